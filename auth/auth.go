@@ -2,10 +2,15 @@ package auth
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"errors"
 	"time"
 
+	"github.com/Parsa-Sh-Y/book-manager-service/config"
 	"github.com/Parsa-Sh-Y/book-manager-service/db"
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
 	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 )
@@ -16,48 +21,110 @@ var (
 	ErrInvalidToken        = errors.New("invalid token")
 	ErrCanNotValidateToken = errors.New("can not validate the user token")
 	ErrAnuthorizedToken    = errors.New("anuthorized token")
+	ErrRevokedToken        = errors.New("the session of the token was logged out")
+	ErrExpiredToken        = errors.New("the token has expired")
 )
 
+// unknownUserHash is compared with the password given for an unknown username, so a login
+// takes as long for an unknown username as for a wrong password. Its cost is the one of the
+// hashes of db.
+var unknownUserHash, _ = bcrypt.GenerateFromPassword([]byte("unknown user"), 4)
+
+// Store is the storage Auth needs to log in the users and keep their sessions
+type Store interface {
+	db.UserStore
+	db.SessionStore
+}
+
+// Tokens are issued when a user logs in or refreshes a session.
+// The access token authenticates the requests and the refresh token
+// is exchanged for new tokens when the access token expires.
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+}
+
 type UserCredentials struct {
 	Username string `json:"username"`
 	Password string `json:"password"`
 }
 
+// claims of the access tokens.
+// The subject is the username and the token never carries credentials
+// or the role, which is read from the database on every request.
 type claims struct {
-	jwt.MapClaims
-	Username string `json:"username"`
-	Password string `json:"password"`
+	jwt.RegisteredClaims
+	SessionID uint `json:"sid"`
+}
+
+// Identity is the user an access token was issued to
+type Identity struct {
+	Username  string
+	SessionID uint
+}
+
+// Options configures the tokens issued by Auth
+type Options struct {
+	// AccessTokenDuration is how long an access token is valid
+	AccessTokenDuration time.Duration
+	// SessionDuration is how long a session can be refreshed after logging in
+	SessionDuration time.Duration
+	// Issuer and Audience are set in the tokens and checked when verifying them
+	Issuer   string
+	Audience string
+	// ClockSkew is the tolerated difference between the clocks of the instances
+	ClockSkew time.Duration
+}
+
+// NewOptions returns the Options set in the configuration
+func NewOptions(conf config.Config) Options {
+
+	return Options{
+		AccessTokenDuration: time.Duration(int64(time.Minute) * conf.JwtExpirationInMinutes),
+		SessionDuration:     time.Duration(int64(time.Hour) * conf.JwtRefreshExpirationInHours),
+		Issuer:              conf.JwtIssuer,
+		Audience:            conf.JwtAudience,
+		ClockSkew:           time.Duration(int64(time.Second) * conf.JwtClockSkewInSeconds),
+	}
 }
 
 type Auth struct {
-	db *db.GormDB
-	// jwtSecretKey is the JWT secret key. Each time the server starts, new key is generated.
-	jwtSecretKey          []byte
-	jwtExpirationDuration time.Duration
+	db Store
+	// keys signs and verifies the JWT tokens
+	keys    *KeySet
+	options Options
+	parser  *jwt.Parser
 }
 
 // NewAuth creates new instance of Auth for authenticating user accounts.
-func NewAuth(authDB *db.GormDB, jwtExpirationInMinutes int64) (*Auth, error) {
-	secretKey, err := generateRandomKey()
-	if err != nil {
-		return nil, err
-	}
+func NewAuth(authDB Store, keys *KeySet, options Options) (*Auth, error) {
 
 	// Check the authDB
 	if authDB == nil {
 		return nil, errors.New("the authenticate database is essential")
 	}
 
+	// Check the keys
+	if keys == nil {
+		return nil, errors.New("the jwt signing keys are essential")
+	}
+
 	return &Auth{
-		db:                    authDB,
-		jwtSecretKey:          secretKey,
-		jwtExpirationDuration: time.Duration(int64(time.Minute) * jwtExpirationInMinutes),
+		db:      authDB,
+		keys:    keys,
+		options: options,
+		parser: jwt.NewParser(
+			jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+			jwt.WithIssuer(options.Issuer),
+			jwt.WithAudience(options.Audience),
+			jwt.WithLeeway(options.ClockSkew),
+			jwt.WithIssuedAt(),
+		),
 	}, nil
 }
 
 // generateRandomKey
-// Each time that Auth is initialized, generateRandomKey is called to
-// generate another key
+// GenerateKeySet calls generateRandomKey when no signing key is configured
 func generateRandomKey() ([]byte, error) {
 	jwtKey := make([]byte, 32)
 	if _, err := rand.Read(jwtKey); err != nil {
@@ -67,62 +134,212 @@ func generateRandomKey() ([]byte, error) {
 	return jwtKey, nil
 }
 
-// returns an empty string when there is an error
-func (a *Auth) Login(cred *UserCredentials) (string, error) {
+// returns nil tokens when there is an error
+func (a *Auth) Login(cred *UserCredentials) (*Tokens, error) {
 
 	// get the user from the database
 	user, err := a.db.GetUserByUsername(cred.Username)
-	if err != nil {
-		return "", err
+	if err == db.ErrUserNotFound {
+		bcrypt.CompareHashAndPassword(unknownUserHash, []byte(cred.Password))
+		return nil, err
+	} else if err != nil {
+		return nil, err
 	}
 
 	// check if password is correct
 	if err := bcrypt.CompareHashAndPassword([]byte(user.Password), []byte(cred.Password)); err != nil {
-		return "", ErrIncorrectPassword
+		return nil, ErrIncorrectPassword
+	}
+
+	// start a new session
+	refreshToken, refreshTokenHash, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+	session := models.Session{UserID: user.ID, ExpiresAt: time.Now().Add(a.options.SessionDuration)}
+	if err := a.db.CreateSession(&session, refreshTokenHash); err != nil {
+		return nil, err
+	}
+
+	accessToken, err := a.createAccessToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tokens{AccessToken: accessToken, RefreshToken: refreshToken}, nil
+}
+
+// Refresh exchanges a refresh token for new tokens of the same session.
+// Each refresh token can be used once, using it again revokes the session.
+func (a *Auth) Refresh(refreshToken string) (*Tokens, error) {
+
+	if refreshToken == "" {
+		return nil, ErrEmptyTokenString
+	}
+
+	newRefreshToken, newRefreshTokenHash, err := generateRefreshToken()
+	if err != nil {
+		return nil, err
+	}
+
+	session, err := a.db.RotateRefreshToken(hashRefreshToken(refreshToken), newRefreshTokenHash)
+	if err != nil {
+		return nil, err
+	}
+
+	user, err := a.db.GetUserByID(session.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	accessToken, err := a.createAccessToken(user, session.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Tokens{AccessToken: accessToken, RefreshToken: newRefreshToken}, nil
+}
+
+// Logout revokes the session of the identity.
+// Neither the access token nor the refresh tokens of the session are accepted afterwards.
+func (a *Auth) Logout(identity *Identity) error {
+
+	return a.db.RevokeSession(identity.SessionID)
+}
+
+func (a *Auth) createAccessToken(user *models.User, sessionID uint) (string, error) {
+
+	id, err := generateTokenID()
+	if err != nil {
+		return "", err
 	}
 
 	// Create the JWT token
-	expirationTime := time.Now().Add(a.jwtExpirationDuration)
+	now := time.Now()
 	tokenJWT := jwt.NewWithClaims(jwt.SigningMethodHS256, &claims{
-		Username: cred.Username,
-		MapClaims: jwt.MapClaims{
-			"expired_at": expirationTime.Unix(),
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        id,
+			Subject:   user.Username,
+			Issuer:    a.options.Issuer,
+			Audience:  jwt.ClaimStrings{a.options.Audience},
+			IssuedAt:  jwt.NewNumericDate(now),
+			NotBefore: jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(a.options.AccessTokenDuration)),
 		},
+		SessionID: sessionID,
 	})
 
-	tokenString, err := tokenJWT.SignedString(a.jwtSecretKey)
-	if err != nil {
+	// sign with the active key and name it in the header
+	keyID, secret := a.keys.active()
+	tokenJWT.Header["kid"] = keyID
+
+	return tokenJWT.SignedString(secret)
+}
+
+// generateTokenID returns a random id for the jti claim
+func generateTokenID() (string, error) {
+
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
 		return "", err
 	}
 
-	return tokenString, nil
+	return hex.EncodeToString(random), nil
+}
+
+// generateRefreshToken returns a random refresh token and the hash that is stored in the database
+func generateRefreshToken() (token string, hash string, err error) {
+
+	random := make([]byte, 32)
+	if _, err := rand.Read(random); err != nil {
+		return "", "", err
+	}
+
+	token = base64.RawURLEncoding.EncodeToString(random)
+	return token, hashRefreshToken(token), nil
+}
+
+func hashRefreshToken(token string) string {
+
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
 }
 
 // returns an empty username string if there is an error
 func (a *Auth) GetUsernameByToken(token string) (username string, err error) {
 
+	identity, err := a.Authenticate(token)
+	if err != nil {
+		return "", err
+	}
+
+	return identity.Username, nil
+
+}
+
+// Authenticate verifies the access token and returns whom it was issued to.
+// When there is an error nil is returned instead of an identity.
+func (a *Auth) Authenticate(token string) (*Identity, error) {
+
+	c, err := a.parseToken(token)
+	if err != nil {
+		return nil, err
+	}
+
+	// check if the session was logged out
+	session, err := a.db.GetSession(c.SessionID)
+	if err == db.ErrSessionNotFound {
+		return nil, ErrRevokedToken
+	} else if err != nil {
+		return nil, ErrCanNotValidateToken
+	}
+	if session.RevokedAt != nil {
+		return nil, ErrRevokedToken
+	}
+
+	return &Identity{Username: c.Subject, SessionID: c.SessionID}, nil
+
+}
+
+// parseToken verifies the signature of the token and returns its claims
+func (a *Auth) parseToken(token string) (*claims, error) {
+
 	// check if token is empty
 	if token == "" {
-		return "", ErrEmptyTokenString
+		return nil, ErrEmptyTokenString
 	}
 
 	c := &claims{}
 
-	jwtToken, err := jwt.ParseWithClaims(token, c, func(token *jwt.Token) (interface{}, error) {
-		return a.jwtSecretKey, nil
+	jwtToken, err := a.parser.ParseWithClaims(token, c, func(token *jwt.Token) (interface{}, error) {
+		// verify with the key named in the header
+		keyID, _ := token.Header["kid"].(string)
+		return a.keys.lookup(keyID)
 	})
 	if err != nil {
-		if err == jwt.ErrSignatureInvalid {
-			return "", errors.New("invalid token")
-		} else {
-			return "", ErrCanNotValidateToken
+		switch {
+		case errors.Is(err, jwt.ErrTokenExpired):
+			return nil, ErrExpiredToken
+		case errors.Is(err, jwt.ErrTokenMalformed),
+			errors.Is(err, jwt.ErrTokenSignatureInvalid),
+			errors.Is(err, jwt.ErrTokenUnverifiable),
+			errors.Is(err, jwt.ErrTokenInvalidClaims),
+			errors.Is(err, ErrUnknownKey):
+			return nil, ErrInvalidToken
+		default:
+			return nil, ErrCanNotValidateToken
 		}
 	}
 
 	if !jwtToken.Valid {
-		return "", ErrAnuthorizedToken
-	} else {
-		return c.Username, nil
+		return nil, ErrAnuthorizedToken
 	}
 
+	// jwt only checks exp when it is present
+	if c.ExpiresAt == nil || c.Subject == "" {
+		return nil, ErrInvalidToken
+	}
+
+	return c, nil
+
 }
@@ -0,0 +1,152 @@
+package auth
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// minKeySize is the minimum size of a HS256 secret in bytes
+const minKeySize = 32
+
+var (
+	ErrNoActiveKey = errors.New("the active signing key is not one of the signing keys")
+	ErrUnknownKey  = errors.New("the token is signed by an unknown key")
+)
+
+// KeySet is the set of keys the tokens are verified with.
+// New tokens are signed with the active key.
+//
+// To rotate the keys without logging out the users, add the new key to the set,
+// make it the active key once every instance knows it, and remove the old key
+// after the tokens signed by it have expired.
+type KeySet struct {
+	activeKeyID string
+	keys        map[string][]byte
+}
+
+// keyFile is the format of the JWT key file
+//
+//	{
+//	  "active_key_id": "2023-10",
+//	  "keys": [
+//	    {"id": "2023-09", "secret": "<base64>"},
+//	    {"id": "2023-10", "secret": "<base64>"}
+//	  ]
+//	}
+type keyFile struct {
+	ActiveKeyID string `json:"active_key_id"`
+	Keys        []struct {
+		ID     string `json:"id"`
+		Secret []byte `json:"secret"`
+	} `json:"keys"`
+}
+
+// LoadKeySet reads the keys from the key file and from keys, a comma separated
+// list of id:base64-secret pairs. The active key is activeKeyID, or the key file's
+// active key, or the first key of keys. It returns a nil KeySet when no key is configured.
+func LoadKeySet(keys string, keyFilePath string, activeKeyID string) (*KeySet, error) {
+
+	ks := &KeySet{keys: make(map[string][]byte)}
+
+	if keyFilePath != "" {
+		data, err := os.ReadFile(keyFilePath)
+		if err != nil {
+			return nil, fmt.Errorf("can not read the jwt key file: %w", err)
+		}
+
+		var file keyFile
+		if err := json.Unmarshal(data, &file); err != nil {
+			return nil, fmt.Errorf("can not parse the jwt key file: %w", err)
+		}
+
+		for _, key := range file.Keys {
+			if err := ks.add(key.ID, key.Secret); err != nil {
+				return nil, err
+			}
+		}
+		ks.activeKeyID = file.ActiveKeyID
+	}
+
+	if keys != "" {
+		for i, pair := range strings.Split(keys, ",") {
+			id, encoded, ok := strings.Cut(strings.TrimSpace(pair), ":")
+			if !ok {
+				return nil, fmt.Errorf("jwt signing key %d is not an id:secret pair", i+1)
+			}
+			secret, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				return nil, fmt.Errorf("jwt signing key %q is not base64 encoded", id)
+			}
+			if err := ks.add(id, secret); err != nil {
+				return nil, err
+			}
+			if ks.activeKeyID == "" && i == 0 {
+				ks.activeKeyID = id
+			}
+		}
+	}
+
+	if len(ks.keys) == 0 {
+		return nil, nil
+	}
+
+	if activeKeyID != "" {
+		ks.activeKeyID = activeKeyID
+	}
+	if _, ok := ks.keys[ks.activeKeyID]; !ok {
+		return nil, ErrNoActiveKey
+	}
+
+	return ks, nil
+}
+
+// GenerateKeySet creates a KeySet with a single random key.
+// The tokens signed by it are lost when the server stops.
+func GenerateKeySet() (*KeySet, error) {
+
+	secret, err := generateRandomKey()
+	if err != nil {
+		return nil, err
+	}
+
+	return &KeySet{
+		activeKeyID: "generated",
+		keys:        map[string][]byte{"generated": secret},
+	}, nil
+}
+
+func (ks *KeySet) add(id string, secret []byte) error {
+
+	if id == "" {
+		return errors.New("a jwt signing key has no id")
+	}
+	if _, ok := ks.keys[id]; ok {
+		return fmt.Errorf("jwt signing key %q is defined twice", id)
+	}
+	if len(secret) < minKeySize {
+		return fmt.Errorf("jwt signing key %q is shorter than %d bytes", id, minKeySize)
+	}
+
+	ks.keys[id] = secret
+	return nil
+}
+
+// active returns the id and the secret of the key new tokens are signed with
+func (ks *KeySet) active() (string, []byte) {
+	return ks.activeKeyID, ks.keys[ks.activeKeyID]
+}
+
+// lookup returns the secret of the key with the given id
+func (ks *KeySet) lookup(id string) ([]byte, error) {
+
+	secret, ok := ks.keys[id]
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+
+	return secret, nil
+}
@@ -0,0 +1,72 @@
+package auth
+
+import "github.com/Parsa-Sh-Y/book-manager-service/db/models"
+
+// Permission is an action a role may be allowed to take
+type Permission string
+
+const (
+	PermissionReadBooks     Permission = "books:read"
+	PermissionCreateBook    Permission = "books:create"
+	PermissionEditOwnBook   Permission = "books:edit-own"
+	PermissionDeleteOwnBook Permission = "books:delete-own"
+	// PermissionEditAnyBook allows editing every field of the books of other users
+	PermissionEditAnyBook Permission = "books:edit-any"
+	// PermissionEditCatalog allows editing the catalog fields of the books of other users:
+	// the authors, the publisher, the category, the series, the ISBNs and the published date
+	PermissionEditCatalog   Permission = "books:edit-catalog"
+	PermissionDeleteAnyBook Permission = "books:delete-any"
+	PermissionManageUsers   Permission = "users:manage"
+	// PermissionManageAuthors allows editing and deleting the authors shared by all the books
+	PermissionManageAuthors Permission = "authors:manage"
+	// PermissionManagePublishers allows editing and deleting the publishers shared by all the books
+	PermissionManagePublishers Permission = "publishers:manage"
+	// PermissionManageCategories allows adding, changing and deleting the categories of the tree
+	PermissionManageCategories Permission = "categories:manage"
+	// PermissionManageTags allows renaming and deleting a tag on all the books having it
+	PermissionManageTags Permission = "tags:manage"
+	// PermissionManageSeries allows editing and deleting the series shared by all the books
+	PermissionManageSeries Permission = "series:manage"
+)
+
+// permissions is the permission matrix of the roles
+var permissions = map[string]map[Permission]bool{
+	models.RoleMember: {
+		PermissionReadBooks:     true,
+		PermissionCreateBook:    true,
+		PermissionEditOwnBook:   true,
+		PermissionDeleteOwnBook: true,
+	},
+	models.RoleLibrarian: {
+		PermissionReadBooks:        true,
+		PermissionCreateBook:       true,
+		PermissionEditOwnBook:      true,
+		PermissionDeleteOwnBook:    true,
+		PermissionEditCatalog:      true,
+		PermissionManageAuthors:    true,
+		PermissionManagePublishers: true,
+		PermissionManageCategories: true,
+		PermissionManageTags:       true,
+		PermissionManageSeries:     true,
+	},
+	models.RoleAdmin: {
+		PermissionReadBooks:        true,
+		PermissionCreateBook:       true,
+		PermissionEditOwnBook:      true,
+		PermissionDeleteOwnBook:    true,
+		PermissionEditAnyBook:      true,
+		PermissionEditCatalog:      true,
+		PermissionDeleteAnyBook:    true,
+		PermissionManageUsers:      true,
+		PermissionManageAuthors:    true,
+		PermissionManagePublishers: true,
+		PermissionManageCategories: true,
+		PermissionManageTags:       true,
+		PermissionManageSeries:     true,
+	},
+}
+
+// Can reports whether the role has the permission
+func Can(role string, permission Permission) bool {
+	return permissions[role][permission]
+}
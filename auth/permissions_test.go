@@ -0,0 +1,46 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+)
+
+func TestCan(t *testing.T) {
+
+	all := []Permission{
+		PermissionReadBooks, PermissionCreateBook, PermissionEditOwnBook, PermissionDeleteOwnBook,
+		PermissionEditAnyBook, PermissionEditCatalog, PermissionDeleteAnyBook, PermissionManageUsers,
+		PermissionManageAuthors, PermissionManagePublishers, PermissionManageCategories,
+		PermissionManageTags, PermissionManageSeries,
+	}
+
+	tests := []struct {
+		role string
+		want []Permission
+	}{
+		{models.RoleMember, []Permission{
+			PermissionReadBooks, PermissionCreateBook, PermissionEditOwnBook, PermissionDeleteOwnBook,
+		}},
+		{models.RoleLibrarian, []Permission{
+			PermissionReadBooks, PermissionCreateBook, PermissionEditOwnBook, PermissionDeleteOwnBook,
+			PermissionEditCatalog, PermissionManageAuthors, PermissionManagePublishers,
+			PermissionManageCategories, PermissionManageTags, PermissionManageSeries,
+		}},
+		{models.RoleAdmin, all},
+		{"", nil},
+		{"guest", nil},
+	}
+
+	for _, tt := range tests {
+		want := map[Permission]bool{}
+		for _, permission := range tt.want {
+			want[permission] = true
+		}
+		for _, permission := range all {
+			if got := Can(tt.role, permission); got != want[permission] {
+				t.Errorf("Can(%q, %q) = %v, want %v", tt.role, permission, got, want[permission])
+			}
+		}
+	}
+}
@@ -2,11 +2,29 @@ package config
 
 type Config struct {
 	Database struct {
+		Driver   string `env:"DATABASE_DRIVER" env-default:"postgres" env-description:"Database driver for service, postgres or sqlite"`
 		Host     string `env:"DATABASE_HOST" env-default:"localhost" env-description:"Database host for service"`
 		Port     int    `env:"DATABASE_PORT" env-default:"5432" env-description:"Database port for service"`
 		Name     string `env:"DATABASE_NAME" env-default:"book_manager_db" env-description:"Database name for service"`
 		User     string `env:"DATABASE_USER" env-default:"postgres" env-description:"Database user for service"`
 		Password string `env:"DATABASE_PASSWORD" env-default:"postgresdev82" env-description:"Database password for service"`
+		Path     string `env:"DATABASE_PATH" env-default:"book_manager.db" env-description:"Database file for the sqlite driver, :memory: for an in-memory database"`
 	}
-	JwtExpirationInMinutes int64 `env:"JWT_EXP_MINUTES" env-default:"10" env-description:"Jwt expiration minutes"`
+	Metadata struct {
+		Provider         string `env:"METADATA_PROVIDER" env-default:"openlibrary" env-description:"Catalog the metadata of the books is looked up in, openlibrary or fixture"`
+		URL              string `env:"METADATA_URL" env-default:"https://openlibrary.org" env-description:"Base URL of the Open Library compatible catalog"`
+		FixturePath      string `env:"METADATA_FIXTURE_PATH" env-default:"metadata/testdata/openlibrary.json" env-description:"Json file of the books of the fixture catalog"`
+		TimeoutInSeconds int64  `env:"METADATA_TIMEOUT_SECONDS" env-default:"10" env-description:"Timeout of the requests to the catalog"`
+	}
+	Import struct {
+		MaxFileSizeInMegabytes int64 `env:"IMPORT_MAX_FILE_SIZE_MB" env-default:"32" env-description:"Largest file imported by POST /api/v1/books/import"`
+	}
+	JwtExpirationInMinutes      int64  `env:"JWT_EXP_MINUTES" env-default:"10" env-description:"Jwt expiration minutes"`
+	JwtRefreshExpirationInHours int64  `env:"JWT_REFRESH_EXP_HOURS" env-default:"168" env-description:"Refresh token expiration hours"`
+	JwtIssuer                   string `env:"JWT_ISSUER" env-default:"book-manager-service" env-description:"Issuer of the jwt tokens"`
+	JwtAudience                 string `env:"JWT_AUDIENCE" env-default:"book-manager-service" env-description:"Audience of the jwt tokens"`
+	JwtClockSkewInSeconds       int64  `env:"JWT_CLOCK_SKEW_SECONDS" env-default:"30" env-description:"Tolerated clock skew when validating jwt tokens"`
+	JwtSigningKeys              string `env:"JWT_SIGNING_KEYS" env-description:"Jwt signing keys as comma separated id:base64-secret pairs"`
+	JwtKeyFile                  string `env:"JWT_KEY_FILE" env-description:"Json file with the jwt signing keys"`
+	JwtActiveKeyID              string `env:"JWT_ACTIVE_KEY_ID" env-description:"Id of the key new jwt tokens are signed with"`
 }
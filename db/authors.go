@@ -0,0 +1,234 @@
+package db
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrAuthorNotFound No such author exists
+	ErrAuthorNotFound = errors.New("no such author exists")
+	// ErrAuthorHasBooks The author is credited in books and can not be deleted
+	ErrAuthorHasBooks = errors.New("the author is credited in books")
+	// ErrUnknownAuthor The book credits an author id that does not exist
+	ErrUnknownAuthor = errors.New("the book credits an author that does not exist")
+)
+
+// AuthorQuery filters and paginates the authors returned by GetAllAuthors.
+// The authors are ordered by last name, first name and id.
+type AuthorQuery struct {
+	// Name matches a part of the first name, last name or full name, ignoring case
+	Name string
+
+	// Limit is the maximum number of authors returned, zero means no limit
+	Limit  int
+	Offset int
+}
+
+// authorNameCondition matches a part of the name of an author
+const authorNameCondition = `(LOWER(authors.first_name) LIKE @p ESCAPE '\' OR LOWER(authors.last_name) LIKE @p ESCAPE '\'
+	OR LOWER(authors.first_name || ' ' || authors.last_name) LIKE @p ESCAPE '\')`
+
+func (gdb *GormDB) CreateAuthor(author *models.Author) error {
+
+	author.ID = 0
+	normalizeAuthor(author)
+	return gdb.db.Create(author).Error
+
+}
+
+func (gdb *GormDB) GetAuthor(id uint) (*models.Author, error) {
+
+	var author models.Author
+	err := gdb.db.Where("id = ?", id).First(&author).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrAuthorNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &author, nil
+
+}
+
+// GetAllAuthors returns a page of the authors matching the query
+// and the number of matching authors on all the pages
+func (gdb *GormDB) GetAllAuthors(query AuthorQuery) ([]models.Author, int64, error) {
+
+	tx := gdb.db.Model(models.Author{})
+	if query.Name != "" {
+		pattern := "%" + escapeLike(strings.ToLower(query.Name)) + "%"
+		tx = tx.Where(authorNameCondition, map[string]interface{}{"p": pattern})
+	}
+	tx = tx.Session(&gorm.Session{})
+
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	tx = tx.Order("last_name").Order("first_name").Order("id")
+	if query.Limit > 0 {
+		tx = tx.Limit(query.Limit)
+	}
+	if query.Offset > 0 {
+		tx = tx.Offset(query.Offset)
+	}
+
+	authors := []models.Author{}
+	if err := tx.Find(&authors).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return authors, total, nil
+
+}
+
+// UpdateAuthor replaces every field of the author with the same id
+func (gdb *GormDB) UpdateAuthor(author *models.Author) error {
+
+	normalizeAuthor(author)
+	result := gdb.db.Model(&models.Author{}).Where("id = ?", author.ID).Select("*").Omit("id").Updates(author)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrAuthorNotFound
+	}
+
+	return nil
+
+}
+
+// DeleteAuthor deletes the author if no book credits it
+func (gdb *GormDB) DeleteAuthor(id uint) error {
+
+	return gdb.db.Transaction(func(tx *gorm.DB) error {
+
+		var count int64
+		if err := tx.Model(&models.BookAuthor{}).Where("author_id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrAuthorHasBooks
+		}
+
+		result := tx.Delete(&models.Author{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrAuthorNotFound
+		}
+
+		return nil
+	})
+
+}
+
+// setBookAuthors credits the authors to the book in order. An author with an id
+// has to exist, the others are looked up by name and created if there is none.
+// The same author with the same role is only credited once.
+func setBookAuthors(tx *gorm.DB, bookID uint, authors []models.BookAuthor) ([]models.BookAuthor, error) {
+
+	type credit struct {
+		authorID uint
+		role     string
+	}
+
+	credited := make([]models.BookAuthor, 0, len(authors))
+	seen := make(map[credit]bool, len(authors))
+	for _, link := range authors {
+		if err := findOrCreateAuthor(tx, &link.Author); err != nil {
+			return nil, err
+		}
+
+		link.BookID = bookID
+		link.AuthorID = link.Author.ID
+		if link.Role == "" {
+			link.Role = models.AuthorRoleAuthor
+		}
+		key := credit{link.AuthorID, link.Role}
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		link.Position = len(credited)
+		if err := tx.Omit(clause.Associations).Create(&link).Error; err != nil {
+			return nil, err
+		}
+		credited = append(credited, link)
+	}
+
+	return credited, nil
+}
+
+// findOrCreateAuthor replaces author with the stored author of the same id or, without an id,
+// of the same name ignoring case and spaces. An author with a new name is created.
+func findOrCreateAuthor(tx *gorm.DB, author *models.Author) error {
+
+	if author.ID != 0 {
+		err := tx.Where("id = ?", author.ID).First(author).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUnknownAuthor
+		}
+		return err
+	}
+
+	normalizeAuthor(author)
+	var stored models.Author
+	err := tx.Where("LOWER(first_name) = ? AND LOWER(last_name) = ?",
+		strings.ToLower(author.FirstName), strings.ToLower(author.LastName)).Order("id").First(&stored).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return tx.Create(author).Error
+	} else if err != nil {
+		return err
+	}
+
+	*author = stored
+	return nil
+}
+
+// preloadBooks loads the category, the series and the publisher of the books,
+// their credits in order, with their authors, and their tags ordered by name
+func preloadBooks(tx *gorm.DB) *gorm.DB {
+
+	return tx.Preload("Category").Preload("Series").Preload("Publisher").Preload("Authors", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("position")
+	}).Preload("Authors.Author").Preload("Tags", func(tx *gorm.DB) *gorm.DB {
+		return tx.Order("name")
+	})
+}
+
+// normalizeAuthor trims the names of the author and collapses their spaces
+func normalizeAuthor(author *models.Author) {
+
+	author.FirstName = normalizeName(author.FirstName)
+	author.LastName = normalizeName(author.LastName)
+	author.Nationality = normalizeName(author.Nationality)
+
+}
+
+func normalizeName(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// sameAuthorName reports whether the names are the same author, like findOrCreateAuthor
+func sameAuthorName(a, b *models.Author) bool {
+	return strings.EqualFold(a.FirstName, b.FirstName) && strings.EqualFold(a.LastName, b.LastName)
+}
+
+// matchAuthorName reports whether the lower case part is in the name of the author,
+// like authorNameCondition
+func matchAuthorName(author *models.Author, part string) bool {
+
+	first := strings.ToLower(author.FirstName)
+	last := strings.ToLower(author.LastName)
+
+	return strings.Contains(first, part) || strings.Contains(last, part) || strings.Contains(first+" "+last, part)
+}
@@ -0,0 +1,257 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrCategoryNotFound No such category exists
+	ErrCategoryNotFound = errors.New("no such category exists")
+	// ErrCategoryNameIsInUse There exists another category with the same name and parent
+	ErrCategoryNameIsInUse = errors.New("name is in use by another category with the same parent")
+	// ErrInvalidCategoryParent The parent is not a category, or is nested in the category itself
+	ErrInvalidCategoryParent = errors.New("the parent is not a category or is nested in the category")
+	// ErrCategoryNotEmpty The category has books or subcategories and can not be deleted
+	ErrCategoryNotEmpty = errors.New("the category has books or subcategories")
+	// ErrUnknownCategory The category of the book does not exist, or its name is shared by more than one category
+	ErrUnknownCategory = errors.New("the book is in a category that does not exist or whose name is ambiguous")
+)
+
+// categorySubtreeQuery selects the ids of the categories matching the condition
+// and of all the categories nested in them
+const categorySubtreeQuery = `WITH RECURSIVE subtree(id) AS (
+		SELECT id FROM categories WHERE %s
+		UNION ALL
+		SELECT categories.id FROM categories JOIN subtree ON categories.parent_id = subtree.id
+	) SELECT id FROM subtree`
+
+// CreateCategory adds the category under its parent. The unique index of the
+// categories table makes sure no category with the same parent has the same name.
+func (gdb *GormDB) CreateCategory(category *models.Category) error {
+
+	category.ID = 0
+	category.Children = nil
+	category.Name = normalizeName(category.Name)
+
+	return gdb.db.Transaction(func(tx *gorm.DB) error {
+
+		if category.ParentID != nil {
+			var count int64
+			if err := tx.Model(&models.Category{}).Where("id = ?", *category.ParentID).Count(&count).Error; err != nil {
+				return err
+			}
+			if count == 0 {
+				return ErrInvalidCategoryParent
+			}
+		}
+
+		return translateError(tx.Create(category).Error)
+	})
+
+}
+
+// GetCategory returns the category with its subcategories nested in it
+func (gdb *GormDB) GetCategory(id uint) (*models.Category, error) {
+
+	var categories []models.Category
+	if err := gdb.db.Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	return categoryWithChildren(categories, id)
+}
+
+// GetCategoryTree returns the top level categories with their subcategories nested in them
+func (gdb *GormDB) GetCategoryTree() ([]models.Category, error) {
+
+	var categories []models.Category
+	if err := gdb.db.Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	tree := models.CategoryTree(categories)
+	if tree == nil {
+		tree = []models.Category{}
+	}
+
+	return tree, nil
+}
+
+// UpdateCategory renames the category with the same id and moves it under its parent,
+// along with its subcategories
+func (gdb *GormDB) UpdateCategory(category *models.Category) error {
+
+	category.Children = nil
+	category.Name = normalizeName(category.Name)
+
+	return gdb.db.Transaction(func(tx *gorm.DB) error {
+
+		var categories []models.Category
+		if err := tx.Find(&categories).Error; err != nil {
+			return err
+		}
+		if err := validCategoryParent(categories, category.ID, category.ParentID); err != nil {
+			return err
+		}
+
+		err := tx.Model(&models.Category{}).Where("id = ?", category.ID).Select("name", "parent_id").Updates(category).Error
+		return translateError(err)
+	})
+
+}
+
+// DeleteCategory deletes the category. Without moveTo the category can not have books or
+// subcategories, otherwise they are moved to the category with the id moveTo first.
+func (gdb *GormDB) DeleteCategory(id uint, moveTo uint) error {
+
+	return gdb.db.Transaction(func(tx *gorm.DB) error {
+
+		var categories []models.Category
+		if err := tx.Find(&categories).Error; err != nil {
+			return err
+		}
+		if !hasCategory(categories, id) {
+			return ErrCategoryNotFound
+		}
+
+		if moveTo != 0 {
+			if err := validCategoryParent(categories, id, &moveTo); err != nil {
+				return err
+			}
+
+			// the books change, so their version is incremented
+			err := tx.Model(&models.Book{}).Where("category_id = ?", id).
+				Updates(map[string]interface{}{"category_id": moveTo, "version": gorm.Expr("version + 1")}).Error
+			if err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Category{}).Where("parent_id = ?", id).Update("parent_id", moveTo).Error; err != nil {
+				return translateError(err)
+			}
+		} else {
+			var books, children int64
+			if err := tx.Model(&models.Book{}).Where("category_id = ?", id).Count(&books).Error; err != nil {
+				return err
+			}
+			if err := tx.Model(&models.Category{}).Where("parent_id = ?", id).Count(&children).Error; err != nil {
+				return err
+			}
+			if books > 0 || children > 0 {
+				return ErrCategoryNotEmpty
+			}
+		}
+
+		return tx.Delete(&models.Category{}, id).Error
+	})
+
+}
+
+// setBookCategory links the book to its category. Unlike the authors and the publishers
+// the categories are never created for a book: the category is looked up by its id or,
+// without an id, by its name ignoring case, which has to be unique in the whole tree.
+func setBookCategory(tx *gorm.DB, book *models.Book) error {
+
+	book.CategoryID = nil
+	if book.Category == nil {
+		return nil
+	}
+
+	found := tx.Model(&models.Category{})
+	if book.Category.ID != 0 {
+		found = found.Where("id = ?", book.Category.ID)
+	} else {
+		found = found.Where("LOWER(name) = LOWER(?)", normalizeName(book.Category.Name))
+	}
+
+	var categories []models.Category
+	if err := found.Limit(2).Find(&categories).Error; err != nil {
+		return err
+	}
+	if len(categories) != 1 {
+		return ErrUnknownCategory
+	}
+
+	book.Category = &categories[0]
+	book.CategoryID = &categories[0].ID
+	return nil
+}
+
+// categoryWithChildren returns the category with the id among categories,
+// with its subcategories nested in it
+func categoryWithChildren(categories []models.Category, id uint) (*models.Category, error) {
+
+	subtree := categorySubtree(categories, id)
+	nested := make([]models.Category, 0, len(subtree))
+	for _, category := range categories {
+		if subtree[category.ID] {
+			nested = append(nested, category)
+		}
+	}
+
+	for _, category := range models.CategoryTree(nested) {
+		if category.ID == id {
+			return &category, nil
+		}
+	}
+
+	return nil, ErrCategoryNotFound
+}
+
+// validCategoryParent checks that the category with the id exists and can be moved under parentID
+func validCategoryParent(categories []models.Category, id uint, parentID *uint) error {
+
+	if !hasCategory(categories, id) {
+		return ErrCategoryNotFound
+	}
+	if parentID != nil && (!hasCategory(categories, *parentID) || categorySubtree(categories, id)[*parentID]) {
+		return ErrInvalidCategoryParent
+	}
+
+	return nil
+}
+
+func hasCategory(categories []models.Category, id uint) bool {
+
+	for _, category := range categories {
+		if category.ID == id {
+			return true
+		}
+	}
+
+	return false
+}
+
+// categorySubtree returns the ids of the roots and of all the categories nested in them.
+// It is the in-memory counterpart of categorySubtreeQuery.
+func categorySubtree(categories []models.Category, roots ...uint) map[uint]bool {
+
+	ids := make(map[uint]bool, len(roots))
+	for _, id := range roots {
+		ids[id] = true
+	}
+
+	for changed := true; changed; {
+		changed = false
+		for _, category := range categories {
+			if !ids[category.ID] && category.ParentID != nil && ids[*category.ParentID] {
+				ids[category.ID] = true
+				changed = true
+			}
+		}
+	}
+
+	return ids
+}
+
+// categoryName returns the name of the category of the book, empty when it has none
+func categoryName(book *models.Book) string {
+
+	if book.Category == nil {
+		return ""
+	}
+
+	return book.Category.Name
+}
@@ -0,0 +1,56 @@
+package db
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/jackc/pgx/v5/pgconn"
+	"github.com/mattn/go-sqlite3"
+)
+
+// uniqueViolationErrors maps the unique indexes to the errors of their violations.
+// Postgres names the violated index, sqlite names the table and the column,
+// or the index when it is on an expression.
+var uniqueViolationErrors = map[string]error{
+	"idx_users_username":       ErrUsernameIsInUse,
+	"users.username":           ErrUsernameIsInUse,
+	"idx_users_email":          ErrEmailIsInUse,
+	"users.email":              ErrEmailIsInUse,
+	"idx_users_phone_number":   ErrPhoneNumberIsInUse,
+	"users.phone_number":       ErrPhoneNumberIsInUse,
+	"idx_publishers_name":      ErrPublisherNameIsInUse,
+	"idx_categories_name":      ErrCategoryNameIsInUse,
+	"idx_series_name":          ErrSeriesNameIsInUse,
+	"idx_books_user_id_isbn13": ErrISBNIsInUse,
+	"books.isbn13":             ErrISBNIsInUse,
+}
+
+// postgresUniqueViolation is the SQLSTATE of unique constraint violations
+const postgresUniqueViolation = "23505"
+
+// translateError returns the sentinel error of a constraint violation,
+// or err when it is not a known violation
+func translateError(err error) error {
+
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) && pgErr.Code == postgresUniqueViolation {
+		if sentinel, ok := uniqueViolationErrors[pgErr.ConstraintName]; ok {
+			return sentinel
+		}
+	}
+
+	var sqliteErr sqlite3.Error
+	if errors.As(err, &sqliteErr) && sqliteErr.ExtendedCode == sqlite3.ErrConstraintUnique {
+		// the message is "UNIQUE constraint failed: users.username"
+		// or "UNIQUE constraint failed: index 'idx_publishers_name'"
+		_, columns, _ := strings.Cut(sqliteErr.Error(), ": ")
+		for _, column := range strings.Split(columns, ", ") {
+			column = strings.Trim(strings.TrimPrefix(column, "index "), "'")
+			if sentinel, ok := uniqueViolationErrors[column]; ok {
+				return sentinel
+			}
+		}
+	}
+
+	return err
+}
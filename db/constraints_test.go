@@ -0,0 +1,85 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/config"
+	"github.com/jackc/pgx/v5/pgconn"
+)
+
+func TestTranslateError(t *testing.T) {
+
+	// postgres names the violated index
+	other := errors.New("connection refused")
+	pgTests := []struct {
+		err  error
+		want error
+	}{
+		{&pgconn.PgError{Code: "23505", ConstraintName: "idx_users_username"}, ErrUsernameIsInUse},
+		{&pgconn.PgError{Code: "23505", ConstraintName: "idx_users_email"}, ErrEmailIsInUse},
+		{&pgconn.PgError{Code: "23505", ConstraintName: "idx_users_phone_number"}, ErrPhoneNumberIsInUse},
+		{&pgconn.PgError{Code: "23505", ConstraintName: "idx_books_user_id_isbn13"}, ErrISBNIsInUse},
+		{fmt.Errorf("saving the book: %w", &pgconn.PgError{Code: "23505", ConstraintName: "idx_series_name"}), ErrSeriesNameIsInUse},
+		{&pgconn.PgError{Code: "23505", ConstraintName: "idx_tags_name"}, nil},
+		{&pgconn.PgError{Code: "23503", ConstraintName: "idx_users_username"}, nil},
+		{other, other},
+		{nil, nil},
+	}
+	for _, tt := range pgTests {
+		want := tt.want
+		if want == nil {
+			want = tt.err
+		}
+		if err := translateError(tt.err); err != want {
+			t.Errorf("translateError(%v) = %v, want %v", tt.err, err, want)
+		}
+	}
+
+	// sqlite names the table and the columns, or the index on an expression
+	var conf config.Config
+	conf.Database.Driver, conf.Database.Path = DriverSQLite, SQLiteInMemory
+	gdb, err := CreateNewGormDB(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	gdb = newTestGormDB(t, gdb)
+
+	inserts := []string{
+		`INSERT INTO users (username, email, password, role, phone_number) VALUES ('ali', 'ali@example.com', '', 'member', '09121234567')`,
+		`INSERT INTO publishers (name) VALUES ('Ace Books')`,
+		`INSERT INTO categories (name) VALUES ('Sci-Fi')`,
+		`INSERT INTO series (name) VALUES ('Dune')`,
+		`INSERT INTO books (name, isbn13, user_id) VALUES ('Dune', '9780441172719', 1)`,
+	}
+	for _, insert := range inserts {
+		if err := gdb.db.Exec(insert).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	sqliteTests := []struct {
+		name   string
+		insert string
+		want   error
+	}{
+		{"username", `INSERT INTO users (username, email, password, role) VALUES ('ali', 'ali2@example.com', '', 'member')`, ErrUsernameIsInUse},
+		{"email", `INSERT INTO users (username, email, password, role) VALUES ('reza', 'ali@example.com', '', 'member')`, ErrEmailIsInUse},
+		{"phone number", `INSERT INTO users (username, email, password, role, phone_number) VALUES ('sara', 'sara@example.com', '', 'member', '09121234567')`, ErrPhoneNumberIsInUse},
+		{"publisher", `INSERT INTO publishers (name) VALUES ('ace books')`, ErrPublisherNameIsInUse},
+		{"category", `INSERT INTO categories (name) VALUES ('SCI-FI')`, ErrCategoryNameIsInUse},
+		{"series", `INSERT INTO series (name) VALUES ('dune')`, ErrSeriesNameIsInUse},
+		{"isbn 13", `INSERT INTO books (name, isbn13, user_id) VALUES ('Dune', '9780441172719', 1)`, ErrISBNIsInUse},
+	}
+	for _, tt := range sqliteTests {
+		err := gdb.db.Exec(tt.insert).Error
+		if err == nil {
+			t.Errorf("%s: the insert did not fail", tt.name)
+			continue
+		}
+		if got := translateError(err); got != tt.want {
+			t.Errorf("%s: translateError(%v) = %v, want %v", tt.name, err, got, tt.want)
+		}
+	}
+}
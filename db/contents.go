@@ -0,0 +1,260 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrContentNotFound The book has no such entry in its table of contents
+	ErrContentNotFound = errors.New("no such entry exists in the table of contents")
+	// ErrInvalidContentParent The parent is not an entry of the book, or is nested in the entry itself
+	ErrInvalidContentParent = errors.New("the parent is not an entry of the book or is nested in the entry")
+)
+
+// GetContents returns the table of contents of the book as a tree
+func (gdb *GormDB) GetContents(bookID uint) ([]models.Content, error) {
+
+	var count int64
+	if err := gdb.db.Model(&models.Book{}).Where("id = ?", bookID).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count == 0 {
+		return nil, ErrBookNotFound
+	}
+
+	var contents []models.Content
+	if err := gdb.db.Where("book_id = ?", bookID).Find(&contents).Error; err != nil {
+		return nil, err
+	}
+
+	return models.ContentTree(contents), nil
+
+}
+
+// CreateContent inserts the entry, with its children, at its position among the entries
+// with the same parent. A negative position or one past the last entry appends it.
+func (gdb *GormDB) CreateContent(content *models.Content, version uint) error {
+
+	return gdb.db.Transaction(func(tx *gorm.DB) error {
+
+		if err := bumpVersion(tx, content.BookId, version); err != nil {
+			return err
+		}
+		if err := checkContentParent(tx, content.BookId, content.ParentID, 0); err != nil {
+			return err
+		}
+
+		// make room for the entry
+		position, err := insertPosition(tx, content.BookId, content.ParentID, content.Position, 0)
+		if err != nil {
+			return err
+		}
+		if err := shiftContents(tx, content.BookId, content.ParentID, position, 1, 0); err != nil {
+			return err
+		}
+
+		entries := []models.Content{*content}
+		entries[0].Position = position
+		if err := createContents(tx, content.BookId, content.ParentID, entries, position); err != nil {
+			return err
+		}
+
+		*content = entries[0]
+		return nil
+	})
+
+}
+
+// UpdateContent renames the entry, changes its kind and page, and moves it to its
+// parent and position. A negative position keeps the entry in place when its parent
+// does not change, and appends it to the entries of its new parent otherwise.
+// The children of the entry move with it.
+func (gdb *GormDB) UpdateContent(content *models.Content, version uint) error {
+
+	return gdb.db.Transaction(func(tx *gorm.DB) error {
+
+		if err := bumpVersion(tx, content.BookId, version); err != nil {
+			return err
+		}
+
+		var stored models.Content
+		err := tx.Where("id = ? AND book_id = ?", content.ID, content.BookId).First(&stored).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrContentNotFound
+		} else if err != nil {
+			return err
+		}
+
+		if err := checkContentParent(tx, content.BookId, content.ParentID, content.ID); err != nil {
+			return err
+		}
+
+		if sameParent(stored.ParentID, content.ParentID) && content.Position < 0 {
+			content.Position = stored.Position
+		} else {
+			// take the entry out of its siblings and make room among the new ones
+			if err := shiftContents(tx, stored.BookId, stored.ParentID, stored.Position+1, -1, stored.ID); err != nil {
+				return err
+			}
+			position, err := insertPosition(tx, content.BookId, content.ParentID, content.Position, content.ID)
+			if err != nil {
+				return err
+			}
+			if err := shiftContents(tx, content.BookId, content.ParentID, position, 1, content.ID); err != nil {
+				return err
+			}
+			content.Position = position
+		}
+
+		return tx.Model(&stored).Updates(map[string]interface{}{
+			"content_name": content.ContentName,
+			"kind":         content.Kind,
+			"page":         content.Page,
+			"parent_id":    content.ParentID,
+			"position":     content.Position,
+		}).Error
+	})
+
+}
+
+// DeleteContent deletes the entry with the entries nested in it
+func (gdb *GormDB) DeleteContent(bookID uint, contentID uint, version uint) error {
+
+	return gdb.db.Transaction(func(tx *gorm.DB) error {
+
+		if err := bumpVersion(tx, bookID, version); err != nil {
+			return err
+		}
+
+		var stored models.Content
+		err := tx.Where("id = ? AND book_id = ?", contentID, bookID).First(&stored).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrContentNotFound
+		} else if err != nil {
+			return err
+		}
+
+		// the nested entries are deleted by cascade
+		if err := tx.Delete(&stored).Error; err != nil {
+			return err
+		}
+
+		return shiftContents(tx, bookID, stored.ParentID, stored.Position+1, -1, 0)
+	})
+
+}
+
+// bumpVersion increments the version of the book if it is still at version
+func bumpVersion(tx *gorm.DB, bookID uint, version uint) error {
+
+	result := tx.Model(&models.Book{}).Where("id = ? AND version = ?", bookID, version).
+		Update("version", gorm.Expr("version + 1"))
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return versionMismatch(tx, bookID)
+	}
+
+	return nil
+}
+
+// createContents saves the tree of entries under parentID, numbering them from position
+func createContents(tx *gorm.DB, bookID uint, parentID *uint, entries []models.Content, position int) error {
+
+	for i := range entries {
+		entries[i].ID = 0
+		entries[i].BookId = bookID
+		entries[i].ParentID = parentID
+		entries[i].Position = position + i
+		if err := tx.Create(&entries[i]).Error; err != nil {
+			return err
+		}
+
+		if err := createContents(tx, bookID, &entries[i].ID, entries[i].Children, 0); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// siblingContents selects the entries of the book with the parent
+func siblingContents(tx *gorm.DB, bookID uint, parentID *uint) *gorm.DB {
+
+	tx = tx.Model(&models.Content{}).Where("book_id = ?", bookID)
+	if parentID == nil {
+		return tx.Where("parent_id IS NULL")
+	}
+
+	return tx.Where("parent_id = ?", *parentID)
+}
+
+// insertPosition returns where to insert an entry among the entries with the parent,
+// leaving out the entry with id except. Positions out of range append the entry.
+func insertPosition(tx *gorm.DB, bookID uint, parentID *uint, position int, except uint) (int, error) {
+
+	var count int64
+	if err := siblingContents(tx, bookID, parentID).Where("id <> ?", except).Count(&count).Error; err != nil {
+		return 0, err
+	}
+	if position < 0 || position > int(count) {
+		return int(count), nil
+	}
+
+	return position, nil
+}
+
+// shiftContents adds delta to the positions of the entries with the parent
+// at or after from, leaving out the entry with id except
+func shiftContents(tx *gorm.DB, bookID uint, parentID *uint, from int, delta int, except uint) error {
+
+	return siblingContents(tx, bookID, parentID).
+		Where("position >= ? AND id <> ?", from, except).
+		Update("position", gorm.Expr("position + ?", delta)).Error
+}
+
+// checkContentParent makes sure the parent is an entry of the book
+// and is not the entry with id moving or nested in it
+func checkContentParent(tx *gorm.DB, bookID uint, parentID *uint, moving uint) error {
+
+	if parentID == nil {
+		return nil
+	}
+
+	var contents []models.Content
+	if err := tx.Select("id", "parent_id").Where("book_id = ?", bookID).Find(&contents).Error; err != nil {
+		return err
+	}
+
+	return validContentParent(contents, parentID, moving)
+}
+
+// validContentParent walks from the parent up to the top level entries of contents
+func validContentParent(contents []models.Content, parentID *uint, moving uint) error {
+
+	parents := make(map[uint]*uint, len(contents))
+	for _, content := range contents {
+		parents[content.ID] = content.ParentID
+	}
+
+	for id, steps := parentID, 0; id != nil; id, steps = parents[*id], steps+1 {
+		if _, ok := parents[*id]; !ok || *id == moving || steps > len(contents) {
+			return ErrInvalidContentParent
+		}
+	}
+
+	return nil
+}
+
+func sameParent(a *uint, b *uint) bool {
+
+	if a == nil || b == nil {
+		return a == nil && b == nil
+	}
+
+	return *a == *b
+}
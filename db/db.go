@@ -3,12 +3,17 @@ package db
 import (
 	"errors"
 	"fmt"
+	"math"
+	"strings"
 
 	"github.com/Parsa-Sh-Y/book-manager-service/config"
+	"github.com/Parsa-Sh-Y/book-manager-service/db/migrations"
 	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/postgres"
+	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 var (
@@ -24,6 +29,18 @@ var (
 	ErrBookNotFound = errors.New("no such book exists")
 	// ErrPermissionDenied Permission Denied
 	ErrPermissionDenied = errors.New("permission Denied")
+	// ErrBookVersionMismatch The book was changed since the version the client has
+	ErrBookVersionMismatch = errors.New("the book was changed by another request")
+	// ErrUnknownDriver The configured database driver is not supported
+	ErrUnknownDriver = errors.New("unknown database driver")
+)
+
+const (
+	DriverPostgres = "postgres"
+	DriverSQLite   = "sqlite"
+
+	// SQLiteInMemory is the database path that keeps a sqlite database in memory
+	SQLiteInMemory = ":memory:"
 )
 
 type GormDB struct {
@@ -32,147 +49,337 @@ type GormDB struct {
 
 func CreateNewGormDB(config config.Config) (*GormDB, error) {
 
-	dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable",
-		config.Database.Host,
-		config.Database.User,
-		config.Database.Password,
-		config.Database.Name,
-		config.Database.Port)
+	var dialector gorm.Dialector
+	switch config.Database.Driver {
+	case DriverPostgres, "":
+		dsn := fmt.Sprintf("host=%s user=%s password=%s dbname=%s port=%d sslmode=disable",
+			config.Database.Host,
+			config.Database.User,
+			config.Database.Password,
+			config.Database.Name,
+			config.Database.Port)
+		dialector = postgres.Open(dsn)
+	case DriverSQLite:
+		dialector = sqlite.Open(sqliteDSN(config.Database.Path))
+	default:
+		return nil, fmt.Errorf("%w: %q", ErrUnknownDriver, config.Database.Driver)
+	}
 
-	db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+	db, err := gorm.Open(dialector, &gorm.Config{})
 	if err != nil {
 		return nil, err
 	}
 
+	if config.Database.Driver == DriverSQLite && config.Database.Path == SQLiteInMemory {
+		// every connection to :memory: opens a new empty database, so all the queries
+		// have to share a single connection that is never closed while idle or old
+		sqlDB, err := db.DB()
+		if err != nil {
+			return nil, err
+		}
+		sqlDB.SetMaxOpenConns(1)
+		sqlDB.SetMaxIdleConns(1)
+		sqlDB.SetConnMaxLifetime(0)
+		sqlDB.SetConnMaxIdleTime(0)
+	}
+
 	return &GormDB{
 		db: db,
 	}, nil
 
 }
 
+// sqliteDSN builds the sqlite connection string for path.
+// Foreign keys are turned on so deleting a book cascades to its contents as in postgres.
+func sqliteDSN(path string) string {
+
+	if path == SQLiteInMemory {
+		return "file::memory:?_foreign_keys=on"
+	}
+
+	return fmt.Sprintf("file:%s?_foreign_keys=on&_busy_timeout=5000", path)
+}
+
+// CreateSchema applies all the pending migrations
 func (gdb *GormDB) CreateSchema() error {
 
-	err := gdb.db.AutoMigrate(&models.User{}, &models.Book{}, &models.Content{})
+	_, err := gdb.MigrateUp()
+	return err
 
-	if err != nil {
-		return err
-	}
+}
 
-	return nil
+// MigrateUp applies all the pending migrations and returns them
+func (gdb *GormDB) MigrateUp() ([]migrations.Migration, error) {
+	return migrations.Up(gdb.db)
+}
 
+// MigrateDown reverts the last steps applied migrations and returns them
+func (gdb *GormDB) MigrateDown(steps int) ([]migrations.Migration, error) {
+	return migrations.Down(gdb.db, steps)
 }
 
+// MigrationStatus returns all the migrations and whether they are applied
+func (gdb *GormDB) MigrationStatus() ([]migrations.State, error) {
+	return migrations.Status(gdb.db)
+}
+
+// CreateUser adds the user. The unique indexes of the users table make sure
+// no other account has the same username, email or phone number.
 func (gdb *GormDB) CreateUser(user *models.User) error {
 
-	// Check if no other account with the same username exists
-	var count int64
-	gdb.db.Model(&models.User{}).Where("username = ?", user.Username).Count(&count)
-	if count > 0 {
-		return ErrUsernameIsInUse
+	if user.Role == "" {
+		user.Role = models.RoleMember
 	}
 
-	// Check if no other account with the same email exists
-	gdb.db.Model(&models.User{}).Where("email = ?", user.Email).Count(&count)
-	if count > 0 {
-		return ErrEmailIsInUse
+	if err := hashPassword(user); err != nil {
+		return err
 	}
 
-	// Check if no other account with the same phone number exists
-	gdb.db.Model(&models.User{}).Where("phone_number = ?", user.PhoneNumber).Count(&count)
-	if count > 0 {
-		return ErrPhoneNumberIsInUse
+	return translateError(gdb.db.Create(user).Error)
+}
+
+func (gdb *GormDB) CreateBook(book *models.Book) error {
+
+	return gdb.db.Transaction(func(tx *gorm.DB) error {
+		return createBook(tx, book)
+	})
+}
+
+// CreateBooks creates the books in one transaction. Each book is created in a savepoint,
+// so a book that can not be created is left out without rolling back the others.
+func (gdb *GormDB) CreateBooks(books []*models.Book) ([]error, error) {
+
+	errs := make([]error, len(books))
+	err := gdb.db.Transaction(func(tx *gorm.DB) error {
+		for i, book := range books {
+			errs[i] = tx.Transaction(func(tx *gorm.DB) error {
+				return createBook(tx, book)
+			})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
 	}
 
-	if pw, err := bcrypt.GenerateFromPassword([]byte(user.Password), 4); err != nil {
+	return errs, nil
+}
+
+// createBook creates the book with its publisher, category, series, authors, tags and table of contents
+func createBook(tx *gorm.DB, book *models.Book) error {
+
+	book.Version = 1
+	if err := setBookISBN(book); err != nil {
+		return err
+	}
+	if err := setBookPublisher(tx, book); err != nil {
+		return err
+	}
+	if err := setBookCategory(tx, book); err != nil {
 		return err
-	} else {
-		user.Password = string(pw)
+	}
+	if err := setBookSeries(tx, book); err != nil {
+		return err
+	}
+	if err := tx.Omit(clause.Associations).Create(book).Error; err != nil {
+		return translateError(err)
 	}
 
-	result := gdb.db.Create(user)
-	return result.Error
-}
+	authors, err := setBookAuthors(tx, book.ID, book.Authors)
+	if err != nil {
+		return err
+	}
+	book.Authors = authors
 
-func (gdb *GormDB) CreateBook(book *models.Book) error {
+	tags, err := setBookTags(tx, book.ID, book.Tags)
+	if err != nil {
+		return err
+	}
+	book.Tags = tags
 
-	return gdb.db.Create(&book).Error
+	return createContents(tx, book.ID, nil, book.TableOfContents, 0)
 }
 
 func (gdb *GormDB) GetBook(id int) (*models.Book, error) {
 
 	var book models.Book
-	err := gdb.db.Where("id = ?", id).First(&book).Error
+	err := preloadBooks(gdb.db).Preload("TableOfContents").Where("id = ?", id).First(&book).Error
 
-	if err != nil {
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBookNotFound
+	} else if err != nil {
 		return nil, err
 	} else {
+		book.TableOfContents = models.ContentTree(book.TableOfContents)
 		return &book, nil
 	}
 
 }
 
-func (gdb *GormDB) DeleteBook(id uint) error {
+func (gdb *GormDB) DeleteBook(id uint, version uint) error {
+
+	return gdb.db.Transaction(func(tx *gorm.DB) error {
+		return deleteBook(tx, id, version)
+	})
+
+}
+
+// deleteBook deletes the book if it is still at version
+func deleteBook(tx *gorm.DB, id uint, version uint) error {
+
+	result := tx.Where("version = ?", version).Delete(&models.Book{}, id)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return versionMismatch(tx, id)
+	}
+
+	return nil
+}
+
+// versionMismatch returns the error of a conditional change of the book
+// that changed no rows: the book was deleted or is at another version
+func versionMismatch(tx *gorm.DB, id uint) error {
+
+	var count int64
+	if err := tx.Model(&models.Book{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		return err
+	}
+	if count == 0 {
+		return ErrBookNotFound
+	}
+
+	return ErrBookVersionMismatch
+}
+
+// DeleteUserBook deletes the book like DeleteBook if the user owns it
+func (gdb *GormDB) DeleteUserBook(username string, bookId uint, version uint) error {
+
+	return gdb.db.Transaction(func(tx *gorm.DB) error {
+
+		if err := lockUserBook(tx, username, bookId); err != nil {
+			return err
+		}
+
+		return deleteBook(tx, bookId, version)
+	})
 
-	return gdb.db.Delete(&models.Book{}, id).Error
 }
 
-func (gdb *GormDB) DeleteUserBook(username string, bookId uint) error {
+// lockUserBook locks the row of the book until the end of the transaction,
+// so the book can not change between the ownership check and the write.
+// It returns ErrPermissionDenied when the user does not own the book.
+func lockUserBook(tx *gorm.DB, username string, bookId uint) error {
 
 	// find the book
 	var book models.Book
-	result := gdb.db.Model(models.Book{}).Where("id = ?", bookId).Find(&book)
-	if result.RowsAffected == 0 {
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("id = ?", bookId).First(&book).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return ErrBookNotFound
-	} else if result.Error != nil {
-		return result.Error
+	} else if err != nil {
+		return err
 	}
 
 	// find the user who owns the book
 	var user models.User
-	result = gdb.db.Model(models.User{}).Where("username = ?", username).Find(&user)
-	if result.RowsAffected != 1 {
+	err = tx.Where("username = ?", username).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
 		return ErrUserNotFound
-	} else if result.Error != nil {
-		return result.Error
+	} else if err != nil {
+		return err
 	}
 
-	// delete the book if the user owns it
-	if user.ID == book.UserID {
-		return gdb.DeleteBook(bookId)
-	} else {
+	if user.ID != book.UserID {
 		return ErrPermissionDenied
 	}
+
+	return nil
 }
 
-func (gdb *GormDB) UpdateBook(id uint, name string, category string) error {
-	return gdb.db.Model(models.Book{}).Where("id = ?", id).Update("name", name).Update("category", category).Error
+// UpdateBook replaces every field of the book, its category, its series, its publisher,
+// its table of contents, its authors and its tags with those of book.
+// The owner of the book does not change.
+func (gdb *GormDB) UpdateBook(book *models.Book, version uint) error {
+
+	return gdb.db.Transaction(func(tx *gorm.DB) error {
+		return updateBook(tx, book, version)
+	})
+
 }
 
-func (gdb *GormDB) UpdateUserBook(username string, bookId uint, name string, category string) error {
-	// find the book
-	var book models.Book
-	result := gdb.db.Model(models.Book{}).Where("id = ?", bookId).Find(&book)
+// updateBook replaces the book, its category, its series, its publisher, its table of contents,
+// its authors and its tags if the book is still at version
+func updateBook(tx *gorm.DB, book *models.Book, version uint) (err error) {
+
+	// the transaction is rolled back on an error, so the book stays at version
+	defer func() {
+		if err != nil {
+			book.Version = version
+		}
+	}()
+
+	if err := setBookISBN(book); err != nil {
+		return err
+	}
+	if err := setBookPublisher(tx, book); err != nil {
+		return err
+	}
+	if err := setBookCategory(tx, book); err != nil {
+		return err
+	}
+	if err := setBookSeries(tx, book); err != nil {
+		return err
+	}
+
+	book.Version = version + 1
+	result := tx.Model(&models.Book{}).Where("id = ? AND version = ?", book.ID, version).
+		Select("*").Omit("id", "user_id", clause.Associations).Updates(book)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
 	if result.RowsAffected == 0 {
-		return ErrBookNotFound
-	} else if result.Error != nil {
-		return result.Error
+		return versionMismatch(tx, book.ID)
 	}
 
-	// find the user who owns the book
-	var user models.User
-	result = gdb.db.Model(models.User{}).Where("username = ?", username).Find(&user)
-	if result.RowsAffected != 1 {
-		return ErrUserNotFound
-	} else if result.Error != nil {
-		return result.Error
+	// replace the authors, the tags and the table of contents
+	if err := tx.Where("book_id = ?", book.ID).Delete(&models.BookAuthor{}).Error; err != nil {
+		return err
+	}
+	authors, err := setBookAuthors(tx, book.ID, book.Authors)
+	if err != nil {
+		return err
 	}
+	book.Authors = authors
 
-	// update the book if the user owns it
-	if user.ID == book.UserID {
-		return gdb.UpdateBook(bookId, name, category)
-	} else {
-		return ErrPermissionDenied
+	if err := tx.Where("book_id = ?", book.ID).Delete(&models.BookTag{}).Error; err != nil {
+		return err
+	}
+	tags, err := setBookTags(tx, book.ID, book.Tags)
+	if err != nil {
+		return err
+	}
+	book.Tags = tags
+
+	if err := tx.Where("book_id = ?", book.ID).Delete(&models.Content{}).Error; err != nil {
+		return err
 	}
+
+	return createContents(tx, book.ID, nil, book.TableOfContents, 0)
+}
+
+// UpdateUserBook replaces the book like UpdateBook if the user owns it
+func (gdb *GormDB) UpdateUserBook(username string, book *models.Book, version uint) error {
+
+	return gdb.db.Transaction(func(tx *gorm.DB) error {
+
+		if err := lockUserBook(tx, username, book.ID); err != nil {
+			return err
+		}
+
+		return updateBook(tx, book, version)
+	})
+
 }
 
 // The boolean returned is flase when there is an error
@@ -210,15 +417,265 @@ func (gdb *GormDB) GetUserByUsername(username string) (*models.User, error) {
 
 }
 
-func (gdb *GormDB) GetAllBooks() (*[]models.Book, error) {
+// When there is an error nil is return instead of a user
+func (gdb *GormDB) GetUserByID(id uint) (*models.User, error) {
+
+	var user models.User
+	err := gdb.db.Where("id = ?", id).First(&user).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrUserNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+
+}
+
+// GetAllUsers returns the users ordered by id
+func (gdb *GormDB) GetAllUsers() ([]models.User, error) {
+
+	users := []models.User{}
+	err := gdb.db.Model(models.User{}).Order("id").Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return users, nil
+
+}
+
+func (gdb *GormDB) UpdateUserRole(username string, role string) error {
+
+	result := gdb.db.Model(models.User{}).Where("username = ?", username).Update("role", role)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrUserNotFound
+	}
+
+	return nil
+
+}
+
+// DeleteUser deletes the user with its books and sessions
+func (gdb *GormDB) DeleteUser(username string) error {
+
+	return gdb.db.Transaction(func(tx *gorm.DB) error {
+
+		var user models.User
+		err := tx.Where("username = ?", username).First(&user).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUserNotFound
+		} else if err != nil {
+			return err
+		}
+
+		// the contents, the credits of the authors, the tags and the sessions are deleted by cascade
+		if err := tx.Where("user_id = ?", user.ID).Delete(&models.Book{}).Error; err != nil {
+			return err
+		}
+
+		return tx.Delete(&user).Error
+	})
+
+}
+
+// GetAllBooks returns a page of the books matching the query
+// and the number of matching books on all the pages
+func (gdb *GormDB) GetAllBooks(query BookQuery) (*[]models.Book, int64, error) {
+
+	if err := query.Validate(); err != nil {
+		return nil, 0, err
+	}
+
+	tx := query.filterBooks(gdb.db.Model(models.Book{})).Session(&gorm.Session{})
+
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	tx = query.orderBooks(tx)
+	if query.Limit > 0 {
+		tx = tx.Limit(query.Limit)
+	}
+	if query.Offset > 0 {
+		tx = tx.Offset(query.Offset)
+	}
+
+	tx = preloadBooks(tx)
+	if query.Contents {
+		tx = tx.Preload("TableOfContents")
+	}
+
+	books := []models.Book{}
+	err := tx.Find(&books).Error
+	if err != nil {
+		return nil, 0, err
+	}
+	if query.Contents {
+		for i := range books {
+			books[i].TableOfContents = models.ContentTree(books[i].TableOfContents)
+		}
+	}
+
+	return &books, total, nil
+
+}
+
+// SearchBooks returns the books best matching the search text.
+// Postgres ranks the books with its full-text search, the other
+// databases fall back to matching the words of the text.
+func (gdb *GormDB) SearchBooks(text string, limit int) ([]BookMatch, error) {
+
+	terms := searchTerms(text)
+	if len(terms) == 0 {
+		return nil, ErrEmptySearch
+	}
+
+	if gdb.db.Dialector.Name() == DriverPostgres {
+		return gdb.searchBooksPostgres(text, limit)
+	}
+
+	// find the books containing all the terms
+	tx := preloadBooks(gdb.db.Model(models.Book{})).Preload("TableOfContents")
+	for _, term := range terms {
+		pattern := "%" + escapeLike(term) + "%"
+		tx = tx.Where(`(LOWER(name) LIKE @p ESCAPE '\' OR LOWER(summary) LIKE @p ESCAPE '\'
+			OR EXISTS (SELECT 1 FROM publishers WHERE publishers.id = books.publisher_id AND LOWER(publishers.name) LIKE @p ESCAPE '\')
+			OR EXISTS (SELECT 1 FROM book_authors JOIN authors ON authors.id = book_authors.author_id
+				WHERE book_authors.book_id = books.id AND `+authorNameCondition+`)
+			OR EXISTS (SELECT 1 FROM contents WHERE contents.book_id = books.id AND LOWER(contents.content_name) LIKE @p ESCAPE '\'))`,
+			map[string]interface{}{"p": pattern})
+	}
 
 	var books []models.Book
+	if err := tx.Find(&books).Error; err != nil {
+		return nil, err
+	}
 
-	err := gdb.db.Model(models.Book{}).Find(&books).Error
+	// rank them
+	matches := make([]BookMatch, 0, len(books))
+	for i := range books {
+		if match, ok := matchBookText(&books[i], books[i].TableOfContents, terms); ok {
+			matches = append(matches, match)
+		}
+	}
+
+	return sortMatches(matches, limit), nil
+
+}
+
+// postgresSearchQuery ranks the books by the weighted tsvector of their fields
+// and highlights the matching fields with ts_headline
+const postgresSearchQuery = `
+SELECT books.id,
+	ts_rank(doc.vector, query) AS rank,
+	ts_headline('english', coalesce(books.name, ''), query, @short) AS name,
+	ts_headline('english', coalesce(books.summary, ''), query, @long) AS summary,
+	ts_headline('english', coalesce(publishers.name, ''), query, @short) AS publisher,
+	ts_headline('english', coalesce(credits.names, ''), query, @short) AS author,
+	ts_headline('english', coalesce(toc.names, ''), query, @long) AS table_of_contents
+FROM books
+	CROSS JOIN websearch_to_tsquery('english', @text) AS query
+	LEFT JOIN publishers ON publishers.id = books.publisher_id
+	LEFT JOIN LATERAL (
+		SELECT string_agg(contents.content_name, '; ' ORDER BY contents.position, contents.id) AS names
+		FROM contents
+		WHERE contents.book_id = books.id
+	) AS toc ON true
+	LEFT JOIN LATERAL (
+		SELECT string_agg(trim(authors.first_name || ' ' || authors.last_name), '; ' ORDER BY book_authors.position) AS names
+		FROM book_authors
+			JOIN authors ON authors.id = book_authors.author_id
+		WHERE book_authors.book_id = books.id
+	) AS credits ON true
+	CROSS JOIN LATERAL (
+		SELECT setweight(to_tsvector('english', coalesce(books.name, '')), 'A') ||
+			setweight(to_tsvector('english', coalesce(credits.names, '')), 'A') ||
+			setweight(to_tsvector('english', coalesce(books.summary, '')), 'B') ||
+			setweight(to_tsvector('english', coalesce(toc.names, '')), 'C') ||
+			setweight(to_tsvector('english', coalesce(publishers.name, '')), 'D') AS vector
+	) AS doc
+WHERE doc.vector @@ query
+ORDER BY rank DESC, books.id
+LIMIT @limit`
+
+type postgresSearchRow struct {
+	ID              uint
+	Rank            float64
+	Name            string
+	Summary         string
+	Publisher       string
+	Author          string
+	TableOfContents string
+}
+
+func (gdb *GormDB) searchBooksPostgres(text string, limit int) ([]BookMatch, error) {
+
+	if limit <= 0 {
+		limit = math.MaxInt32
+	}
+
+	var rows []postgresSearchRow
+	err := gdb.db.Raw(postgresSearchQuery, map[string]interface{}{
+		"text":  text,
+		"limit": limit,
+		"short": "StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", HighlightAll=true",
+		"long":  "StartSel=" + highlightStart + ", StopSel=" + highlightStop + ", MaxFragments=2, FragmentDelimiter=…",
+	}).Scan(&rows).Error
 	if err != nil {
 		return nil, err
 	}
 
-	return &books, nil
+	// load the matching books
+	ids := make([]uint, 0, len(rows))
+	for _, row := range rows {
+		ids = append(ids, row.ID)
+	}
+	var books []models.Book
+	if err := preloadBooks(gdb.db).Preload("TableOfContents").Where("id IN ?", ids).Find(&books).Error; err != nil {
+		return nil, err
+	}
+	byID := make(map[uint]models.Book, len(books))
+	for _, book := range books {
+		book.TableOfContents = models.ContentTree(book.TableOfContents)
+		byID[book.ID] = book
+	}
+
+	matches := make([]BookMatch, 0, len(rows))
+	for _, row := range rows {
+		match := BookMatch{Book: byID[row.ID], Rank: row.Rank, Highlights: make(map[string]string)}
+		for field, snippet := range map[string]string{
+			SearchFieldName:            row.Name,
+			SearchFieldSummary:         row.Summary,
+			SearchFieldPublisher:       row.Publisher,
+			SearchFieldAuthor:          row.Author,
+			SearchFieldTableOfContents: row.TableOfContents,
+		} {
+			// ts_headline returns the text even if nothing in it matched
+			if strings.Contains(snippet, highlightStart) {
+				match.Highlights[field] = snippet
+			}
+		}
+		matches = append(matches, match)
+	}
+
+	return matches, nil
+
+}
+
+// hashPassword replaces the plain password of the user with its bcrypt hash
+func hashPassword(user *models.User) error {
+
+	pw, err := bcrypt.GenerateFromPassword([]byte(user.Password), 4)
+	if err != nil {
+		return err
+	}
+
+	user.Password = string(pw)
+	return nil
 
 }
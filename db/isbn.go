@@ -0,0 +1,71 @@
+package db
+
+import (
+	"errors"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"github.com/Parsa-Sh-Y/book-manager-service/isbn"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrISBNIsInUse The user has another book with the same ISBN
+	ErrISBNIsInUse = errors.New("the user has another book with the same isbn")
+	// ErrISBNMismatch The ISBN-10 and the ISBN-13 of the book are not the same number
+	ErrISBNMismatch = errors.New("the isbn 10 and the isbn 13 of the book are not the same number")
+)
+
+// GetUserBookByISBN returns the book of the user with the ISBN, an ISBN-10 or an ISBN-13
+func (gdb *GormDB) GetUserBookByISBN(userID uint, number string) (*models.Book, error) {
+
+	isbn13, err := isbn.To13(number)
+	if err != nil {
+		return nil, ErrBookNotFound
+	}
+
+	var book models.Book
+	err = preloadBooks(gdb.db).Preload("TableOfContents").Where("user_id = ? AND isbn13 = ?", userID, isbn13).First(&book).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrBookNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	book.TableOfContents = models.ContentTree(book.TableOfContents)
+	return &book, nil
+
+}
+
+// setBookISBN removes the hyphens of the ISBNs of the book and derives the missing one
+// from the other. Books given both have to give the same number in the two forms.
+func setBookISBN(book *models.Book) error {
+
+	book.ISBN10, book.ISBN13 = isbn.Normalize(book.ISBN10), isbn.Normalize(book.ISBN13)
+	if book.ISBN10 == "" && book.ISBN13 == "" {
+		return nil
+	}
+
+	number := book.ISBN13
+	if number == "" {
+		number = book.ISBN10
+	}
+
+	isbn13, err := isbn.To13(number)
+	if err != nil {
+		return err
+	}
+	// the ISBN-13 starting with 979 are kept without an ISBN-10
+	isbn10, err := isbn.To10(number)
+	if errors.Is(err, isbn.ErrNoISBN10) {
+		isbn10 = ""
+	} else if err != nil {
+		return err
+	}
+
+	if book.ISBN10 != "" && book.ISBN10 != isbn10 {
+		return ErrISBNMismatch
+	}
+	book.ISBN10, book.ISBN13 = isbn10, isbn13
+
+	return nil
+}
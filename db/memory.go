@@ -0,0 +1,1680 @@
+package db
+
+import (
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"github.com/Parsa-Sh-Y/book-manager-service/isbn"
+)
+
+// MemoryDB is an in-memory Store. It keeps the same semantics as GormDB
+// and is meant for tests and for running the service without a database.
+type MemoryDB struct {
+	mu sync.RWMutex
+
+	users         map[uint]models.User
+	sessions      map[uint]models.Session
+	refreshTokens map[string]models.RefreshToken // by hash
+	books         map[uint]models.Book
+	contents      map[uint]models.Content
+	authors       map[uint]models.Author
+	bookAuthors   map[uint][]models.BookAuthor // by book id, without the authors
+	publishers    map[uint]models.Publisher
+	categories    map[uint]models.Category
+	series        map[uint]models.Series
+	bookTags      map[uint][]string // by book id, the names of the tags ordered by name
+
+	lastUserID         uint
+	lastSessionID      uint
+	lastRefreshTokenID uint
+	lastBookID         uint
+	lastContentID      uint
+	lastAuthorID       uint
+	lastPublisherID    uint
+	lastCategoryID     uint
+	lastSeriesID       uint
+}
+
+func CreateNewMemoryDB() *MemoryDB {
+
+	return &MemoryDB{
+		users:         make(map[uint]models.User),
+		sessions:      make(map[uint]models.Session),
+		refreshTokens: make(map[string]models.RefreshToken),
+		books:         make(map[uint]models.Book),
+		contents:      make(map[uint]models.Content),
+		authors:       make(map[uint]models.Author),
+		bookAuthors:   make(map[uint][]models.BookAuthor),
+		publishers:    make(map[uint]models.Publisher),
+		categories:    make(map[uint]models.Category),
+		series:        make(map[uint]models.Series),
+		bookTags:      make(map[uint][]string),
+	}
+
+}
+
+// CreateSchema does nothing, the maps are created by CreateNewMemoryDB
+func (mdb *MemoryDB) CreateSchema() error {
+	return nil
+}
+
+func (mdb *MemoryDB) CreateUser(user *models.User) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	// Check if no other account with the same username, email or phone number exists
+	for _, u := range mdb.users {
+		if u.Username == user.Username {
+			return ErrUsernameIsInUse
+		}
+	}
+	for _, u := range mdb.users {
+		if u.Email == user.Email {
+			return ErrEmailIsInUse
+		}
+	}
+	for _, u := range mdb.users {
+		// the phone number is optional, like in the unique index of GormDB
+		if user.PhoneNumber != "" && u.PhoneNumber == user.PhoneNumber {
+			return ErrPhoneNumberIsInUse
+		}
+	}
+
+	if user.Role == "" {
+		user.Role = models.RoleMember
+	}
+
+	if err := hashPassword(user); err != nil {
+		return err
+	}
+
+	mdb.lastUserID++
+	user.ID = mdb.lastUserID
+
+	stored := *user
+	stored.Books = nil
+	mdb.users[user.ID] = stored
+
+	return nil
+}
+
+func (mdb *MemoryDB) CreateSession(session *models.Session, refreshTokenHash string) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	now := time.Now()
+	mdb.lastSessionID++
+	session.ID = mdb.lastSessionID
+	session.CreatedAt = now
+
+	token := mdb.addRefreshToken(session.ID, refreshTokenHash, now)
+	session.RefreshTokens = []models.RefreshToken{token}
+
+	stored := *session
+	stored.RefreshTokens = nil
+	mdb.sessions[session.ID] = stored
+
+	return nil
+}
+
+// addRefreshToken stores a new refresh token of the session.
+// The caller must hold the write lock.
+func (mdb *MemoryDB) addRefreshToken(sessionID uint, tokenHash string, now time.Time) models.RefreshToken {
+
+	mdb.lastRefreshTokenID++
+	token := models.RefreshToken{ID: mdb.lastRefreshTokenID, SessionID: sessionID, TokenHash: tokenHash, CreatedAt: now}
+	mdb.refreshTokens[tokenHash] = token
+
+	return token
+}
+
+func (mdb *MemoryDB) GetSession(id uint) (*models.Session, error) {
+
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	session, ok := mdb.sessions[id]
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+
+	return &session, nil
+}
+
+func (mdb *MemoryDB) RotateRefreshToken(tokenHash string, newTokenHash string) (*models.Session, error) {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	token, ok := mdb.refreshTokens[tokenHash]
+	if !ok {
+		return nil, ErrRefreshTokenNotFound
+	}
+	session := mdb.sessions[token.SessionID]
+
+	now := time.Now()
+	if session.RevokedAt != nil || now.After(session.ExpiresAt) {
+		return nil, ErrSessionRevoked
+	}
+
+	// a used token is presented again, revoke the session
+	if token.UsedAt != nil {
+		session.RevokedAt = &now
+		mdb.sessions[session.ID] = session
+		return nil, ErrRefreshTokenReused
+	}
+
+	token.UsedAt = &now
+	mdb.refreshTokens[tokenHash] = token
+	mdb.addRefreshToken(session.ID, newTokenHash, now)
+
+	return &session, nil
+}
+
+func (mdb *MemoryDB) RevokeSession(id uint) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	session, ok := mdb.sessions[id]
+	if !ok {
+		return ErrSessionNotFound
+	}
+
+	if session.RevokedAt == nil {
+		now := time.Now()
+		session.RevokedAt = &now
+		mdb.sessions[id] = session
+	}
+
+	return nil
+}
+
+func (mdb *MemoryDB) CreateBook(book *models.Book) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	if err := setBookISBN(book); err != nil {
+		return err
+	}
+	if mdb.isbnInUse(book.UserID, book.ISBN13, 0) {
+		return ErrISBNIsInUse
+	}
+	if err := mdb.checkAuthors(book.Authors); err != nil {
+		return err
+	}
+	if err := mdb.checkPublisher(book.Publisher); err != nil {
+		return err
+	}
+	if err := mdb.checkSeries(book.Series); err != nil {
+		return err
+	}
+	if err := mdb.setBookCategory(book); err != nil {
+		return err
+	}
+
+	mdb.lastBookID++
+	book.ID = mdb.lastBookID
+	book.Version = 1
+
+	book.Authors = mdb.setBookAuthors(book.ID, book.Authors)
+	book.Tags = mdb.setBookTags(book.ID, book.Tags)
+	mdb.setBookPublisher(book)
+	mdb.setBookSeries(book)
+	mdb.createContents(book.ID, nil, book.TableOfContents, 0)
+
+	// contents, authors, tags, publishers, categories and series are kept apart from the book, like their tables
+	stored := *book
+	stored.TableOfContents = nil
+	stored.Authors = nil
+	stored.Tags = nil
+	stored.Publisher = nil
+	stored.Category = nil
+	stored.Series = nil
+	mdb.books[book.ID] = stored
+
+	return nil
+}
+
+func (mdb *MemoryDB) CreateBooks(books []*models.Book) ([]error, error) {
+
+	errs := make([]error, len(books))
+	for i, book := range books {
+		errs[i] = mdb.CreateBook(book)
+	}
+
+	return errs, nil
+}
+
+func (mdb *MemoryDB) GetBook(id int) (*models.Book, error) {
+
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	book, ok := mdb.books[uint(id)]
+	if !ok {
+		return nil, ErrBookNotFound
+	}
+	book.TableOfContents = models.ContentTree(mdb.bookContents(book.ID))
+	mdb.loadBook(&book)
+
+	return &book, nil
+}
+
+func (mdb *MemoryDB) GetUserBookByISBN(userID uint, number string) (*models.Book, error) {
+
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	isbn13, err := isbn.To13(number)
+	if err != nil {
+		return nil, ErrBookNotFound
+	}
+
+	for _, book := range mdb.books {
+		if book.UserID == userID && book.ISBN13 == isbn13 {
+			book.TableOfContents = models.ContentTree(mdb.bookContents(book.ID))
+			mdb.loadBook(&book)
+			return &book, nil
+		}
+	}
+
+	return nil, ErrBookNotFound
+}
+
+// isbnInUse reports whether the user has a book other than exceptID with the ISBN-13.
+// The caller must hold the lock.
+func (mdb *MemoryDB) isbnInUse(userID uint, isbn13 string, exceptID uint) bool {
+
+	if isbn13 == "" {
+		return false
+	}
+
+	for _, book := range mdb.books {
+		if book.ID != exceptID && book.UserID == userID && book.ISBN13 == isbn13 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (mdb *MemoryDB) DeleteBook(id uint, version uint) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	book, ok := mdb.books[id]
+	if !ok {
+		return ErrBookNotFound
+	}
+	if book.Version != version {
+		return ErrBookVersionMismatch
+	}
+
+	mdb.deleteBook(id)
+	return nil
+}
+
+// deleteBook removes the book and cascades to its contents, the credits of its authors and its tags.
+// The caller must hold the write lock.
+func (mdb *MemoryDB) deleteBook(id uint) {
+
+	delete(mdb.books, id)
+	delete(mdb.bookAuthors, id)
+	delete(mdb.bookTags, id)
+	for contentID, content := range mdb.contents {
+		if content.BookId == id {
+			delete(mdb.contents, contentID)
+		}
+	}
+
+}
+
+func (mdb *MemoryDB) DeleteUserBook(username string, bookId uint, version uint) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	book, ok := mdb.books[bookId]
+	if !ok {
+		return ErrBookNotFound
+	}
+
+	user, err := mdb.findUser(username)
+	if err != nil {
+		return err
+	}
+
+	// delete the book if the user owns it
+	if user.ID != book.UserID {
+		return ErrPermissionDenied
+	} else if book.Version != version {
+		return ErrBookVersionMismatch
+	} else {
+		mdb.deleteBook(bookId)
+		return nil
+	}
+}
+
+func (mdb *MemoryDB) UpdateBook(book *models.Book, version uint) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	stored, ok := mdb.books[book.ID]
+	if !ok {
+		return ErrBookNotFound
+	}
+	if stored.Version != version {
+		return ErrBookVersionMismatch
+	}
+
+	return mdb.updateBook(book)
+}
+
+// updateBook replaces the fields, the contents, the authors, the tags, the publisher, the category
+// and the series of the book, keeping its owner. The caller must hold the write lock.
+func (mdb *MemoryDB) updateBook(book *models.Book) error {
+
+	stored, ok := mdb.books[book.ID]
+	if !ok {
+		return ErrBookNotFound
+	}
+	if err := setBookISBN(book); err != nil {
+		return err
+	}
+	if mdb.isbnInUse(stored.UserID, book.ISBN13, book.ID) {
+		return ErrISBNIsInUse
+	}
+	if err := mdb.checkAuthors(book.Authors); err != nil {
+		return err
+	}
+	if err := mdb.checkPublisher(book.Publisher); err != nil {
+		return err
+	}
+	if err := mdb.checkSeries(book.Series); err != nil {
+		return err
+	}
+	if err := mdb.setBookCategory(book); err != nil {
+		return err
+	}
+
+	book.Authors = mdb.setBookAuthors(book.ID, book.Authors)
+	book.Tags = mdb.setBookTags(book.ID, book.Tags)
+	mdb.setBookPublisher(book)
+	mdb.setBookSeries(book)
+
+	for contentID, content := range mdb.contents {
+		if content.BookId == book.ID {
+			delete(mdb.contents, contentID)
+		}
+	}
+	mdb.createContents(book.ID, nil, book.TableOfContents, 0)
+
+	book.Version = stored.Version + 1
+	replaced := *book
+	replaced.UserID = stored.UserID
+	replaced.TableOfContents = nil
+	replaced.Authors = nil
+	replaced.Tags = nil
+	replaced.Publisher = nil
+	replaced.Category = nil
+	replaced.Series = nil
+	mdb.books[book.ID] = replaced
+
+	return nil
+}
+
+func (mdb *MemoryDB) UpdateUserBook(username string, book *models.Book, version uint) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	stored, ok := mdb.books[book.ID]
+	if !ok {
+		return ErrBookNotFound
+	}
+
+	user, err := mdb.findUser(username)
+	if err != nil {
+		return err
+	}
+
+	// update the book if the user owns it
+	if user.ID != stored.UserID {
+		return ErrPermissionDenied
+	} else if stored.Version != version {
+		return ErrBookVersionMismatch
+	} else {
+		return mdb.updateBook(book)
+	}
+}
+
+func (mdb *MemoryDB) IsUsernamePresent(username string) (bool, error) {
+
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	_, err := mdb.findUser(username)
+	return err == nil, nil
+}
+
+func (mdb *MemoryDB) GetUserByUsername(username string) (*models.User, error) {
+
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	user, err := mdb.findUser(username)
+	if err != nil {
+		return nil, err
+	}
+
+	return &user, nil
+}
+
+func (mdb *MemoryDB) GetUserByID(id uint) (*models.User, error) {
+
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	user, ok := mdb.users[id]
+	if !ok {
+		return nil, ErrUserNotFound
+	}
+
+	return &user, nil
+}
+
+func (mdb *MemoryDB) GetAllUsers() ([]models.User, error) {
+
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	users := make([]models.User, 0, len(mdb.users))
+	for _, user := range mdb.users {
+		users = append(users, user)
+	}
+
+	sort.Slice(users, func(i, j int) bool { return users[i].ID < users[j].ID })
+	return users, nil
+}
+
+func (mdb *MemoryDB) UpdateUserRole(username string, role string) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	user, err := mdb.findUser(username)
+	if err != nil {
+		return err
+	}
+
+	user.Role = role
+	mdb.users[user.ID] = user
+
+	return nil
+}
+
+func (mdb *MemoryDB) DeleteUser(username string) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	user, err := mdb.findUser(username)
+	if err != nil {
+		return err
+	}
+
+	// cascade to the books and the sessions of the user
+	for id, book := range mdb.books {
+		if book.UserID == user.ID {
+			mdb.deleteBook(id)
+		}
+	}
+	for id, session := range mdb.sessions {
+		if session.UserID == user.ID {
+			delete(mdb.sessions, id)
+		}
+	}
+	for hash, token := range mdb.refreshTokens {
+		if _, ok := mdb.sessions[token.SessionID]; !ok {
+			delete(mdb.refreshTokens, hash)
+		}
+	}
+
+	delete(mdb.users, user.ID)
+	return nil
+}
+
+// findUser looks up a user by username.
+// The caller must hold the lock.
+func (mdb *MemoryDB) findUser(username string) (models.User, error) {
+
+	for _, user := range mdb.users {
+		if user.Username == username {
+			return user, nil
+		}
+	}
+
+	return models.User{}, ErrUserNotFound
+}
+
+func (mdb *MemoryDB) GetAllBooks(query BookQuery) (*[]models.Book, int64, error) {
+
+	if err := query.Validate(); err != nil {
+		return nil, 0, err
+	}
+
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	categories := mdb.queryCategories(&query)
+	books := []models.Book{}
+	for _, book := range mdb.books {
+		mdb.loadBook(&book)
+		if query.matchBook(&book, categories) {
+			books = append(books, book)
+		}
+	}
+
+	sort.Slice(books, func(i, j int) bool { return query.lessBook(&books[i], &books[j]) })
+
+	total := int64(len(books))
+	books = paginate(books, query.Limit, query.Offset)
+	if query.Contents {
+		for i := range books {
+			books[i].TableOfContents = models.ContentTree(mdb.bookContents(books[i].ID))
+		}
+	}
+
+	return &books, total, nil
+}
+
+func (mdb *MemoryDB) SearchBooks(text string, limit int) ([]BookMatch, error) {
+
+	terms := searchTerms(text)
+	if len(terms) == 0 {
+		return nil, ErrEmptySearch
+	}
+
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	var matches []BookMatch
+	for _, book := range mdb.books {
+		mdb.loadBook(&book)
+		if match, ok := matchBookText(&book, mdb.bookContents(book.ID), terms); ok {
+			matches = append(matches, match)
+		}
+	}
+
+	return sortMatches(matches, limit), nil
+}
+
+func (mdb *MemoryDB) GetContents(bookID uint) ([]models.Content, error) {
+
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	if _, ok := mdb.books[bookID]; !ok {
+		return nil, ErrBookNotFound
+	}
+
+	return models.ContentTree(mdb.bookContents(bookID)), nil
+}
+
+func (mdb *MemoryDB) CreateContent(content *models.Content, version uint) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	if err := mdb.checkVersion(content.BookId, version); err != nil {
+		return err
+	}
+	if content.ParentID != nil {
+		if err := validContentParent(mdb.bookContents(content.BookId), content.ParentID, 0); err != nil {
+			return err
+		}
+	}
+
+	// make room for the entry
+	position := mdb.insertPosition(content.BookId, content.ParentID, content.Position, 0)
+	mdb.shiftContents(content.BookId, content.ParentID, position, 1, 0)
+
+	entries := []models.Content{*content}
+	mdb.createContents(content.BookId, content.ParentID, entries, position)
+	mdb.bumpVersion(content.BookId)
+
+	*content = entries[0]
+	return nil
+}
+
+func (mdb *MemoryDB) UpdateContent(content *models.Content, version uint) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	if err := mdb.checkVersion(content.BookId, version); err != nil {
+		return err
+	}
+
+	stored, ok := mdb.contents[content.ID]
+	if !ok || stored.BookId != content.BookId {
+		return ErrContentNotFound
+	}
+	if content.ParentID != nil {
+		if err := validContentParent(mdb.bookContents(content.BookId), content.ParentID, content.ID); err != nil {
+			return err
+		}
+	}
+
+	if sameParent(stored.ParentID, content.ParentID) && content.Position < 0 {
+		content.Position = stored.Position
+	} else {
+		// take the entry out of its siblings and make room among the new ones
+		mdb.shiftContents(stored.BookId, stored.ParentID, stored.Position+1, -1, stored.ID)
+		content.Position = mdb.insertPosition(content.BookId, content.ParentID, content.Position, content.ID)
+		mdb.shiftContents(content.BookId, content.ParentID, content.Position, 1, content.ID)
+	}
+
+	stored.ContentName = content.ContentName
+	stored.Kind = content.Kind
+	stored.Page = content.Page
+	stored.ParentID = content.ParentID
+	stored.Position = content.Position
+	mdb.contents[stored.ID] = stored
+	mdb.bumpVersion(content.BookId)
+
+	return nil
+}
+
+func (mdb *MemoryDB) DeleteContent(bookID uint, contentID uint, version uint) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	if err := mdb.checkVersion(bookID, version); err != nil {
+		return err
+	}
+
+	stored, ok := mdb.contents[contentID]
+	if !ok || stored.BookId != bookID {
+		return ErrContentNotFound
+	}
+
+	// delete the entry and cascade to the entries nested in it
+	deleted := map[uint]bool{contentID: true}
+	for changed := true; changed; {
+		changed = false
+		for id, content := range mdb.contents {
+			if !deleted[id] && content.ParentID != nil && deleted[*content.ParentID] {
+				deleted[id] = true
+				changed = true
+			}
+		}
+	}
+	for id := range deleted {
+		delete(mdb.contents, id)
+	}
+
+	mdb.shiftContents(bookID, stored.ParentID, stored.Position+1, -1, 0)
+	mdb.bumpVersion(bookID)
+
+	return nil
+}
+
+// checkVersion returns the error of changing the book at version.
+// The caller must hold the lock.
+func (mdb *MemoryDB) checkVersion(bookID uint, version uint) error {
+
+	book, ok := mdb.books[bookID]
+	if !ok {
+		return ErrBookNotFound
+	}
+	if book.Version != version {
+		return ErrBookVersionMismatch
+	}
+
+	return nil
+}
+
+// bumpVersion increments the version of the book.
+// The caller must hold the write lock.
+func (mdb *MemoryDB) bumpVersion(bookID uint) {
+
+	book := mdb.books[bookID]
+	book.Version++
+	mdb.books[bookID] = book
+
+}
+
+// createContents saves the tree of entries under parentID, numbering them from position.
+// The caller must hold the write lock.
+func (mdb *MemoryDB) createContents(bookID uint, parentID *uint, entries []models.Content, position int) {
+
+	for i := range entries {
+		mdb.lastContentID++
+		entries[i].ID = mdb.lastContentID
+		entries[i].BookId = bookID
+		entries[i].ParentID = parentID
+		entries[i].Position = position + i
+
+		id := entries[i].ID
+		mdb.createContents(bookID, &id, entries[i].Children, 0)
+
+		stored := entries[i]
+		stored.Children = nil
+		mdb.contents[stored.ID] = stored
+	}
+
+}
+
+// insertPosition returns where to insert an entry among the entries with the parent,
+// leaving out the entry with id except. The caller must hold the lock.
+func (mdb *MemoryDB) insertPosition(bookID uint, parentID *uint, position int, except uint) int {
+
+	count := 0
+	for _, content := range mdb.contents {
+		if content.BookId == bookID && sameParent(content.ParentID, parentID) && content.ID != except {
+			count++
+		}
+	}
+	if position < 0 || position > count {
+		return count
+	}
+
+	return position
+}
+
+// shiftContents adds delta to the positions of the entries with the parent at or after from,
+// leaving out the entry with id except. The caller must hold the write lock.
+func (mdb *MemoryDB) shiftContents(bookID uint, parentID *uint, from int, delta int, except uint) {
+
+	for id, content := range mdb.contents {
+		if content.BookId == bookID && sameParent(content.ParentID, parentID) && content.Position >= from && id != except {
+			content.Position += delta
+			mdb.contents[id] = content
+		}
+	}
+
+}
+
+// bookContents returns the contents of the book ordered by id.
+// The caller must hold the lock.
+func (mdb *MemoryDB) bookContents(bookID uint) []models.Content {
+
+	var contents []models.Content
+	for _, content := range mdb.contents {
+		if content.BookId == bookID {
+			contents = append(contents, content)
+		}
+	}
+
+	sort.Slice(contents, func(i, j int) bool { return contents[i].ID < contents[j].ID })
+	return contents
+}
+
+func (mdb *MemoryDB) CreateAuthor(author *models.Author) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	mdb.createAuthor(author)
+	return nil
+}
+
+// createAuthor stores the author with a new id.
+// The caller must hold the write lock.
+func (mdb *MemoryDB) createAuthor(author *models.Author) {
+
+	normalizeAuthor(author)
+	mdb.lastAuthorID++
+	author.ID = mdb.lastAuthorID
+	mdb.authors[author.ID] = *author
+
+}
+
+func (mdb *MemoryDB) GetAuthor(id uint) (*models.Author, error) {
+
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	author, ok := mdb.authors[id]
+	if !ok {
+		return nil, ErrAuthorNotFound
+	}
+
+	return &author, nil
+}
+
+func (mdb *MemoryDB) GetAllAuthors(query AuthorQuery) ([]models.Author, int64, error) {
+
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	part := strings.ToLower(query.Name)
+	authors := []models.Author{}
+	for _, author := range mdb.authors {
+		if query.Name == "" || matchAuthorName(&author, part) {
+			authors = append(authors, author)
+		}
+	}
+
+	sort.Slice(authors, func(i, j int) bool {
+		if authors[i].LastName != authors[j].LastName {
+			return authors[i].LastName < authors[j].LastName
+		}
+		if authors[i].FirstName != authors[j].FirstName {
+			return authors[i].FirstName < authors[j].FirstName
+		}
+		return authors[i].ID < authors[j].ID
+	})
+
+	total := int64(len(authors))
+	return paginate(authors, query.Limit, query.Offset), total, nil
+}
+
+func (mdb *MemoryDB) UpdateAuthor(author *models.Author) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	if _, ok := mdb.authors[author.ID]; !ok {
+		return ErrAuthorNotFound
+	}
+
+	normalizeAuthor(author)
+	mdb.authors[author.ID] = *author
+
+	return nil
+}
+
+func (mdb *MemoryDB) DeleteAuthor(id uint) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	if _, ok := mdb.authors[id]; !ok {
+		return ErrAuthorNotFound
+	}
+	for _, credits := range mdb.bookAuthors {
+		for _, link := range credits {
+			if link.AuthorID == id {
+				return ErrAuthorHasBooks
+			}
+		}
+	}
+
+	delete(mdb.authors, id)
+	return nil
+}
+
+// checkAuthors returns ErrUnknownAuthor if an author with an id does not exist.
+// The caller must hold the lock.
+func (mdb *MemoryDB) checkAuthors(authors []models.BookAuthor) error {
+
+	for _, link := range authors {
+		if _, ok := mdb.authors[link.Author.ID]; link.Author.ID != 0 && !ok {
+			return ErrUnknownAuthor
+		}
+	}
+
+	return nil
+}
+
+// setBookAuthors replaces the credits of the book like setBookAuthors of GormDB
+// and returns them. The caller must hold the write lock and check the authors first.
+func (mdb *MemoryDB) setBookAuthors(bookID uint, authors []models.BookAuthor) []models.BookAuthor {
+
+	credited := make([]models.BookAuthor, 0, len(authors))
+	for _, link := range authors {
+		link.Author = mdb.findOrCreateAuthor(link.Author)
+		link.BookID = bookID
+		link.AuthorID = link.Author.ID
+		if link.Role == "" {
+			link.Role = models.AuthorRoleAuthor
+		}
+
+		duplicate := false
+		for _, other := range credited {
+			duplicate = duplicate || (other.AuthorID == link.AuthorID && other.Role == link.Role)
+		}
+		if duplicate {
+			continue
+		}
+
+		link.Position = len(credited)
+		credited = append(credited, link)
+	}
+
+	stored := make([]models.BookAuthor, len(credited))
+	for i, link := range credited {
+		link.Author = models.Author{}
+		stored[i] = link
+	}
+	mdb.bookAuthors[bookID] = stored
+
+	return credited
+}
+
+// findOrCreateAuthor returns the stored author with the id of author or,
+// without an id, with its name. The caller must hold the write lock.
+func (mdb *MemoryDB) findOrCreateAuthor(author models.Author) models.Author {
+
+	if author.ID != 0 {
+		return mdb.authors[author.ID]
+	}
+
+	normalizeAuthor(&author)
+	var found *models.Author
+	for id, stored := range mdb.authors {
+		if sameAuthorName(&stored, &author) && (found == nil || id < found.ID) {
+			stored := stored
+			found = &stored
+		}
+	}
+	if found != nil {
+		return *found
+	}
+
+	mdb.createAuthor(&author)
+	return author
+}
+
+func (mdb *MemoryDB) CreatePublisher(publisher *models.Publisher) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	normalizePublisher(publisher)
+	if mdb.publisherNameInUse(publisher.Name, 0) {
+		return ErrPublisherNameIsInUse
+	}
+
+	mdb.lastPublisherID++
+	publisher.ID = mdb.lastPublisherID
+	mdb.publishers[publisher.ID] = *publisher
+
+	return nil
+}
+
+func (mdb *MemoryDB) GetPublisher(id uint) (*models.Publisher, error) {
+
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	publisher, ok := mdb.publishers[id]
+	if !ok {
+		return nil, ErrPublisherNotFound
+	}
+
+	return &publisher, nil
+}
+
+func (mdb *MemoryDB) GetAllPublishers(query PublisherQuery) ([]models.Publisher, int64, error) {
+
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	publishers := []models.Publisher{}
+	for _, publisher := range mdb.publishers {
+		if query.matchPublisher(&publisher) {
+			publishers = append(publishers, publisher)
+		}
+	}
+
+	sort.Slice(publishers, func(i, j int) bool {
+		if publishers[i].Name != publishers[j].Name {
+			return publishers[i].Name < publishers[j].Name
+		}
+		return publishers[i].ID < publishers[j].ID
+	})
+
+	total := int64(len(publishers))
+	return paginate(publishers, query.Limit, query.Offset), total, nil
+}
+
+func (mdb *MemoryDB) UpdatePublisher(publisher *models.Publisher) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	if _, ok := mdb.publishers[publisher.ID]; !ok {
+		return ErrPublisherNotFound
+	}
+
+	normalizePublisher(publisher)
+	if mdb.publisherNameInUse(publisher.Name, publisher.ID) {
+		return ErrPublisherNameIsInUse
+	}
+	mdb.publishers[publisher.ID] = *publisher
+
+	return nil
+}
+
+func (mdb *MemoryDB) DeletePublisher(id uint) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	if _, ok := mdb.publishers[id]; !ok {
+		return ErrPublisherNotFound
+	}
+	for _, book := range mdb.books {
+		if book.PublisherID != nil && *book.PublisherID == id {
+			return ErrPublisherHasBooks
+		}
+	}
+
+	delete(mdb.publishers, id)
+	return nil
+}
+
+// publisherNameInUse reports whether a publisher other than except has the name, ignoring case.
+// The caller must hold the lock.
+func (mdb *MemoryDB) publisherNameInUse(name string, except uint) bool {
+
+	for id, publisher := range mdb.publishers {
+		if id != except && strings.EqualFold(publisher.Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkPublisher returns ErrUnknownPublisher if the publisher has an id that does not exist.
+// The caller must hold the lock.
+func (mdb *MemoryDB) checkPublisher(publisher *models.Publisher) error {
+
+	if publisher == nil || publisher.ID == 0 {
+		return nil
+	}
+	if _, ok := mdb.publishers[publisher.ID]; !ok {
+		return ErrUnknownPublisher
+	}
+
+	return nil
+}
+
+// setBookPublisher links the book to its publisher like setBookPublisher of GormDB.
+// The caller must hold the write lock and check the publisher first.
+func (mdb *MemoryDB) setBookPublisher(book *models.Book) {
+
+	book.PublisherID = nil
+	if book.Publisher == nil {
+		return
+	}
+
+	publisher := *book.Publisher
+	if publisher.ID == 0 {
+		normalizePublisher(&publisher)
+		for id, stored := range mdb.publishers {
+			if strings.EqualFold(stored.Name, publisher.Name) {
+				publisher.ID = id
+			}
+		}
+		if publisher.ID == 0 {
+			mdb.lastPublisherID++
+			publisher.ID = mdb.lastPublisherID
+			mdb.publishers[publisher.ID] = publisher
+		}
+	}
+
+	publisher = mdb.publishers[publisher.ID]
+	book.Publisher = &publisher
+	book.PublisherID = &publisher.ID
+
+}
+
+// loadBook sets the authors, the tags, the publisher, the category and the series of the book.
+// The caller must hold the lock.
+func (mdb *MemoryDB) loadBook(book *models.Book) {
+
+	book.Authors = mdb.bookCredits(book.ID)
+	book.Publisher = nil
+	if book.PublisherID != nil {
+		publisher := mdb.publishers[*book.PublisherID]
+		book.Publisher = &publisher
+	}
+	book.Category = nil
+	if book.CategoryID != nil {
+		category := mdb.categories[*book.CategoryID]
+		book.Category = &category
+	}
+	book.Series = nil
+	if book.SeriesID != nil {
+		series := mdb.series[*book.SeriesID]
+		book.Series = &series
+	}
+	book.Tags = make([]models.Tag, 0, len(mdb.bookTags[book.ID]))
+	for _, name := range mdb.bookTags[book.ID] {
+		book.Tags = append(book.Tags, models.Tag{Name: name})
+	}
+
+}
+
+// bookCredits returns the credits of the book ordered by position, with their authors.
+// The caller must hold the lock.
+func (mdb *MemoryDB) bookCredits(bookID uint) []models.BookAuthor {
+
+	credits := make([]models.BookAuthor, 0, len(mdb.bookAuthors[bookID]))
+	for _, link := range mdb.bookAuthors[bookID] {
+		link.Author = mdb.authors[link.AuthorID]
+		credits = append(credits, link)
+	}
+
+	return credits
+}
+
+func (mdb *MemoryDB) CreateCategory(category *models.Category) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	category.Children = nil
+	category.Name = normalizeName(category.Name)
+	if category.ParentID != nil {
+		if _, ok := mdb.categories[*category.ParentID]; !ok {
+			return ErrInvalidCategoryParent
+		}
+	}
+	if mdb.categoryNameInUse(category.Name, category.ParentID, 0) {
+		return ErrCategoryNameIsInUse
+	}
+
+	mdb.lastCategoryID++
+	category.ID = mdb.lastCategoryID
+	mdb.categories[category.ID] = *category
+
+	return nil
+}
+
+func (mdb *MemoryDB) GetCategory(id uint) (*models.Category, error) {
+
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	return categoryWithChildren(mdb.categoryList(), id)
+}
+
+func (mdb *MemoryDB) GetCategoryTree() ([]models.Category, error) {
+
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	tree := models.CategoryTree(mdb.categoryList())
+	if tree == nil {
+		tree = []models.Category{}
+	}
+
+	return tree, nil
+}
+
+func (mdb *MemoryDB) UpdateCategory(category *models.Category) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	category.Children = nil
+	category.Name = normalizeName(category.Name)
+	if err := validCategoryParent(mdb.categoryList(), category.ID, category.ParentID); err != nil {
+		return err
+	}
+	if mdb.categoryNameInUse(category.Name, category.ParentID, category.ID) {
+		return ErrCategoryNameIsInUse
+	}
+	mdb.categories[category.ID] = *category
+
+	return nil
+}
+
+func (mdb *MemoryDB) DeleteCategory(id uint, moveTo uint) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	categories := mdb.categoryList()
+	if !hasCategory(categories, id) {
+		return ErrCategoryNotFound
+	}
+
+	var children []uint
+	for childID, category := range mdb.categories {
+		if category.ParentID != nil && *category.ParentID == id {
+			children = append(children, childID)
+		}
+	}
+	var books []uint
+	for bookID, book := range mdb.books {
+		if book.CategoryID != nil && *book.CategoryID == id {
+			books = append(books, bookID)
+		}
+	}
+
+	if moveTo == 0 {
+		if len(books) > 0 || len(children) > 0 {
+			return ErrCategoryNotEmpty
+		}
+		delete(mdb.categories, id)
+		return nil
+	}
+
+	if err := validCategoryParent(categories, id, &moveTo); err != nil {
+		return err
+	}
+	for _, childID := range children {
+		if mdb.categoryNameInUse(mdb.categories[childID].Name, &moveTo, childID) {
+			return ErrCategoryNameIsInUse
+		}
+	}
+
+	for _, childID := range children {
+		child := mdb.categories[childID]
+		parentID := moveTo
+		child.ParentID = &parentID
+		mdb.categories[childID] = child
+	}
+	for _, bookID := range books {
+		book := mdb.books[bookID]
+		categoryID := moveTo
+		book.CategoryID = &categoryID
+		book.Version++
+		mdb.books[bookID] = book
+	}
+
+	delete(mdb.categories, id)
+	return nil
+}
+
+// categoryList returns the categories ordered by id.
+// The caller must hold the lock.
+func (mdb *MemoryDB) categoryList() []models.Category {
+
+	categories := make([]models.Category, 0, len(mdb.categories))
+	for _, category := range mdb.categories {
+		categories = append(categories, category)
+	}
+
+	sort.Slice(categories, func(i, j int) bool { return categories[i].ID < categories[j].ID })
+	return categories
+}
+
+// categoryNameInUse reports whether a category other than except has the name and the parent,
+// ignoring case. The caller must hold the lock.
+func (mdb *MemoryDB) categoryNameInUse(name string, parentID *uint, except uint) bool {
+
+	for id, category := range mdb.categories {
+		if id != except && sameParent(category.ParentID, parentID) && strings.EqualFold(category.Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// setBookCategory links the book to its category like setBookCategory of GormDB.
+// The caller must hold the lock.
+func (mdb *MemoryDB) setBookCategory(book *models.Book) error {
+
+	book.CategoryID = nil
+	if book.Category == nil {
+		return nil
+	}
+
+	var found []models.Category
+	for _, category := range mdb.categoryList() {
+		if book.Category.ID != 0 && category.ID == book.Category.ID ||
+			book.Category.ID == 0 && strings.EqualFold(category.Name, normalizeName(book.Category.Name)) {
+			found = append(found, category)
+		}
+	}
+	if len(found) != 1 {
+		return ErrUnknownCategory
+	}
+
+	book.Category = &found[0]
+	book.CategoryID = &found[0].ID
+	return nil
+}
+
+// queryCategories returns the ids of the categories matched by the category filters
+// of the query, nil when it has none. The caller must hold the lock.
+func (mdb *MemoryDB) queryCategories(query *BookQuery) map[uint]bool {
+
+	if query.Category == "" && query.CategoryID == 0 {
+		return nil
+	}
+
+	categories := mdb.categoryList()
+	var matched map[uint]bool
+	if query.Category != "" {
+		var roots []uint
+		for _, category := range categories {
+			if strings.EqualFold(category.Name, normalizeName(query.Category)) {
+				roots = append(roots, category.ID)
+			}
+		}
+		matched = categorySubtree(categories, roots...)
+	}
+	if query.CategoryID != 0 {
+		subtree := categorySubtree(categories, query.CategoryID)
+		if matched == nil {
+			matched = subtree
+		} else {
+			for id := range matched {
+				if !subtree[id] {
+					delete(matched, id)
+				}
+			}
+		}
+	}
+
+	return matched
+}
+
+func (mdb *MemoryDB) GetAllTags(query TagQuery) ([]TagCount, int64, error) {
+
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	counts := make(map[string]int64)
+	for _, names := range mdb.bookTags {
+		for _, name := range names {
+			counts[name]++
+		}
+	}
+
+	part := normalizeTag(query.Name)
+	tags := []TagCount{}
+	for name, books := range counts {
+		if strings.Contains(name, part) {
+			tags = append(tags, TagCount{Name: name, Books: books})
+		}
+	}
+
+	sort.Slice(tags, func(i, j int) bool { return tags[i].Name < tags[j].Name })
+
+	total := int64(len(tags))
+	return paginate(tags, query.Limit, query.Offset), total, nil
+}
+
+func (mdb *MemoryDB) RenameTag(name string, newName string) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	name, newName = normalizeTag(name), normalizeTag(newName)
+	books := mdb.taggedBooks(name)
+	if len(books) == 0 {
+		return ErrTagNotFound
+	}
+	if newName == name {
+		return nil
+	}
+
+	for _, bookID := range books {
+		tags := make([]models.Tag, 0, len(mdb.bookTags[bookID]))
+		for _, tag := range mdb.bookTags[bookID] {
+			if tag == name {
+				tag = newName
+			}
+			tags = append(tags, models.Tag{Name: tag})
+		}
+		mdb.setBookTags(bookID, tags)
+		mdb.bumpVersion(bookID)
+	}
+
+	return nil
+}
+
+func (mdb *MemoryDB) DeleteTag(name string) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	name = normalizeTag(name)
+	books := mdb.taggedBooks(name)
+	if len(books) == 0 {
+		return ErrTagNotFound
+	}
+
+	for _, bookID := range books {
+		var tags []models.Tag
+		for _, tag := range mdb.bookTags[bookID] {
+			if tag != name {
+				tags = append(tags, models.Tag{Name: tag})
+			}
+		}
+		mdb.setBookTags(bookID, tags)
+		mdb.bumpVersion(bookID)
+	}
+
+	return nil
+}
+
+// taggedBooks returns the ids of the books having the tag with the normalized name.
+// The caller must hold the lock.
+func (mdb *MemoryDB) taggedBooks(name string) []uint {
+
+	var books []uint
+	for bookID, names := range mdb.bookTags {
+		for _, tag := range names {
+			if tag == name {
+				books = append(books, bookID)
+			}
+		}
+	}
+
+	return books
+}
+
+// setBookTags replaces the tags of the book like setBookTags of GormDB and returns them.
+// The caller must hold the write lock.
+func (mdb *MemoryDB) setBookTags(bookID uint, tags []models.Tag) []models.Tag {
+
+	names := tagNames(tags)
+	mdb.bookTags[bookID] = names
+
+	stored := make([]models.Tag, 0, len(names))
+	for _, name := range names {
+		stored = append(stored, models.Tag{Name: name})
+	}
+
+	return stored
+}
+
+func (mdb *MemoryDB) CreateSeries(series *models.Series) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	normalizeSeries(series)
+	if mdb.seriesNameInUse(series.Name, 0) {
+		return ErrSeriesNameIsInUse
+	}
+
+	mdb.lastSeriesID++
+	series.ID = mdb.lastSeriesID
+	mdb.series[series.ID] = *series
+
+	return nil
+}
+
+func (mdb *MemoryDB) GetSeries(id uint) (*models.Series, error) {
+
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	series, ok := mdb.series[id]
+	if !ok {
+		return nil, ErrSeriesNotFound
+	}
+
+	return &series, nil
+}
+
+func (mdb *MemoryDB) GetAllSeries(query SeriesQuery) ([]models.Series, int64, error) {
+
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	part := strings.ToLower(query.Name)
+	series := []models.Series{}
+	for _, s := range mdb.series {
+		if strings.Contains(strings.ToLower(s.Name), part) {
+			series = append(series, s)
+		}
+	}
+
+	sort.Slice(series, func(i, j int) bool {
+		if series[i].Name != series[j].Name {
+			return series[i].Name < series[j].Name
+		}
+		return series[i].ID < series[j].ID
+	})
+
+	total := int64(len(series))
+	return paginate(series, query.Limit, query.Offset), total, nil
+}
+
+func (mdb *MemoryDB) UpdateSeries(series *models.Series) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	if _, ok := mdb.series[series.ID]; !ok {
+		return ErrSeriesNotFound
+	}
+
+	normalizeSeries(series)
+	if mdb.seriesNameInUse(series.Name, series.ID) {
+		return ErrSeriesNameIsInUse
+	}
+	mdb.series[series.ID] = *series
+
+	return nil
+}
+
+func (mdb *MemoryDB) DeleteSeries(id uint) error {
+
+	mdb.mu.Lock()
+	defer mdb.mu.Unlock()
+
+	if _, ok := mdb.series[id]; !ok {
+		return ErrSeriesNotFound
+	}
+	for _, book := range mdb.books {
+		if book.SeriesID != nil && *book.SeriesID == id {
+			return ErrSeriesHasBooks
+		}
+	}
+
+	delete(mdb.series, id)
+	return nil
+}
+
+func (mdb *MemoryDB) GetSeriesProgress(seriesID uint, userID uint) (*SeriesProgress, error) {
+
+	mdb.mu.RLock()
+	defer mdb.mu.RUnlock()
+
+	series, ok := mdb.series[seriesID]
+	if !ok {
+		return nil, ErrSeriesNotFound
+	}
+
+	var volumes, owned []int
+	for _, book := range mdb.books {
+		if book.SeriesID == nil || *book.SeriesID != seriesID || book.Volumn <= 0 {
+			continue
+		}
+		volumes = append(volumes, book.Volumn)
+		if book.UserID == userID {
+			owned = append(owned, book.Volumn)
+		}
+	}
+	sort.Ints(owned)
+
+	return seriesProgress(&series, volumes, owned), nil
+}
+
+// seriesNameInUse reports whether a series other than except has the name, ignoring case.
+// The caller must hold the lock.
+func (mdb *MemoryDB) seriesNameInUse(name string, except uint) bool {
+
+	for id, series := range mdb.series {
+		if id != except && strings.EqualFold(series.Name, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkSeries returns ErrUnknownSeries if the series has an id that does not exist.
+// The caller must hold the lock.
+func (mdb *MemoryDB) checkSeries(series *models.Series) error {
+
+	if series == nil || series.ID == 0 {
+		return nil
+	}
+	if _, ok := mdb.series[series.ID]; !ok {
+		return ErrUnknownSeries
+	}
+
+	return nil
+}
+
+// setBookSeries links the book to its series like setBookSeries of GormDB.
+// The caller must hold the write lock and check the series first.
+func (mdb *MemoryDB) setBookSeries(book *models.Book) {
+
+	book.SeriesID = nil
+	if book.Series == nil {
+		return
+	}
+
+	series := *book.Series
+	if series.ID == 0 {
+		normalizeSeries(&series)
+		for id, stored := range mdb.series {
+			if strings.EqualFold(stored.Name, series.Name) {
+				series.ID = id
+			}
+		}
+		if series.ID == 0 {
+			mdb.lastSeriesID++
+			series.ID = mdb.lastSeriesID
+			mdb.series[series.ID] = series
+		}
+	}
+
+	series = mdb.series[series.ID]
+	book.Series = &series
+	book.SeriesID = &series.ID
+
+}
+
+// paginate returns the page of items starting at offset with at most limit items.
+// A zero limit means no limit.
+func paginate[T any](items []T, limit int, offset int) []T {
+
+	if offset >= len(items) {
+		return []T{}
+	}
+	items = items[offset:]
+
+	if limit > 0 && limit < len(items) {
+		items = items[:limit]
+	}
+
+	return items
+}
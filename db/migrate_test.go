@@ -0,0 +1,174 @@
+package db
+
+import (
+	"path/filepath"
+	"reflect"
+	"sort"
+	"testing"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/config"
+	"github.com/Parsa-Sh-Y/book-manager-service/db/migrations"
+)
+
+// seed0006 are the books of a database at 0006_nest_contents, before the authors and the
+// categories had tables, with the name, the category and the first and last name of the author
+var seed0006 = [][4]string{
+	{"Dune", "sci fi", "Frank", "Herbert"},
+	{"Dune Messiah", "Sci-Fi", "frank", " herbert "},
+	{"Children of Dune", "Sci-Fi", "Frank", "Herbert"},
+	{"Emma", "classics", "jane", "austen"},
+	{"Persuasion", "Classics", "Jane", "Austen"},
+	{"Sense and Sensibility", " Classics ", "Jane", "  Austen"},
+	{"Notes", "", "", ""},
+}
+
+// migrated0006 are the author and the category of each book of seed0006 once migrated,
+// the most used spelling of their normalized names
+var migrated0006 = map[string][2]string{
+	"Dune":                  {"Frank Herbert", "Sci-Fi"},
+	"Dune Messiah":          {"Frank Herbert", "Sci-Fi"},
+	"Children of Dune":      {"Frank Herbert", "Sci-Fi"},
+	"Emma":                  {"Jane Austen", "Classics"},
+	"Persuasion":            {"Jane Austen", "Classics"},
+	"Sense and Sensibility": {"Jane Austen", "Classics"},
+	"Notes":                 {"", ""},
+}
+
+func TestMigrations(t *testing.T) {
+
+	var conf config.Config
+	conf.Database.Driver, conf.Database.Path = DriverSQLite, filepath.Join(t.TempDir(), "book_manager.db")
+	gdb, err := CreateNewGormDB(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sqlDB, err := gdb.db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer sqlDB.Close()
+
+	count := len(migrations.All())
+	if count != 12 {
+		t.Fatalf("%d migrations, want 12", count)
+	}
+
+	// every migration applies, reverts and applies again on an empty database
+	migrate(t, gdb, count, 0)
+	migrate(t, gdb, 0, count)
+	migrate(t, gdb, count, 0)
+
+	// the rows of a database from before 0007
+	migrate(t, gdb, 6, count-6)
+	err = gdb.db.Exec(`INSERT INTO users (id, username, email, password, role) VALUES (1, 'ali', 'ali@example.com', '', 'member')`).Error
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, book := range seed0006 {
+		err := gdb.db.Exec(`INSERT INTO books (name, category, author_first_name, author_last_name, user_id) VALUES (?, ?, ?, ?, 1)`,
+			book[0], book[1], book[2], book[3]).Error
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	migrate(t, gdb, count, 0)
+	checkMigratedBooks(t, gdb)
+
+	// going down keeps the merged author and category of each book
+	migrate(t, gdb, 6, count-6)
+	var rows []struct{ Name, Category, AuthorFirstName, AuthorLastName string }
+	if err := gdb.db.Table("books").Order("id").Find(&rows).Error; err != nil {
+		t.Fatal(err)
+	}
+	if len(rows) != len(seed0006) {
+		t.Fatalf("%d books after going down, want %d", len(rows), len(seed0006))
+	}
+	for _, row := range rows {
+		author := row.AuthorFirstName + " " + row.AuthorLastName
+		if row.AuthorLastName == "" {
+			author = ""
+		}
+		if want := migrated0006[row.Name]; author != want[0] || row.Category != want[1] {
+			t.Errorf("%s after going down: author %q, category %q, want %q, %q", row.Name, author, row.Category, want[0], want[1])
+		}
+	}
+
+	migrate(t, gdb, count, 0)
+	checkMigratedBooks(t, gdb)
+}
+
+// migrate applies the pending migrations, then reverts steps of them,
+// and checks that applied migrations are left
+func migrate(t *testing.T, gdb *GormDB, applied int, steps int) {
+
+	t.Helper()
+	if _, err := gdb.MigrateUp(); err != nil {
+		t.Fatal(err)
+	}
+	if reverted, err := gdb.MigrateDown(steps); err != nil || len(reverted) != steps {
+		t.Fatalf("MigrateDown(%d) reverted %d: %v", steps, len(reverted), err)
+	}
+
+	states, err := gdb.MigrationStatus()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, state := range states {
+		if state.Applied != (i < applied) {
+			t.Fatalf("migration %04d_%s applied %v, want the first %d applied", state.Version, state.Name, state.Applied, applied)
+		}
+	}
+}
+
+// checkMigratedBooks checks the authors and the categories made of seed0006
+func checkMigratedBooks(t *testing.T, gdb *GormDB) {
+
+	t.Helper()
+	books, _, err := gdb.GetAllBooks(BookQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(*books) != len(seed0006) {
+		t.Fatalf("%d books, want %d", len(*books), len(seed0006))
+	}
+	for _, book := range *books {
+		var author, category string
+		if len(book.Authors) > 0 {
+			author = book.Authors[0].Author.FullName()
+		}
+		if book.Category != nil {
+			category = book.Category.Name
+		}
+		if want := migrated0006[book.Name]; len(book.Authors) > 1 || author != want[0] || category != want[1] {
+			t.Errorf("%s: authors %+v, category %q, want %q, %q", book.Name, book.Authors, category, want[0], want[1])
+		}
+	}
+
+	// one author and one category for each normalized name
+	authors, _, err := gdb.GetAllAuthors(AuthorQuery{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, author := range authors {
+		names = append(names, author.FullName())
+	}
+	sort.Strings(names)
+	if want := []string{"Frank Herbert", "Jane Austen"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("authors = %q, want %q", names, want)
+	}
+
+	categories, err := gdb.GetCategoryTree()
+	if err != nil {
+		t.Fatal(err)
+	}
+	names = nil
+	for _, category := range categories {
+		names = append(names, category.Name)
+	}
+	sort.Strings(names)
+	if want := []string{"Classics", "Sci-Fi"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("categories = %q, want %q", names, want)
+	}
+}
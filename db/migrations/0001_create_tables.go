@@ -0,0 +1,85 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type user0001 struct {
+	ID          uint
+	Username    string     `gorm:"type:varchar(50)"`
+	Email       string     `gorm:"type:varchar(50)"`
+	Password    string     `gorm:"type:varchar(255)"`
+	Firstname   string     `gorm:"type:varchar(50)"`
+	Lastname    string     `gorm:"type:varchar(50)"`
+	PhoneNumber string     `gorm:"type:char(11)"`
+	Gender      string     `gorm:"type:varchar(50)"`
+	Books       []book0001 `gorm:"foreignKey:UserID"`
+}
+
+func (user0001) TableName() string { return "users" }
+
+type book0001 struct {
+	ID                uint
+	Name              string        `gorm:"type:varchar(255)"`
+	Category          string        `gorm:"type:varchar(255)"`
+	Volumn            int           `gorm:"type:integer"`
+	PublishedAt       time.Time     `gorm:"type:date"`
+	TableOfContents   []content0001 `gorm:"foreignKey:BookId;constraint:onUpdate:CASCADE,onDelete:CASCADE"`
+	Summary           string        `gorm:"type:text"`
+	Publisher         string        `gorm:"type:varchar(255)"`
+	AuthorFirstName   string        `gorm:"type:varchar(50)"`
+	AuthorLastName    string        `gorm:"type:varchar(50)"`
+	AuthorBirthday    time.Time     `gorm:"type:date"`
+	AuthorNationality string        `gorm:"type:varchar(50)"`
+	UserID            uint
+}
+
+func (book0001) TableName() string { return "books" }
+
+type content0001 struct {
+	ID          uint
+	ContentName string `gorm:"type:varchar(255)"`
+	BookId      uint
+}
+
+func (content0001) TableName() string { return "contents" }
+
+// The first migration creates the tables that CreateSchema used to create with
+// AutoMigrate. Databases created by AutoMigrate already have them, so only the
+// missing tables and constraints are created.
+func init() {
+	register(Migration{
+		Version: 1,
+		Name:    "create_tables",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+
+			for _, table := range []interface{}{&user0001{}, &book0001{}, &content0001{}} {
+				if m.HasTable(table) {
+					continue
+				}
+				if err := m.CreateTable(table); err != nil {
+					return err
+				}
+			}
+
+			if !m.HasConstraint(&user0001{}, "Books") {
+				if err := m.CreateConstraint(&user0001{}, "Books"); err != nil {
+					return err
+				}
+			}
+			if !m.HasConstraint(&book0001{}, "TableOfContents") {
+				if err := m.CreateConstraint(&book0001{}, "TableOfContents"); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&content0001{}, &book0001{}, &user0001{})
+		},
+	})
+}
@@ -0,0 +1,65 @@
+package migrations
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type user0002 struct {
+	ID       uint
+	Sessions []session0002 `gorm:"foreignKey:UserID;constraint:onUpdate:CASCADE,onDelete:CASCADE"`
+}
+
+func (user0002) TableName() string { return "users" }
+
+type session0002 struct {
+	ID            uint
+	UserID        uint
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	RevokedAt     *time.Time
+	RefreshTokens []refreshToken0002 `gorm:"foreignKey:SessionID;constraint:onUpdate:CASCADE,onDelete:CASCADE"`
+}
+
+func (session0002) TableName() string { return "sessions" }
+
+type refreshToken0002 struct {
+	ID        uint
+	SessionID uint
+	TokenHash string `gorm:"type:char(64);uniqueIndex"`
+	CreatedAt time.Time
+	UsedAt    *time.Time
+}
+
+func (refreshToken0002) TableName() string { return "refresh_tokens" }
+
+func init() {
+	register(Migration{
+		Version: 2,
+		Name:    "create_sessions",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+
+			if err := m.CreateTable(&session0002{}, &refreshToken0002{}); err != nil {
+				return err
+			}
+
+			if !m.HasConstraint(&user0002{}, "Sessions") {
+				if err := m.CreateConstraint(&user0002{}, "Sessions"); err != nil {
+					return err
+				}
+			}
+			if !m.HasConstraint(&session0002{}, "RefreshTokens") {
+				if err := m.CreateConstraint(&session0002{}, "RefreshTokens"); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropTable(&refreshToken0002{}, &session0002{})
+		},
+	})
+}
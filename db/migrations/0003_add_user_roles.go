@@ -0,0 +1,30 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+type user0003 struct {
+	ID   uint
+	Role string `gorm:"type:varchar(20);default:member"`
+}
+
+func (user0003) TableName() string { return "users" }
+
+// Every existing user becomes a member
+func init() {
+	register(Migration{
+		Version: 3,
+		Name:    "add_user_roles",
+		Up: func(tx *gorm.DB) error {
+			if err := tx.Migrator().AddColumn(&user0003{}, "Role"); err != nil {
+				return err
+			}
+
+			return tx.Model(&user0003{}).Where("role IS NULL OR role = ''").Update("role", "member").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&user0003{}, "Role")
+		},
+	})
+}
@@ -0,0 +1,26 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+type book0004 struct {
+	ID      uint
+	Version uint `gorm:"not null;default:1"`
+}
+
+func (book0004) TableName() string { return "books" }
+
+// Every existing book starts at version 1
+func init() {
+	register(Migration{
+		Version: 4,
+		Name:    "add_book_versions",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&book0004{}, "Version")
+		},
+		Down: func(tx *gorm.DB) error {
+			return tx.Migrator().DropColumn(&book0004{}, "Version")
+		},
+	})
+}
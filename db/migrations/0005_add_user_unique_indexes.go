@@ -0,0 +1,45 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+type user0005 struct {
+	ID          uint
+	Username    string `gorm:"type:varchar(50);uniqueIndex:idx_users_username"`
+	Email       string `gorm:"type:varchar(50);uniqueIndex:idx_users_email"`
+	PhoneNumber string `gorm:"type:char(11);uniqueIndex:idx_users_phone_number,where:phone_number <> ''"`
+}
+
+func (user0005) TableName() string { return "users" }
+
+var userIndexes0005 = []string{"idx_users_username", "idx_users_email", "idx_users_phone_number"}
+
+// The usernames, emails and phone numbers were only checked before inserting a user,
+// the indexes make the database reject duplicates. The phone number is optional,
+// so the users without one are left out of its index.
+// The migration fails if the users table already has duplicates, they have to be fixed by hand.
+func init() {
+	register(Migration{
+		Version: 5,
+		Name:    "add_user_unique_indexes",
+		Up: func(tx *gorm.DB) error {
+			for _, index := range userIndexes0005 {
+				if err := tx.Migrator().CreateIndex(&user0005{}, index); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			for _, index := range userIndexes0005 {
+				if err := tx.Migrator().DropIndex(&user0005{}, index); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	})
+}
@@ -0,0 +1,76 @@
+package migrations
+
+import (
+	"gorm.io/gorm"
+)
+
+type content0006 struct {
+	ID       uint
+	BookId   uint
+	ParentID *uint
+	Position int    `gorm:"not null;default:0"`
+	Kind     string `gorm:"type:varchar(20)"`
+	Page     *int
+	Children []content0006 `gorm:"foreignKey:ParentID;constraint:onUpdate:CASCADE,onDelete:CASCADE"`
+}
+
+func (content0006) TableName() string { return "contents" }
+
+var contentColumns0006 = []string{"ParentID", "Position", "Kind", "Page"}
+
+// The contents become a tree. The existing contents stay at the top level,
+// in the order they were created.
+func init() {
+	register(Migration{
+		Version: 6,
+		Name:    "nest_contents",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+
+			for _, column := range contentColumns0006 {
+				if err := m.AddColumn(&content0006{}, column); err != nil {
+					return err
+				}
+			}
+			if err := m.CreateConstraint(&content0006{}, "Children"); err != nil {
+				return err
+			}
+
+			// number the contents of each book
+			var contents []content0006
+			if err := tx.Order("book_id, id").Find(&contents).Error; err != nil {
+				return err
+			}
+			position := 0
+			for i, content := range contents {
+				if i > 0 && content.BookId != contents[i-1].BookId {
+					position = 0
+				}
+				if err := tx.Model(&content0006{}).Where("id = ?", content.ID).Update("position", position).Error; err != nil {
+					return err
+				}
+				position++
+			}
+
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+
+			// the nested entries lose their parent, keep only the top level ones
+			if err := tx.Where("parent_id IS NOT NULL").Delete(&content0006{}).Error; err != nil {
+				return err
+			}
+			if err := m.DropConstraint(&content0006{}, "Children"); err != nil {
+				return err
+			}
+			for _, column := range contentColumns0006 {
+				if err := m.DropColumn(&content0006{}, column); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+	})
+}
@@ -0,0 +1,166 @@
+package migrations
+
+import (
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type author0007 struct {
+	ID          uint
+	FirstName   string    `gorm:"type:varchar(50)"`
+	LastName    string    `gorm:"type:varchar(50)"`
+	Birthday    time.Time `gorm:"type:date"`
+	Nationality string    `gorm:"type:varchar(50)"`
+}
+
+func (author0007) TableName() string { return "authors" }
+
+type book0007 struct {
+	ID                uint
+	AuthorFirstName   string    `gorm:"type:varchar(50)"`
+	AuthorLastName    string    `gorm:"type:varchar(50)"`
+	AuthorBirthday    time.Time `gorm:"type:date"`
+	AuthorNationality string    `gorm:"type:varchar(50)"`
+}
+
+func (book0007) TableName() string { return "books" }
+
+type bookAuthor0007 struct {
+	BookID   uint       `gorm:"primaryKey;autoIncrement:false"`
+	AuthorID uint       `gorm:"primaryKey;autoIncrement:false"`
+	Role     string     `gorm:"primaryKey;type:varchar(20)"`
+	Position int        `gorm:"not null;default:0"`
+	Book     book0007   `gorm:"constraint:onUpdate:CASCADE,onDelete:CASCADE"`
+	Author   author0007 `gorm:"constraint:onUpdate:CASCADE,onDelete:RESTRICT"`
+}
+
+func (bookAuthor0007) TableName() string { return "book_authors" }
+
+var authorColumns0007 = []string{"AuthorFirstName", "AuthorLastName", "AuthorBirthday", "AuthorNationality"}
+
+// The embedded author of the books becomes an author of its own. The same author
+// spelled with a different case or spacing is merged into one author, named with
+// the most used spelling.
+func init() {
+	register(Migration{
+		Version: 7,
+		Name:    "create_authors",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+
+			if err := m.CreateTable(&author0007{}, &bookAuthor0007{}); err != nil {
+				return err
+			}
+
+			var books []book0007
+			if err := tx.Order("id").Find(&books).Error; err != nil {
+				return err
+			}
+
+			// group the books by the normalized name of their author
+			var keys []string
+			groups := make(map[string][]book0007)
+			for _, book := range books {
+				first, last := normalizeName0007(book.AuthorFirstName), normalizeName0007(book.AuthorLastName)
+				if first == "" && last == "" {
+					continue
+				}
+				key := strings.ToLower(first + "\x00" + last)
+				if _, ok := groups[key]; !ok {
+					keys = append(keys, key)
+				}
+				groups[key] = append(groups[key], book)
+			}
+
+			for _, key := range keys {
+				author := mergeAuthors0007(groups[key])
+				if err := tx.Create(&author).Error; err != nil {
+					return err
+				}
+				for _, book := range groups[key] {
+					link := bookAuthor0007{BookID: book.ID, AuthorID: author.ID, Role: "author"}
+					if err := tx.Omit("Book", "Author").Create(&link).Error; err != nil {
+						return err
+					}
+				}
+			}
+
+			for _, column := range authorColumns0007 {
+				if err := m.DropColumn(&book0007{}, column); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+
+			for _, column := range authorColumns0007 {
+				if err := m.AddColumn(&book0007{}, column); err != nil {
+					return err
+				}
+			}
+
+			// only the first author of each book is kept
+			var links []bookAuthor0007
+			if err := tx.Preload("Author").Where("role = ?", "author").Order("book_id, position").Find(&links).Error; err != nil {
+				return err
+			}
+			done := make(map[uint]bool)
+			for _, link := range links {
+				if done[link.BookID] {
+					continue
+				}
+				done[link.BookID] = true
+				err := tx.Model(&book0007{}).Where("id = ?", link.BookID).Updates(map[string]interface{}{
+					"author_first_name":  link.Author.FirstName,
+					"author_last_name":   link.Author.LastName,
+					"author_birthday":    link.Author.Birthday,
+					"author_nationality": link.Author.Nationality,
+				}).Error
+				if err != nil {
+					return err
+				}
+			}
+
+			return m.DropTable(&bookAuthor0007{}, &author0007{})
+		},
+	})
+}
+
+// normalizeName0007 trims the name and collapses its spaces
+func normalizeName0007(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// mergeAuthors0007 makes one author of the embedded authors of the books.
+// The name is the most used spelling, the first one on a tie, and the
+// birthday and the nationality are the first ones given.
+func mergeAuthors0007(books []book0007) author0007 {
+
+	type spelling struct{ first, last string }
+	counts := make(map[spelling]int)
+	var best spelling
+	for _, book := range books {
+		s := spelling{normalizeName0007(book.AuthorFirstName), normalizeName0007(book.AuthorLastName)}
+		counts[s]++
+		if counts[s] > counts[best] {
+			best = s
+		}
+	}
+
+	author := author0007{FirstName: best.first, LastName: best.last}
+	for _, book := range books {
+		if author.Birthday.IsZero() && !book.AuthorBirthday.IsZero() {
+			author.Birthday = book.AuthorBirthday
+		}
+		if author.Nationality == "" && book.AuthorNationality != "" {
+			author.Nationality = normalizeName0007(book.AuthorNationality)
+		}
+	}
+
+	return author
+}
@@ -0,0 +1,150 @@
+package migrations
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type publisher0008 struct {
+	ID          uint
+	Name        string `gorm:"type:varchar(255);not null"`
+	Country     string `gorm:"type:varchar(50)"`
+	Website     string `gorm:"type:varchar(255)"`
+	FoundedYear int
+}
+
+func (publisher0008) TableName() string { return "publishers" }
+
+// book0008 is a book before the migration, with the name of its publisher
+type book0008 struct {
+	ID        uint
+	Publisher string `gorm:"type:varchar(255)"`
+}
+
+func (book0008) TableName() string { return "books" }
+
+// bookPublisher0008 is a book after the migration, linked to its publisher
+type bookPublisher0008 struct {
+	ID          uint
+	PublisherID *uint         `gorm:"index"`
+	Publisher   publisher0008 `gorm:"constraint:onUpdate:CASCADE,onDelete:RESTRICT"`
+}
+
+func (bookPublisher0008) TableName() string { return "books" }
+
+// The free-text publishers of the books become publishers of their own. The same
+// name spelled with a different case or spacing is merged into one publisher,
+// named with the most used spelling. The names of the publishers are unique
+// ignoring case.
+func init() {
+	register(Migration{
+		Version: 8,
+		Name:    "create_publishers",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+
+			if err := m.CreateTable(&publisher0008{}); err != nil {
+				return err
+			}
+			if err := tx.Exec("CREATE UNIQUE INDEX idx_publishers_name ON publishers (LOWER(name))").Error; err != nil {
+				return err
+			}
+			if err := m.AddColumn(&bookPublisher0008{}, "PublisherID"); err != nil {
+				return err
+			}
+			if err := m.CreateConstraint(&bookPublisher0008{}, "Publisher"); err != nil {
+				return err
+			}
+
+			var books []book0008
+			if err := tx.Order("id").Find(&books).Error; err != nil {
+				return err
+			}
+
+			// group the books by the normalized name of their publisher
+			var keys []string
+			groups := make(map[string][]book0008)
+			for _, book := range books {
+				name := normalizeName0008(book.Publisher)
+				if name == "" {
+					continue
+				}
+				key := strings.ToLower(name)
+				if _, ok := groups[key]; !ok {
+					keys = append(keys, key)
+				}
+				groups[key] = append(groups[key], book)
+			}
+
+			for _, key := range keys {
+				publisher := publisher0008{Name: mostUsedName0008(groups[key])}
+				if err := tx.Create(&publisher).Error; err != nil {
+					return err
+				}
+
+				ids := make([]uint, 0, len(groups[key]))
+				for _, book := range groups[key] {
+					ids = append(ids, book.ID)
+				}
+				err := tx.Model(&bookPublisher0008{}).Where("id IN ?", ids).Update("publisher_id", publisher.ID).Error
+				if err != nil {
+					return err
+				}
+			}
+
+			if err := m.DropColumn(&book0008{}, "Publisher"); err != nil {
+				return err
+			}
+
+			// sqlite changes the columns and the constraints by copying the table,
+			// which leaves out its indexes, so the index is created last
+			return m.CreateIndex(&bookPublisher0008{}, "PublisherID")
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+
+			if err := m.AddColumn(&book0008{}, "Publisher"); err != nil {
+				return err
+			}
+			err := tx.Exec("UPDATE books SET publisher = (SELECT name FROM publishers WHERE publishers.id = books.publisher_id)").Error
+			if err != nil {
+				return err
+			}
+
+			if err := m.DropIndex(&bookPublisher0008{}, "PublisherID"); err != nil {
+				return err
+			}
+			if err := m.DropConstraint(&bookPublisher0008{}, "Publisher"); err != nil {
+				return err
+			}
+			if err := m.DropColumn(&bookPublisher0008{}, "PublisherID"); err != nil {
+				return err
+			}
+
+			return m.DropTable(&publisher0008{})
+		},
+	})
+}
+
+// normalizeName0008 trims the name and collapses its spaces
+func normalizeName0008(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// mostUsedName0008 returns the most used spelling of the publisher of the books,
+// the first one on a tie
+func mostUsedName0008(books []book0008) string {
+
+	counts := make(map[string]int)
+	best := ""
+	for _, book := range books {
+		name := normalizeName0008(book.Publisher)
+		counts[name]++
+		if counts[name] > counts[best] {
+			best = name
+		}
+	}
+
+	return best
+}
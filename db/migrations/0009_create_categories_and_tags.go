@@ -0,0 +1,197 @@
+package migrations
+
+import (
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+type category0009 struct {
+	ID       uint
+	Name     string        `gorm:"type:varchar(255);not null"`
+	ParentID *uint         `gorm:"index"`
+	Parent   *category0009 `gorm:"constraint:onUpdate:CASCADE,onDelete:RESTRICT"`
+}
+
+func (category0009) TableName() string { return "categories" }
+
+type tag0009 struct {
+	ID   uint
+	Name string `gorm:"type:varchar(50);not null;uniqueIndex:idx_tags_name"`
+}
+
+func (tag0009) TableName() string { return "tags" }
+
+// book0009 is a book before the migration, with its free-text category
+type book0009 struct {
+	ID       uint
+	Category string `gorm:"type:varchar(255)"`
+}
+
+func (book0009) TableName() string { return "books" }
+
+// bookCategory0009 is a book after the migration, linked to its category
+type bookCategory0009 struct {
+	ID         uint
+	CategoryID *uint        `gorm:"index"`
+	Category   category0009 `gorm:"constraint:onUpdate:CASCADE,onDelete:RESTRICT"`
+}
+
+func (bookCategory0009) TableName() string { return "books" }
+
+// bookPublisher0009 is the index of the books on their publisher, made by 0008
+type bookPublisher0009 struct {
+	ID          uint
+	PublisherID *uint `gorm:"index"`
+}
+
+func (bookPublisher0009) TableName() string { return "books" }
+
+type bookTag0009 struct {
+	BookID uint     `gorm:"primaryKey;autoIncrement:false"`
+	TagID  uint     `gorm:"primaryKey;autoIncrement:false"`
+	Book   book0009 `gorm:"constraint:onUpdate:CASCADE,onDelete:CASCADE"`
+	Tag    tag0009  `gorm:"constraint:onUpdate:CASCADE,onDelete:CASCADE"`
+}
+
+func (bookTag0009) TableName() string { return "book_tags" }
+
+// The free-text categories of the books become top level categories. The same
+// category spelled with a different case, spacing or hyphenation, such as "Sci-Fi"
+// and "sci fi", is merged into one category named with the most used spelling.
+// Going down keeps the name of the category of each book, the nesting of the
+// categories and the tags are lost.
+func init() {
+	register(Migration{
+		Version: 9,
+		Name:    "create_categories_and_tags",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+
+			if err := m.CreateTable(&category0009{}, &tag0009{}, &bookTag0009{}); err != nil {
+				return err
+			}
+			err := tx.Exec("CREATE UNIQUE INDEX idx_categories_name ON categories (COALESCE(parent_id, 0), LOWER(name))").Error
+			if err != nil {
+				return err
+			}
+			if err := m.AddColumn(&bookCategory0009{}, "CategoryID"); err != nil {
+				return err
+			}
+			if err := m.CreateConstraint(&bookCategory0009{}, "Category"); err != nil {
+				return err
+			}
+
+			var books []book0009
+			if err := tx.Order("id").Find(&books).Error; err != nil {
+				return err
+			}
+
+			// group the books by the normalized name of their category
+			var keys []string
+			groups := make(map[string][]book0009)
+			for _, book := range books {
+				key := categoryKey0009(book.Category)
+				if key == "" {
+					continue
+				}
+				if _, ok := groups[key]; !ok {
+					keys = append(keys, key)
+				}
+				groups[key] = append(groups[key], book)
+			}
+
+			for _, key := range keys {
+				category := category0009{Name: mostUsedName0009(groups[key])}
+				if err := tx.Create(&category).Error; err != nil {
+					return err
+				}
+
+				ids := make([]uint, 0, len(groups[key]))
+				for _, book := range groups[key] {
+					ids = append(ids, book.ID)
+				}
+				err := tx.Model(&bookCategory0009{}).Where("id IN ?", ids).Update("category_id", category.ID).Error
+				if err != nil {
+					return err
+				}
+			}
+
+			if err := m.DropColumn(&book0009{}, "Category"); err != nil {
+				return err
+			}
+
+			// sqlite changes the columns and the constraints by copying the table,
+			// which leaves out its indexes, so the indexes are created last
+			if err := restorePublisherIndex0009(m); err != nil {
+				return err
+			}
+			return m.CreateIndex(&bookCategory0009{}, "CategoryID")
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+
+			if err := m.AddColumn(&book0009{}, "Category"); err != nil {
+				return err
+			}
+			err := tx.Exec("UPDATE books SET category = (SELECT name FROM categories WHERE categories.id = books.category_id)").Error
+			if err != nil {
+				return err
+			}
+
+			if err := m.DropIndex(&bookCategory0009{}, "CategoryID"); err != nil {
+				return err
+			}
+			if err := m.DropConstraint(&bookCategory0009{}, "Category"); err != nil {
+				return err
+			}
+			if err := m.DropColumn(&bookCategory0009{}, "CategoryID"); err != nil {
+				return err
+			}
+			if err := restorePublisherIndex0009(m); err != nil {
+				return err
+			}
+
+			return m.DropTable(&bookTag0009{}, &tag0009{}, &category0009{})
+		},
+	})
+}
+
+// restorePublisherIndex0009 creates the index of the books on their publisher
+// if copying the table left it out
+func restorePublisherIndex0009(m gorm.Migrator) error {
+
+	if m.HasIndex(&bookPublisher0009{}, "PublisherID") {
+		return nil
+	}
+
+	return m.CreateIndex(&bookPublisher0009{}, "PublisherID")
+}
+
+// normalizeName0009 trims the name and collapses its spaces
+func normalizeName0009(name string) string {
+	return strings.Join(strings.Fields(name), " ")
+}
+
+// categoryKey0009 returns the name of a category in lower case, with its hyphens and
+// underscores taken as spaces, so the spellings of the same category have the same key
+func categoryKey0009(name string) string {
+	return normalizeName0009(strings.NewReplacer("-", " ", "_", " ").Replace(strings.ToLower(name)))
+}
+
+// mostUsedName0009 returns the most used spelling of the category of the books,
+// the first one on a tie
+func mostUsedName0009(books []book0009) string {
+
+	counts := make(map[string]int)
+	best := ""
+	for _, book := range books {
+		name := normalizeName0009(book.Category)
+		counts[name]++
+		if counts[name] > counts[best] {
+			best = name
+		}
+	}
+
+	return best
+}
@@ -0,0 +1,78 @@
+package migrations
+
+import "gorm.io/gorm"
+
+type series0010 struct {
+	ID           uint
+	Name         string `gorm:"type:varchar(255);not null"`
+	Description  string `gorm:"type:text"`
+	TotalVolumes int    `gorm:"not null;default:0"`
+}
+
+func (series0010) TableName() string { return "series" }
+
+// bookSeries0010 is a book after the migration, linked to its series
+type bookSeries0010 struct {
+	ID       uint
+	SeriesID *uint      `gorm:"index"`
+	Series   series0010 `gorm:"constraint:onUpdate:CASCADE,onDelete:RESTRICT"`
+}
+
+func (bookSeries0010) TableName() string { return "books" }
+
+// bookIndexes0010 are the indexes of the books made by the earlier migrations
+type bookIndexes0010 struct {
+	ID          uint
+	PublisherID *uint `gorm:"index"`
+	CategoryID  *uint `gorm:"index"`
+}
+
+func (bookIndexes0010) TableName() string { return "books" }
+
+// The books may be volumes of a series. The names of the series are unique
+// ignoring case. No book is in a series before the migration.
+func init() {
+	register(Migration{
+		Version: 10,
+		Name:    "create_series",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+
+			if err := m.CreateTable(&series0010{}); err != nil {
+				return err
+			}
+			if err := tx.Exec("CREATE UNIQUE INDEX idx_series_name ON series (LOWER(name))").Error; err != nil {
+				return err
+			}
+			if err := m.AddColumn(&bookSeries0010{}, "SeriesID"); err != nil {
+				return err
+			}
+			if err := m.CreateConstraint(&bookSeries0010{}, "Series"); err != nil {
+				return err
+			}
+
+			if err := createMissingIndexes(m, &bookIndexes0010{}, "PublisherID", "CategoryID"); err != nil {
+				return err
+			}
+			return m.CreateIndex(&bookSeries0010{}, "SeriesID")
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+
+			if err := m.DropIndex(&bookSeries0010{}, "SeriesID"); err != nil {
+				return err
+			}
+			if err := m.DropConstraint(&bookSeries0010{}, "Series"); err != nil {
+				return err
+			}
+			if err := m.DropColumn(&bookSeries0010{}, "SeriesID"); err != nil {
+				return err
+			}
+			if err := createMissingIndexes(m, &bookIndexes0010{}, "PublisherID", "CategoryID"); err != nil {
+				return err
+			}
+
+			return m.DropTable(&series0010{})
+		},
+	})
+}
@@ -0,0 +1,58 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// bookISBN0011 is a book after the migration, with its ISBNs
+type bookISBN0011 struct {
+	ID     uint
+	ISBN10 string `gorm:"type:varchar(10);not null;default:''"`
+	ISBN13 string `gorm:"type:varchar(13);not null;default:''"`
+}
+
+func (bookISBN0011) TableName() string { return "books" }
+
+// bookIndexes0011 are the indexes of the books made by the earlier migrations
+type bookIndexes0011 struct {
+	ID          uint
+	PublisherID *uint `gorm:"index"`
+	CategoryID  *uint `gorm:"index"`
+	SeriesID    *uint `gorm:"index"`
+}
+
+func (bookIndexes0011) TableName() string { return "books" }
+
+// The books have an ISBN-10 and an ISBN-13, and each user has at most one book
+// with each ISBN-13. The books have no ISBN before the migration.
+func init() {
+	register(Migration{
+		Version: 11,
+		Name:    "add_book_isbn",
+		Up: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+
+			if err := m.AddColumn(&bookISBN0011{}, "ISBN10"); err != nil {
+				return err
+			}
+			if err := m.AddColumn(&bookISBN0011{}, "ISBN13"); err != nil {
+				return err
+			}
+
+			return tx.Exec("CREATE UNIQUE INDEX idx_books_user_id_isbn13 ON books (user_id, isbn13) WHERE isbn13 <> ''").Error
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+
+			if err := tx.Exec("DROP INDEX idx_books_user_id_isbn13").Error; err != nil {
+				return err
+			}
+			if err := m.DropColumn(&bookISBN0011{}, "ISBN13"); err != nil {
+				return err
+			}
+			if err := m.DropColumn(&bookISBN0011{}, "ISBN10"); err != nil {
+				return err
+			}
+
+			return createMissingIndexes(m, &bookIndexes0011{}, "PublisherID", "CategoryID", "SeriesID")
+		},
+	})
+}
@@ -0,0 +1,43 @@
+package migrations
+
+import "gorm.io/gorm"
+
+// bookRating0012 is a book after the migration, with its rating
+type bookRating0012 struct {
+	ID     uint
+	Rating int `gorm:"not null;default:0"`
+}
+
+func (bookRating0012) TableName() string { return "books" }
+
+// bookIndexes0012 are the indexes of the books made by the earlier migrations
+type bookIndexes0012 struct {
+	ID          uint
+	PublisherID *uint  `gorm:"index"`
+	CategoryID  *uint  `gorm:"index"`
+	SeriesID    *uint  `gorm:"index"`
+	UserID      uint   `gorm:"uniqueIndex:idx_books_user_id_isbn13,priority:1"`
+	ISBN13      string `gorm:"uniqueIndex:idx_books_user_id_isbn13,priority:2,where:isbn13 <> ''"`
+}
+
+func (bookIndexes0012) TableName() string { return "books" }
+
+// The owners rate their books. Every existing book starts unrated.
+func init() {
+	register(Migration{
+		Version: 12,
+		Name:    "add_book_rating",
+		Up: func(tx *gorm.DB) error {
+			return tx.Migrator().AddColumn(&bookRating0012{}, "Rating")
+		},
+		Down: func(tx *gorm.DB) error {
+			m := tx.Migrator()
+
+			if err := m.DropColumn(&bookRating0012{}, "Rating"); err != nil {
+				return err
+			}
+
+			return createMissingIndexes(m, &bookIndexes0012{}, "PublisherID", "CategoryID", "SeriesID", "idx_books_user_id_isbn13")
+		},
+	})
+}
@@ -0,0 +1,199 @@
+// Package migrations keeps the numbered schema migrations of the service.
+//
+// Every migration lives in its own file named after its version and registers
+// itself in init. The models used by a migration are snapshots of the models at
+// the time the migration was written, so later changes to db/models never change
+// what an old migration does.
+package migrations
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Migration is a numbered and reversible change to the database schema
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error
+}
+
+// State is a migration and whether it is applied to the database
+type State struct {
+	Migration
+	Applied   bool
+	AppliedAt time.Time
+}
+
+// schemaMigration is a row of the schema_migrations table.
+// There is a row for each applied migration.
+type schemaMigration struct {
+	Version   uint   `gorm:"primaryKey;autoIncrement:false"`
+	Name      string `gorm:"type:varchar(255)"`
+	AppliedAt time.Time
+}
+
+func (schemaMigration) TableName() string {
+	return "schema_migrations"
+}
+
+var all []Migration
+
+// register adds a migration to the list of migrations.
+// It is called from the init function of each migration file.
+func register(m Migration) {
+
+	for _, other := range all {
+		if other.Version == m.Version {
+			panic(fmt.Sprintf("migrations: version %d is registered twice", m.Version))
+		}
+	}
+
+	all = append(all, m)
+	sort.Slice(all, func(i, j int) bool { return all[i].Version < all[j].Version })
+
+}
+
+// All returns every known migration ordered by version
+func All() []Migration {
+	return append([]Migration(nil), all...)
+}
+
+// Up applies all the pending migrations in order and returns the applied ones
+func Up(db *gorm.DB) ([]Migration, error) {
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for _, m := range all {
+		if _, ok := applied[m.Version]; ok {
+			continue
+		}
+
+		err := run(db, func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&schemaMigration{Version: m.Version, Name: m.Name, AppliedAt: time.Now()}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+
+		done = append(done, m)
+	}
+
+	return done, nil
+}
+
+// Down reverts the last steps applied migrations and returns the reverted ones
+func Down(db *gorm.DB, steps int) ([]Migration, error) {
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var done []Migration
+	for i := len(all) - 1; i >= 0 && len(done) < steps; i-- {
+		m := all[i]
+		if _, ok := applied[m.Version]; !ok {
+			continue
+		}
+
+		err := run(db, func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&schemaMigration{}, m.Version).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %04d_%s: %w", m.Version, m.Name, err)
+		}
+
+		done = append(done, m)
+	}
+
+	return done, nil
+}
+
+// Status returns every known migration and whether it is applied
+func Status(db *gorm.DB) ([]State, error) {
+
+	applied, err := appliedVersions(db)
+	if err != nil {
+		return nil, err
+	}
+
+	states := make([]State, 0, len(all))
+	for _, m := range all {
+		row, ok := applied[m.Version]
+		states = append(states, State{Migration: m, Applied: ok, AppliedAt: row.AppliedAt})
+	}
+
+	return states, nil
+}
+
+// appliedVersions creates the schema_migrations table if needed
+// and returns its rows by version
+func appliedVersions(db *gorm.DB) (map[uint]schemaMigration, error) {
+
+	if !db.Migrator().HasTable(&schemaMigration{}) {
+		if err := db.Migrator().CreateTable(&schemaMigration{}); err != nil {
+			return nil, err
+		}
+	}
+
+	var rows []schemaMigration
+	if err := db.Order("version").Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	applied := make(map[uint]schemaMigration, len(rows))
+	for _, row := range rows {
+		applied[row.Version] = row
+	}
+
+	return applied, nil
+}
+
+// createMissingIndexes creates the indexes on the fields of model that do not exist.
+// sqlite alters the columns and the constraints of a table by copying it, which
+// leaves out its indexes, so the migrations doing so put back the earlier indexes.
+func createMissingIndexes(m gorm.Migrator, model interface{}, fields ...string) error {
+
+	for _, field := range fields {
+		if m.HasIndex(model, field) {
+			continue
+		}
+		if err := m.CreateIndex(model, field); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// run executes fn in a transaction on a single connection.
+// sqlite recreates a table to alter it and dropping the old table would cascade
+// to the rows referencing it, so foreign keys are turned off while migrating.
+func run(db *gorm.DB, fn func(tx *gorm.DB) error) error {
+
+	return db.Connection(func(conn *gorm.DB) error {
+		if conn.Dialector.Name() == "sqlite" {
+			if err := conn.Exec("PRAGMA foreign_keys = OFF").Error; err != nil {
+				return err
+			}
+			defer conn.Exec("PRAGMA foreign_keys = ON")
+		}
+
+		return conn.Transaction(fn)
+	})
+}
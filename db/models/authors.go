@@ -0,0 +1,62 @@
+package models
+
+import (
+	"strings"
+	"time"
+)
+
+// Author is a person who took part in writing books
+type Author struct {
+	ID          uint      `json:"id"`
+	FirstName   string    `gorm:"type:varchar(50)" json:"first_name" validate:"max=50"`
+	LastName    string    `gorm:"type:varchar(50)" json:"last_name" validate:"required_without=ID,max=50"`
+	Birthday    time.Time `gorm:"type:date" json:"birthday"`
+	Nationality string    `gorm:"type:varchar(50)" json:"nationality" validate:"max=50"`
+}
+
+// FullName returns the first and the last name of the author
+func (a *Author) FullName() string {
+
+	if a.FirstName == "" {
+		return a.LastName
+	}
+	if a.LastName == "" {
+		return a.FirstName
+	}
+
+	return a.FirstName + " " + a.LastName
+}
+
+// AuthorFromName splits the name of an author written as "First Last" or as
+// "Last, First". The last word is the last name when there is no comma.
+func AuthorFromName(name string) Author {
+
+	if last, first, ok := strings.Cut(name, ","); ok {
+		return Author{FirstName: strings.Join(strings.Fields(first), " "), LastName: strings.Join(strings.Fields(last), " ")}
+	}
+
+	words := strings.Fields(name)
+	if len(words) == 0 {
+		return Author{}
+	}
+
+	return Author{FirstName: strings.Join(words[:len(words)-1], " "), LastName: words[len(words)-1]}
+}
+
+// BookAuthor links an author to a book with the role the author had in it
+type BookAuthor struct {
+	BookID   uint   `gorm:"primaryKey;autoIncrement:false" json:"-"`
+	AuthorID uint   `gorm:"primaryKey;autoIncrement:false" json:"-"`
+	Role     string `gorm:"primaryKey;type:varchar(20)" json:"role" validate:"oneof=author translator editor illustrator"`
+	// Position is the order of the author in the credits of the book, starting at 0
+	Position int    `gorm:"not null;default:0" json:"-"`
+	Author   Author `gorm:"constraint:onUpdate:CASCADE,onDelete:RESTRICT" json:"author"`
+}
+
+// The roles of the authors of a book
+const (
+	AuthorRoleAuthor      = "author"
+	AuthorRoleTranslator  = "translator"
+	AuthorRoleEditor      = "editor"
+	AuthorRoleIllustrator = "illustrator"
+)
@@ -0,0 +1,68 @@
+package models
+
+import (
+	"encoding/json"
+	"sort"
+)
+
+// Category is a node of the managed tree the books are filed under,
+// such as Fiction > Science Fiction > Cyberpunk. The names of the
+// categories with the same parent are unique, ignoring case.
+type Category struct {
+	ID   uint   `json:"id"`
+	Name string `gorm:"type:varchar(255)" json:"name" validate:"required_without=ID,max=255"`
+	// ParentID is the id of the category this one is nested in, nil for the top level categories
+	ParentID *uint      `json:"parent_id"`
+	Children []Category `gorm:"-:all" json:"children,omitempty"`
+}
+
+// UnmarshalJSON reads a category object, or the name of the category as a string
+// like the category of the books used to be
+func (c *Category) UnmarshalJSON(data []byte) error {
+
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*c = Category{Name: name}
+		return nil
+	}
+
+	type category Category
+	return json.Unmarshal(data, (*category)(c))
+}
+
+// CategoryTree nests the categories under their parents and orders the
+// categories with the same parent by name. Categories whose parent is
+// not among categories are kept at the top level.
+func CategoryTree(categories []Category) []Category {
+
+	ids := make(map[uint]bool, len(categories))
+	for _, category := range categories {
+		ids[category.ID] = true
+	}
+
+	children := make(map[uint][]Category)
+	var roots []Category
+	for _, category := range categories {
+		if category.ParentID != nil && ids[*category.ParentID] {
+			children[*category.ParentID] = append(children[*category.ParentID], category)
+		} else {
+			roots = append(roots, category)
+		}
+	}
+
+	var nest func(entries []Category) []Category
+	nest = func(entries []Category) []Category {
+		sort.SliceStable(entries, func(i, j int) bool {
+			if entries[i].Name != entries[j].Name {
+				return entries[i].Name < entries[j].Name
+			}
+			return entries[i].ID < entries[j].ID
+		})
+		for i := range entries {
+			entries[i].Children = nest(children[entries[i].ID])
+		}
+		return entries
+	}
+
+	return nest(roots)
+}
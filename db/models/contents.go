@@ -0,0 +1,70 @@
+package models
+
+import "sort"
+
+// The kinds of the entries of a table of contents
+const (
+	ContentKindPart    = "part"
+	ContentKindChapter = "chapter"
+	ContentKindSection = "section"
+)
+
+// ContentTree nests the contents under their parents and orders the
+// entries with the same parent by position. Entries whose parent is
+// not among contents are kept at the top level.
+func ContentTree(contents []Content) []Content {
+
+	ids := make(map[uint]bool, len(contents))
+	for _, content := range contents {
+		ids[content.ID] = true
+	}
+
+	children := make(map[uint][]Content)
+	var roots []Content
+	for _, content := range contents {
+		if content.ParentID != nil && ids[*content.ParentID] {
+			children[*content.ParentID] = append(children[*content.ParentID], content)
+		} else {
+			roots = append(roots, content)
+		}
+	}
+
+	var nest func(entries []Content) []Content
+	nest = func(entries []Content) []Content {
+		sort.SliceStable(entries, func(i, j int) bool {
+			if entries[i].Position != entries[j].Position {
+				return entries[i].Position < entries[j].Position
+			}
+			return entries[i].ID < entries[j].ID
+		})
+		for i := range entries {
+			entries[i].Children = nest(children[entries[i].ID])
+		}
+		return entries
+	}
+
+	return nest(roots)
+}
+
+// ContentNames returns the names of the entries of the tree, each entry before its children
+func ContentNames(tree []Content) []string {
+
+	var names []string
+	for _, content := range tree {
+		names = append(names, content.ContentName)
+		names = append(names, ContentNames(content.Children)...)
+	}
+
+	return names
+}
+
+// ContentsFromNames makes a flat table of contents with an entry for each name
+func ContentsFromNames(names []string) []Content {
+
+	contents := make([]Content, 0, len(names))
+	for i, name := range names {
+		contents = append(contents, Content{ContentName: name, Position: i})
+	}
+
+	return contents
+}
@@ -1,44 +1,141 @@
 package models
 
 import (
+	"encoding/json"
+	"errors"
 	"time"
 )
 
 type User struct {
 	ID          uint
-	Username    string `gorm:"type:varchar(50)" json:"user_name"`
-	Email       string `gorm:"type:varchar(50)" json:"email"`
-	Password    string `gorm:"type:varchar(255)" json:"password"`
-	Firstname   string `gorm:"type:varchar(50)" json:"first_name"`
-	Lastname    string `gorm:"type:varchar(50)" json:"last_name"`
-	PhoneNumber string `gorm:"type:char(11)"  json:"phone_number"`
-	Gender      string `gorm:"type:varchar(50)"  json:"gender"`
+	Username    string `gorm:"type:varchar(50);uniqueIndex:idx_users_username" json:"user_name" validate:"required,max=50"`
+	Email       string `gorm:"type:varchar(50);uniqueIndex:idx_users_email" json:"email" validate:"required,email,max=50"`
+	Password    string `gorm:"type:varchar(255)" json:"password,omitempty" validate:"required,password"`
+	Firstname   string `gorm:"type:varchar(50)" json:"first_name" validate:"max=50"`
+	Lastname    string `gorm:"type:varchar(50)" json:"last_name" validate:"max=50"`
+	PhoneNumber string `gorm:"type:char(11);uniqueIndex:idx_users_phone_number,where:phone_number <> ''"  json:"phone_number" validate:"phone"`
+	Gender      string `gorm:"type:varchar(50)"  json:"gender" validate:"max=50"`
+	Role        string `gorm:"type:varchar(20);default:member" json:"role"`
 	Books       []Book
 }
 
+// The roles of the users
+const (
+	// RoleAdmin manages every book and user
+	RoleAdmin = "admin"
+	// RoleLibrarian edits the metadata of every book
+	RoleLibrarian = "librarian"
+	// RoleMember manages its own books
+	RoleMember = "member"
+)
+
+// IsValidRole reports whether role is one of the roles of the users
+func IsValidRole(role string) bool {
+	return role == RoleAdmin || role == RoleLibrarian || role == RoleMember
+}
+
+// Content is an entry of the table of contents of a book.
+// The entries are nested under their parent, such as the chapters of a part.
 type Content struct {
-	ID          uint
-	ContentName string `gorm:"type:varchar(255)"`
-	BookId      uint
+	ID          uint   `json:"id"`
+	ContentName string `gorm:"type:varchar(255)" json:"name" validate:"required,max=255"`
+	BookId      uint   `json:"-"`
+	// ParentID is the id of the entry this one is nested in, nil for the top level entries
+	ParentID *uint `json:"-"`
+	// Position is the order of the entry among the entries with the same parent, starting at 0
+	Position int       `gorm:"not null;default:0" json:"position"`
+	Kind     string    `gorm:"type:varchar(20)" json:"kind,omitempty" validate:"oneof=part chapter section"`
+	Page     *int      `json:"page,omitempty" validate:"min=1"`
+	Children []Content `gorm:"-:all" json:"children,omitempty" validate:"dive"`
 }
 
 type Book struct {
-	ID                  uint
-	Name                string    `gorm:"type:varchar(255)" json:"name"`
-	Category            string    `gorm:"type:varchar(255)" json:"category"`
-	Volumn              int       `gorm:"type:integer" json:"volumn"`
-	PublishedAt         time.Time `gorm:"type:date" json:"published_at"`
-	TableOfContents     []Content `gorm:"constraint:onUpdate:CASCADE,onDelete:CASCADE"`
-	TableOfContentsJson []string  `gorm:"-:all" json:"table_of_contents"` // only for json puposes, no such field would be created in the database
-	Summary             string    `gorm:"type:text" json:"summary"`
-	Publisher           string    `gorm:"type:varchar(255)" json:"publisher"`
-	Author              author    `gorm:"embedded" json:"author"`
-	UserID              uint      `json:"-"`
+	ID         uint
+	Name       string    `gorm:"type:varchar(255)" json:"name" validate:"required,max=255"`
+	CategoryID *uint     `json:"-"`
+	Category   *Category `gorm:"constraint:onUpdate:CASCADE,onDelete:RESTRICT" json:"category"`
+	SeriesID   *uint     `json:"-"`
+	Series     *Series   `gorm:"constraint:onUpdate:CASCADE,onDelete:RESTRICT" json:"series"`
+	// Volumn is the volume of the book in its series
+	Volumn      int       `gorm:"type:integer" json:"volumn" validate:"min=0"`
+	PublishedAt time.Time `gorm:"type:date" json:"published_at"`
+	// ISBN10 and ISBN13 are stored without hyphens. Either one is enough, the other one
+	// is derived from it, except for the ISBN-13 starting with 979 that have no ISBN-10.
+	ISBN10 string `gorm:"type:varchar(10);not null;default:''" json:"isbn_10" validate:"isbn10"`
+	ISBN13 string `gorm:"type:varchar(13);not null;default:'';uniqueIndex:idx_books_user_id_isbn13,priority:2,where:isbn13 <> ''" json:"isbn_13" validate:"isbn13"`
+	// TableOfContents is the tree of the contents, the top level entries with their Children
+	TableOfContents     []Content  `gorm:"constraint:onUpdate:CASCADE,onDelete:CASCADE" json:"contents" validate:"dive"`
+	TableOfContentsJson []string   `gorm:"-:all" json:"table_of_contents" validate:"dive,required,max=255"` // only for json puposes, no such field would be created in the database
+	Summary             string     `gorm:"type:text" json:"summary"`
+	PublisherID         *uint      `json:"-"`
+	Publisher           *Publisher `gorm:"constraint:onUpdate:CASCADE,onDelete:RESTRICT" json:"publisher"`
+	// Authors are the credits of the book in order, an author may have more than one role
+	Authors []BookAuthor `gorm:"constraint:onUpdate:CASCADE,onDelete:CASCADE" json:"authors" validate:"dive"`
+	// Tags are ordered by name
+	Tags []Tag `gorm:"many2many:book_tags;constraint:onUpdate:CASCADE,onDelete:CASCADE" json:"tags" validate:"dive"`
+	// UserID is the owner of the book, who has at most one book with each ISBN
+	UserID uint `gorm:"uniqueIndex:idx_books_user_id_isbn13,priority:1" json:"-"`
+	// Version is incremented on every update, it is the ETag of the book
+	Version uint `gorm:"not null;default:1" json:"version"`
+	// Rating is the rating the owner gave the book, from 1 to 5 stars, zero when it is not rated
+	Rating int `gorm:"not null;default:0" json:"rating" validate:"min=0,max=5"`
+}
+
+// ErrAuthorAndAuthors The book has both the single author books used to have and authors
+var ErrAuthorAndAuthors = errors.New("the author of the book is given along with its authors")
+
+// UnmarshalJSON reads a book. The single author the books used to have, an author object
+// or the name of the author as a string, is read as the only author of the book.
+func (b *Book) UnmarshalJSON(data []byte) error {
+
+	type book Book
+	var legacy struct {
+		*book
+		Author json.RawMessage `json:"author"`
+	}
+	legacy.book = (*book)(b)
+	if err := json.Unmarshal(data, &legacy); err != nil {
+		return err
+	}
+	if len(legacy.Author) == 0 || string(legacy.Author) == "null" {
+		return nil
+	}
+
+	var author Author
+	var name string
+	if err := json.Unmarshal(legacy.Author, &name); err == nil {
+		author = AuthorFromName(name)
+	} else if err := json.Unmarshal(legacy.Author, &author); err != nil {
+		return err
+	}
+	if author == (Author{}) {
+		return nil
+	}
+	if len(b.Authors) > 0 {
+		return ErrAuthorAndAuthors
+	}
+	b.Authors = []BookAuthor{{Role: AuthorRoleAuthor, Author: author}}
+
+	return nil
+}
+
+// Session is a login of a user. It lasts until it expires or the user logs out,
+// and its refresh token is replaced on every refresh.
+type Session struct {
+	ID            uint
+	UserID        uint
+	CreatedAt     time.Time
+	ExpiresAt     time.Time
+	RevokedAt     *time.Time
+	RefreshTokens []RefreshToken `gorm:"constraint:onUpdate:CASCADE,onDelete:CASCADE"`
 }
 
-type author struct {
-	AuthorFirstName   string    `gorm:"type:varchar(50)" json:"first_name"`
-	AuthorLastName    string    `gorm:"type:varchar(50)" json:"last_name"`
-	AuthorBirthday    time.Time `gorm:"type:date" json:"birthday"`
-	AuthorNationality string    `gorm:"type:varchar(50)" json:"nationality"`
+// RefreshToken is a refresh token of a session. Only the hash of the token is kept.
+type RefreshToken struct {
+	ID        uint
+	SessionID uint
+	TokenHash string `gorm:"type:char(64);uniqueIndex"`
+	CreatedAt time.Time
+	// UsedAt is set when the token is exchanged for a new one
+	UsedAt *time.Time
 }
@@ -0,0 +1,26 @@
+package models
+
+import "encoding/json"
+
+// Publisher is a publishing house the books are published by
+type Publisher struct {
+	ID          uint   `json:"id"`
+	Name        string `gorm:"type:varchar(255)" json:"name" validate:"required_without=ID,max=255"`
+	Country     string `gorm:"type:varchar(50)" json:"country" validate:"max=50"`
+	Website     string `gorm:"type:varchar(255)" json:"website" validate:"url,max=255"`
+	FoundedYear int    `json:"founded_year" validate:"min=0,max=9999"`
+}
+
+// UnmarshalJSON reads a publisher object, or the name of the publisher as a string
+// like the publisher of the books used to be
+func (p *Publisher) UnmarshalJSON(data []byte) error {
+
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*p = Publisher{Name: name}
+		return nil
+	}
+
+	type publisher Publisher
+	return json.Unmarshal(data, (*publisher)(p))
+}
@@ -0,0 +1,26 @@
+package models
+
+import "encoding/json"
+
+// Series groups the volumes of a multi-volume work. The volume of a book
+// in its series is its Volumn, starting at 1; zero is an unnumbered book.
+type Series struct {
+	ID          uint   `json:"id"`
+	Name        string `gorm:"type:varchar(255)" json:"name" validate:"required_without=ID,max=255"`
+	Description string `gorm:"type:text" json:"description"`
+	// TotalVolumes is the number of volumes of the complete series, zero when it is not known
+	TotalVolumes int `gorm:"not null;default:0" json:"total_volumes" validate:"min=0"`
+}
+
+// UnmarshalJSON reads a series object, or the name of the series as a string
+func (s *Series) UnmarshalJSON(data []byte) error {
+
+	var name string
+	if err := json.Unmarshal(data, &name); err == nil {
+		*s = Series{Name: name}
+		return nil
+	}
+
+	type series Series
+	return json.Unmarshal(data, (*series)(s))
+}
@@ -0,0 +1,28 @@
+package models
+
+import "encoding/json"
+
+// Tag is a free-form label the users put on the books.
+// The names of the tags are lower case and unique.
+type Tag struct {
+	ID   uint   `json:"-"`
+	Name string `gorm:"type:varchar(50);uniqueIndex:idx_tags_name" json:"name" validate:"required,max=50"`
+}
+
+// MarshalJSON writes the tag as its name
+func (t Tag) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Name)
+}
+
+// UnmarshalJSON reads the name of the tag
+func (t *Tag) UnmarshalJSON(data []byte) error {
+
+	*t = Tag{}
+	return json.Unmarshal(data, &t.Name)
+}
+
+// BookTag puts a tag on a book
+type BookTag struct {
+	BookID uint `gorm:"primaryKey;autoIncrement:false"`
+	TagID  uint `gorm:"primaryKey;autoIncrement:false"`
+}
@@ -0,0 +1,206 @@
+package db
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrPublisherNotFound No such publisher exists
+	ErrPublisherNotFound = errors.New("no such publisher exists")
+	// ErrPublisherNameIsInUse There exists another publisher with the same name
+	ErrPublisherNameIsInUse = errors.New("name is in use by another publisher")
+	// ErrPublisherHasBooks The publisher has published books and can not be deleted
+	ErrPublisherHasBooks = errors.New("the publisher has published books")
+	// ErrUnknownPublisher The book is published by a publisher id that does not exist
+	ErrUnknownPublisher = errors.New("the book is published by a publisher that does not exist")
+)
+
+// PublisherQuery filters and paginates the publishers returned by GetAllPublishers.
+// The publishers are ordered by name and id.
+type PublisherQuery struct {
+	// Name matches a part of the name, ignoring case
+	Name string
+	// Country matches the whole value, ignoring case
+	Country string
+
+	// Limit is the maximum number of publishers returned, zero means no limit
+	Limit  int
+	Offset int
+}
+
+// filterPublishers adds the filters of the query to tx
+func (q *PublisherQuery) filterPublishers(tx *gorm.DB) *gorm.DB {
+
+	if q.Name != "" {
+		tx = tx.Where(`LOWER(name) LIKE ? ESCAPE '\'`, "%"+escapeLike(strings.ToLower(q.Name))+"%")
+	}
+	if q.Country != "" {
+		tx = tx.Where("LOWER(country) = ?", strings.ToLower(q.Country))
+	}
+
+	return tx
+}
+
+// matchPublisher reports whether the publisher passes the filters of the query.
+// It is the in-memory counterpart of filterPublishers.
+func (q *PublisherQuery) matchPublisher(publisher *models.Publisher) bool {
+
+	if q.Name != "" && !strings.Contains(strings.ToLower(publisher.Name), strings.ToLower(q.Name)) {
+		return false
+	}
+	if q.Country != "" && !strings.EqualFold(publisher.Country, q.Country) {
+		return false
+	}
+
+	return true
+}
+
+// CreatePublisher adds the publisher. The unique index of the publishers table
+// makes sure no other publisher has the same name, ignoring case.
+func (gdb *GormDB) CreatePublisher(publisher *models.Publisher) error {
+
+	publisher.ID = 0
+	normalizePublisher(publisher)
+	return translateError(gdb.db.Create(publisher).Error)
+
+}
+
+func (gdb *GormDB) GetPublisher(id uint) (*models.Publisher, error) {
+
+	var publisher models.Publisher
+	err := gdb.db.Where("id = ?", id).First(&publisher).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrPublisherNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &publisher, nil
+
+}
+
+// GetAllPublishers returns a page of the publishers matching the query
+// and the number of matching publishers on all the pages
+func (gdb *GormDB) GetAllPublishers(query PublisherQuery) ([]models.Publisher, int64, error) {
+
+	tx := query.filterPublishers(gdb.db.Model(models.Publisher{})).Session(&gorm.Session{})
+
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	tx = tx.Order("name").Order("id")
+	if query.Limit > 0 {
+		tx = tx.Limit(query.Limit)
+	}
+	if query.Offset > 0 {
+		tx = tx.Offset(query.Offset)
+	}
+
+	publishers := []models.Publisher{}
+	if err := tx.Find(&publishers).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return publishers, total, nil
+
+}
+
+// UpdatePublisher replaces every field of the publisher with the same id
+func (gdb *GormDB) UpdatePublisher(publisher *models.Publisher) error {
+
+	normalizePublisher(publisher)
+	result := gdb.db.Model(&models.Publisher{}).Where("id = ?", publisher.ID).Select("*").Omit("id").Updates(publisher)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrPublisherNotFound
+	}
+
+	return nil
+
+}
+
+// DeletePublisher deletes the publisher if it has published no book
+func (gdb *GormDB) DeletePublisher(id uint) error {
+
+	return gdb.db.Transaction(func(tx *gorm.DB) error {
+
+		var count int64
+		if err := tx.Model(&models.Book{}).Where("publisher_id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrPublisherHasBooks
+		}
+
+		result := tx.Delete(&models.Publisher{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrPublisherNotFound
+		}
+
+		return nil
+	})
+
+}
+
+// setBookPublisher links the book to its publisher. Like the authors, a publisher
+// with an id has to exist and the others are looked up by name and created if there is none.
+func setBookPublisher(tx *gorm.DB, book *models.Book) error {
+
+	book.PublisherID = nil
+	if book.Publisher == nil {
+		return nil
+	}
+
+	if err := findOrCreatePublisher(tx, book.Publisher); err != nil {
+		return err
+	}
+
+	book.PublisherID = &book.Publisher.ID
+	return nil
+}
+
+// findOrCreatePublisher replaces publisher with the stored publisher of the same id or,
+// without an id, of the same name ignoring case. A publisher with a new name is created.
+func findOrCreatePublisher(tx *gorm.DB, publisher *models.Publisher) error {
+
+	if publisher.ID != 0 {
+		err := tx.Where("id = ?", publisher.ID).First(publisher).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUnknownPublisher
+		}
+		return err
+	}
+
+	// another request may create the same publisher in the meantime,
+	// in which case the insert does nothing and the publisher is read back
+	normalizePublisher(publisher)
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(publisher)
+	if result.Error != nil || result.RowsAffected == 1 {
+		return result.Error
+	}
+
+	name := publisher.Name
+	*publisher = models.Publisher{}
+	return tx.Where("LOWER(name) = LOWER(?)", name).First(publisher).Error
+}
+
+// normalizePublisher trims the name and the country of the publisher and collapses their spaces
+func normalizePublisher(publisher *models.Publisher) {
+
+	publisher.Name = normalizeName(publisher.Name)
+	publisher.Country = normalizeName(publisher.Country)
+	publisher.Website = strings.TrimSpace(publisher.Website)
+
+}
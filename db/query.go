@@ -0,0 +1,306 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrInvalidSortField The books can not be sorted by the requested field
+	ErrInvalidSortField = errors.New("books can not be sorted by this field")
+)
+
+const (
+	SortByID          = "id"
+	SortByName        = "name"
+	SortByCategory    = "category"
+	SortByPublisher   = "publisher"
+	SortByAuthor      = "author"
+	SortBySeries      = "series"
+	SortByVolume      = "volume"
+	SortByPublishedAt = "published_at"
+)
+
+// firstAuthorColumn is a column of the first author of the book, empty when the book has no author
+const firstAuthorColumn = `COALESCE((SELECT authors.%s FROM book_authors JOIN authors ON authors.id = book_authors.author_id
+	WHERE book_authors.book_id = books.id ORDER BY book_authors.position LIMIT 1), '')`
+
+// bookSortColumns maps each sort field to the columns of the books table
+var bookSortColumns = map[string][]string{
+	SortByID:          {"id"},
+	SortByName:        {"name"},
+	SortByCategory:    {"COALESCE((SELECT categories.name FROM categories WHERE categories.id = books.category_id), '')"},
+	SortByPublisher:   {"COALESCE((SELECT publishers.name FROM publishers WHERE publishers.id = books.publisher_id), '')"},
+	SortByAuthor:      {fmt.Sprintf(firstAuthorColumn, "last_name"), fmt.Sprintf(firstAuthorColumn, "first_name")},
+	SortBySeries:      {"COALESCE((SELECT series.name FROM series WHERE series.id = books.series_id), '')", "volumn"},
+	SortByVolume:      {"volumn"},
+	SortByPublishedAt: {"published_at"},
+}
+
+// BookQuery filters, sorts and paginates the books returned by GetAllBooks.
+// The zero value returns every book ordered by id.
+type BookQuery struct {
+	// Category matches the books in the categories with the name, ignoring case,
+	// or in the categories nested in them
+	Category string
+	// CategoryID matches the books in the category or in the categories nested in it
+	CategoryID uint
+	// Tags matches the books having every one of the tags
+	Tags []string
+	// Publisher matches the whole name, ignoring case
+	Publisher string
+	// PublisherID matches the books published by the publisher
+	PublisherID uint
+	// Author matches a part of the first name, last name or full name of an author of the book, ignoring case
+	Author string
+	// AuthorID matches the books crediting the author in any role
+	AuthorID uint
+	// SeriesID matches the books of the series
+	SeriesID uint
+	// ISBN13 matches the books with the ISBN-13, without hyphens
+	ISBN13 string
+	// UserID matches the books of the user
+	UserID uint
+	// AfterID matches the books with a greater id, to page through all the books
+	// ordered by id without an offset
+	AfterID uint
+
+	// The ranges are inclusive, a nil bound is open
+	MinVolume       *int
+	MaxVolume       *int
+	PublishedAfter  *time.Time
+	PublishedBefore *time.Time
+
+	SortBy     string
+	Descending bool
+
+	// Limit is the maximum number of books returned, zero means no limit
+	Limit  int
+	Offset int
+
+	// Contents loads the tables of contents of the books
+	Contents bool
+}
+
+// Validate checks that the query can be run
+func (q *BookQuery) Validate() error {
+
+	if q.SortBy != "" {
+		if _, ok := bookSortColumns[q.SortBy]; !ok {
+			return ErrInvalidSortField
+		}
+	}
+
+	return nil
+}
+
+// filterBooks adds the filters of the query to tx
+func (q *BookQuery) filterBooks(tx *gorm.DB) *gorm.DB {
+
+	if q.Category != "" {
+		tx = tx.Where("category_id IN ("+fmt.Sprintf(categorySubtreeQuery, "LOWER(name) = LOWER(?)")+")", normalizeName(q.Category))
+	}
+	if q.CategoryID != 0 {
+		tx = tx.Where("category_id IN ("+fmt.Sprintf(categorySubtreeQuery, "id = ?")+")", q.CategoryID)
+	}
+	for _, tag := range q.Tags {
+		tx = tx.Where(`EXISTS (SELECT 1 FROM book_tags JOIN tags ON tags.id = book_tags.tag_id
+			WHERE book_tags.book_id = books.id AND tags.name = ?)`, normalizeTag(tag))
+	}
+	if q.Publisher != "" {
+		tx = tx.Where("publisher_id IN (SELECT id FROM publishers WHERE LOWER(name) = ?)", strings.ToLower(q.Publisher))
+	}
+	if q.PublisherID != 0 {
+		tx = tx.Where("publisher_id = ?", q.PublisherID)
+	}
+	if q.Author != "" {
+		pattern := "%" + escapeLike(strings.ToLower(q.Author)) + "%"
+		tx = tx.Where(`EXISTS (SELECT 1 FROM book_authors JOIN authors ON authors.id = book_authors.author_id
+			WHERE book_authors.book_id = books.id AND `+authorNameCondition+`)`, map[string]interface{}{"p": pattern})
+	}
+	if q.AuthorID != 0 {
+		tx = tx.Where("EXISTS (SELECT 1 FROM book_authors WHERE book_authors.book_id = books.id AND book_authors.author_id = ?)", q.AuthorID)
+	}
+	if q.SeriesID != 0 {
+		tx = tx.Where("series_id = ?", q.SeriesID)
+	}
+	if q.ISBN13 != "" {
+		tx = tx.Where("isbn13 = ?", q.ISBN13)
+	}
+	if q.UserID != 0 {
+		tx = tx.Where("user_id = ?", q.UserID)
+	}
+	if q.AfterID != 0 {
+		tx = tx.Where("id > ?", q.AfterID)
+	}
+	if q.MinVolume != nil {
+		tx = tx.Where("volumn >= ?", *q.MinVolume)
+	}
+	if q.MaxVolume != nil {
+		tx = tx.Where("volumn <= ?", *q.MaxVolume)
+	}
+	if q.PublishedAfter != nil {
+		tx = tx.Where("published_at >= ?", *q.PublishedAfter)
+	}
+	if q.PublishedBefore != nil {
+		tx = tx.Where("published_at <= ?", *q.PublishedBefore)
+	}
+
+	return tx
+}
+
+// orderBooks adds the order of the query to tx.
+// The id is always the last column so the pages are stable.
+func (q *BookQuery) orderBooks(tx *gorm.DB) *gorm.DB {
+
+	direction := " ASC"
+	if q.Descending {
+		direction = " DESC"
+	}
+
+	if q.SortBy != "" && q.SortBy != SortByID {
+		for _, column := range bookSortColumns[q.SortBy] {
+			tx = tx.Order(column + direction)
+		}
+	}
+
+	return tx.Order("id" + direction)
+}
+
+// escapeLike escapes the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// firstAuthor returns the first author of the book, the zero author when it has none.
+// The authors of the book are ordered by position.
+func firstAuthor(book *models.Book) models.Author {
+
+	if len(book.Authors) == 0 {
+		return models.Author{}
+	}
+
+	return book.Authors[0].Author
+}
+
+// publisherName returns the name of the publisher of the book, empty when it has none
+func publisherName(book *models.Book) string {
+
+	if book.Publisher == nil {
+		return ""
+	}
+
+	return book.Publisher.Name
+}
+
+// matchBook reports whether the book passes the filters of the query.
+// It is the in-memory counterpart of filterBooks. The category filters
+// need the tree of the categories, categories are the ids of the ones
+// they match, see MemoryDB.queryCategories.
+func (q *BookQuery) matchBook(book *models.Book, categories map[uint]bool) bool {
+
+	if categories != nil && (book.CategoryID == nil || !categories[*book.CategoryID]) {
+		return false
+	}
+	if !hasTags(book, q.Tags) {
+		return false
+	}
+	if q.Publisher != "" && !strings.EqualFold(publisherName(book), q.Publisher) {
+		return false
+	}
+	if q.PublisherID != 0 && (book.PublisherID == nil || *book.PublisherID != q.PublisherID) {
+		return false
+	}
+	if q.Author != "" {
+		part := strings.ToLower(q.Author)
+		found := false
+		for i := range book.Authors {
+			found = found || matchAuthorName(&book.Authors[i].Author, part)
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.AuthorID != 0 {
+		found := false
+		for _, link := range book.Authors {
+			found = found || link.AuthorID == q.AuthorID
+		}
+		if !found {
+			return false
+		}
+	}
+	if q.SeriesID != 0 && (book.SeriesID == nil || *book.SeriesID != q.SeriesID) {
+		return false
+	}
+	if q.ISBN13 != "" && book.ISBN13 != q.ISBN13 {
+		return false
+	}
+	if q.UserID != 0 && book.UserID != q.UserID {
+		return false
+	}
+	if q.AfterID != 0 && book.ID <= q.AfterID {
+		return false
+	}
+	if q.MinVolume != nil && book.Volumn < *q.MinVolume {
+		return false
+	}
+	if q.MaxVolume != nil && book.Volumn > *q.MaxVolume {
+		return false
+	}
+	if q.PublishedAfter != nil && book.PublishedAt.Before(*q.PublishedAfter) {
+		return false
+	}
+	if q.PublishedBefore != nil && book.PublishedAt.After(*q.PublishedBefore) {
+		return false
+	}
+
+	return true
+}
+
+// lessBook reports whether a comes before b in the order of the query.
+// It is the in-memory counterpart of orderBooks.
+func (q *BookQuery) lessBook(a, b *models.Book) bool {
+
+	cmp := 0
+	switch q.SortBy {
+	case SortByName:
+		cmp = strings.Compare(a.Name, b.Name)
+	case SortByCategory:
+		cmp = strings.Compare(categoryName(a), categoryName(b))
+	case SortByPublisher:
+		cmp = strings.Compare(publisherName(a), publisherName(b))
+	case SortByAuthor:
+		first, second := firstAuthor(a), firstAuthor(b)
+		cmp = strings.Compare(first.LastName, second.LastName)
+		if cmp == 0 {
+			cmp = strings.Compare(first.FirstName, second.FirstName)
+		}
+	case SortBySeries:
+		cmp = strings.Compare(seriesName(a), seriesName(b))
+		if cmp == 0 {
+			cmp = a.Volumn - b.Volumn
+		}
+	case SortByVolume:
+		cmp = a.Volumn - b.Volumn
+	case SortByPublishedAt:
+		if a.PublishedAt.Before(b.PublishedAt) {
+			cmp = -1
+		} else if a.PublishedAt.After(b.PublishedAt) {
+			cmp = 1
+		}
+	}
+	if cmp == 0 {
+		cmp = int(a.ID) - int(b.ID)
+	}
+
+	if q.Descending {
+		return cmp > 0
+	}
+	return cmp < 0
+}
@@ -0,0 +1,198 @@
+package db
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+)
+
+var (
+	// ErrEmptySearch The search text has no words to search for
+	ErrEmptySearch = errors.New("the search text has no words")
+)
+
+const (
+	highlightStart = "<mark>"
+	highlightStop  = "</mark>"
+
+	// snippetRadius is the number of characters kept around the first match of a long field
+	snippetRadius = 60
+)
+
+// The fields of a book that are searched, they are also the keys of BookMatch.Highlights
+const (
+	SearchFieldName            = "name"
+	SearchFieldSummary         = "summary"
+	SearchFieldPublisher       = "publisher"
+	SearchFieldAuthor          = "author"
+	SearchFieldTableOfContents = "table_of_contents"
+)
+
+// searchWeights is how much a match in each field counts in the rank of a book
+var searchWeights = map[string]float64{
+	SearchFieldName:            1.0,
+	SearchFieldAuthor:          1.0,
+	SearchFieldSummary:         0.4,
+	SearchFieldTableOfContents: 0.2,
+	SearchFieldPublisher:       0.2,
+}
+
+// BookMatch is a book found by SearchBooks
+type BookMatch struct {
+	// Book has its table of contents loaded
+	Book models.Book
+	Rank float64
+	// Highlights has a snippet of each matching field with the matches wrapped in <mark></mark>
+	Highlights map[string]string
+}
+
+// searchTerms splits the search text into lower case words
+func searchTerms(text string) []string {
+
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	seen := make(map[string]bool, len(words))
+	terms := words[:0]
+	for _, word := range words {
+		if !seen[word] {
+			seen[word] = true
+			terms = append(terms, word)
+		}
+	}
+
+	return terms
+}
+
+// searchableFields returns the text of each searched field of the book
+func searchableFields(book *models.Book, contents []models.Content) map[string]string {
+
+	names := models.ContentNames(models.ContentTree(contents))
+	authors := make([]string, 0, len(book.Authors))
+	for i := range book.Authors {
+		authors = append(authors, book.Authors[i].Author.FullName())
+	}
+
+	return map[string]string{
+		SearchFieldName:            book.Name,
+		SearchFieldSummary:         book.Summary,
+		SearchFieldPublisher:       publisherName(book),
+		SearchFieldAuthor:          strings.Join(authors, "; "),
+		SearchFieldTableOfContents: strings.Join(names, "; "),
+	}
+}
+
+// matchBookText ranks the book against the search terms.
+// It is the search used by the databases without full-text search.
+// Like the postgres search every term has to appear in the book,
+// otherwise ok is false.
+func matchBookText(book *models.Book, contents []models.Content, terms []string) (match BookMatch, ok bool) {
+
+	fields := searchableFields(book, contents)
+	lowered := make(map[string]string, len(fields))
+	for field, text := range fields {
+		lowered[field] = strings.ToLower(text)
+	}
+
+	for _, term := range terms {
+		found := false
+		for field, text := range lowered {
+			if n := strings.Count(text, term); n > 0 {
+				found = true
+				// repeated matches count less and less
+				match.Rank += searchWeights[field] * (1 - 1/float64(n+1))
+			}
+		}
+		if !found {
+			return BookMatch{}, false
+		}
+	}
+
+	match.Book = *book
+	match.Book.TableOfContents = models.ContentTree(contents)
+	match.Highlights = make(map[string]string)
+	for field, text := range fields {
+		if snippet, ok := highlight(text, terms); ok {
+			match.Highlights[field] = snippet
+		}
+	}
+
+	return match, true
+}
+
+// highlight wraps the terms in text with <mark></mark> and cuts long text
+// around the first match. ok is false when no term appears in text.
+func highlight(text string, terms []string) (snippet string, ok bool) {
+
+	runes := []rune(text)
+	lowered := []rune(strings.ToLower(text))
+	if len(lowered) != len(runes) {
+		// lower casing changed the length, match on the text as is
+		lowered = runes
+	}
+
+	// find the matching ranges
+	marked := make([]bool, len(runes))
+	first := -1
+	for _, term := range terms {
+		t := []rune(term)
+		for i := 0; i+len(t) <= len(lowered); i++ {
+			if string(lowered[i:i+len(t)]) != term {
+				continue
+			}
+			for j := i; j < i+len(t); j++ {
+				marked[j] = true
+			}
+			if first == -1 || i < first {
+				first = i
+			}
+		}
+	}
+	if first == -1 {
+		return "", false
+	}
+
+	start, end := 0, len(runes)
+	if start < first-snippetRadius {
+		start = first - snippetRadius
+	}
+	if end > first+snippetRadius*2 {
+		end = first + snippetRadius*2
+	}
+
+	var b strings.Builder
+	if start > 0 {
+		b.WriteString("…")
+	}
+	for i := start; i < end; i++ {
+		if marked[i] && (i == start || !marked[i-1]) {
+			b.WriteString(highlightStart)
+		}
+		b.WriteRune(runes[i])
+		if marked[i] && (i == end-1 || !marked[i+1]) {
+			b.WriteString(highlightStop)
+		}
+	}
+	if end < len(runes) {
+		b.WriteString("…")
+	}
+
+	return b.String(), true
+}
+
+// sortMatches orders the matches by rank, then by id, and keeps at most limit of them
+func sortMatches(matches []BookMatch, limit int) []BookMatch {
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Rank != matches[j].Rank {
+			return matches[i].Rank > matches[j].Rank
+		}
+		return matches[i].Book.ID < matches[j].Book.ID
+	})
+
+	return paginate(matches, limit, 0)
+}
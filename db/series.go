@@ -0,0 +1,262 @@
+package db
+
+import (
+	"errors"
+	"strings"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrSeriesNotFound No such series exists
+	ErrSeriesNotFound = errors.New("no such series exists")
+	// ErrSeriesNameIsInUse There exists another series with the same name
+	ErrSeriesNameIsInUse = errors.New("name is in use by another series")
+	// ErrSeriesHasBooks The series has books and can not be deleted
+	ErrSeriesHasBooks = errors.New("the series has books")
+	// ErrUnknownSeries The book is in a series id that does not exist
+	ErrUnknownSeries = errors.New("the book is in a series that does not exist")
+	// ErrNoNextVolume The user has the last volume of the series
+	ErrNoNextVolume = errors.New("the user has the last volume of the series")
+)
+
+// SeriesQuery filters and paginates the series returned by GetAllSeries.
+// The series are ordered by name and id.
+type SeriesQuery struct {
+	// Name matches a part of the name, ignoring case
+	Name string
+
+	// Limit is the maximum number of series returned, zero means no limit
+	Limit  int
+	Offset int
+}
+
+// SeriesProgress is how much of a series a user has. The volumes of the
+// series run from 1 to the total number of volumes or, when it is not
+// known, to the last volume of any book in the series.
+type SeriesProgress struct {
+	SeriesID     uint `json:"series_id"`
+	TotalVolumes int  `json:"total_volumes"`
+	// Owned are the volumes the user has a book of, in order
+	Owned []int `json:"owned"`
+	// Missing are the volumes of the series the user has no book of, in order
+	Missing []int `json:"missing"`
+	// Next is the volume after the last one the user has, zero when the user has the last volume
+	Next int `json:"next"`
+}
+
+// seriesProgress computes the progress of a user in the series from the volumes
+// of all the books of the series and from the volumes of the books of the user
+func seriesProgress(series *models.Series, volumes []int, owned []int) *SeriesProgress {
+
+	progress := &SeriesProgress{SeriesID: series.ID, TotalVolumes: series.TotalVolumes, Owned: []int{}, Missing: []int{}}
+
+	last := series.TotalVolumes
+	for _, volume := range volumes {
+		if volume > last {
+			last = volume
+		}
+	}
+
+	has := make(map[int]bool, len(owned))
+	for _, volume := range owned {
+		if volume > 0 && !has[volume] {
+			has[volume] = true
+			progress.Owned = append(progress.Owned, volume)
+		}
+	}
+
+	progress.Next = 1
+	for volume := 1; volume <= last; volume++ {
+		if has[volume] {
+			progress.Next = volume + 1
+		} else {
+			progress.Missing = append(progress.Missing, volume)
+		}
+	}
+	if series.TotalVolumes > 0 && progress.Next > series.TotalVolumes {
+		progress.Next = 0
+	}
+
+	return progress
+}
+
+// CreateSeries adds the series. The unique index of the series table
+// makes sure no other series has the same name, ignoring case.
+func (gdb *GormDB) CreateSeries(series *models.Series) error {
+
+	series.ID = 0
+	normalizeSeries(series)
+	return translateError(gdb.db.Create(series).Error)
+
+}
+
+func (gdb *GormDB) GetSeries(id uint) (*models.Series, error) {
+
+	var series models.Series
+	err := gdb.db.Where("id = ?", id).First(&series).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSeriesNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &series, nil
+
+}
+
+// GetAllSeries returns a page of the series matching the query
+// and the number of matching series on all the pages
+func (gdb *GormDB) GetAllSeries(query SeriesQuery) ([]models.Series, int64, error) {
+
+	tx := gdb.db.Model(models.Series{})
+	if query.Name != "" {
+		tx = tx.Where(`LOWER(name) LIKE ? ESCAPE '\'`, "%"+escapeLike(strings.ToLower(query.Name))+"%")
+	}
+	tx = tx.Session(&gorm.Session{})
+
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	tx = tx.Order("name").Order("id")
+	if query.Limit > 0 {
+		tx = tx.Limit(query.Limit)
+	}
+	if query.Offset > 0 {
+		tx = tx.Offset(query.Offset)
+	}
+
+	series := []models.Series{}
+	if err := tx.Find(&series).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return series, total, nil
+
+}
+
+// UpdateSeries replaces every field of the series with the same id
+func (gdb *GormDB) UpdateSeries(series *models.Series) error {
+
+	normalizeSeries(series)
+	result := gdb.db.Model(&models.Series{}).Where("id = ?", series.ID).Select("*").Omit("id").Updates(series)
+	if result.Error != nil {
+		return translateError(result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrSeriesNotFound
+	}
+
+	return nil
+
+}
+
+// DeleteSeries deletes the series if it has no book
+func (gdb *GormDB) DeleteSeries(id uint) error {
+
+	return gdb.db.Transaction(func(tx *gorm.DB) error {
+
+		var count int64
+		if err := tx.Model(&models.Book{}).Where("series_id = ?", id).Count(&count).Error; err != nil {
+			return err
+		}
+		if count > 0 {
+			return ErrSeriesHasBooks
+		}
+
+		result := tx.Delete(&models.Series{}, id)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrSeriesNotFound
+		}
+
+		return nil
+	})
+
+}
+
+// GetSeriesProgress returns the volumes of the series the user has, misses and reads next
+func (gdb *GormDB) GetSeriesProgress(seriesID uint, userID uint) (*SeriesProgress, error) {
+
+	series, err := gdb.GetSeries(seriesID)
+	if err != nil {
+		return nil, err
+	}
+
+	volumes := gdb.db.Model(&models.Book{}).Distinct("volumn").Where("series_id = ? AND volumn > 0", seriesID).
+		Order("volumn").Session(&gorm.Session{})
+
+	var all, owned []int
+	if err := volumes.Pluck("volumn", &all).Error; err != nil {
+		return nil, err
+	}
+	if err := volumes.Where("user_id = ?", userID).Pluck("volumn", &owned).Error; err != nil {
+		return nil, err
+	}
+
+	return seriesProgress(series, all, owned), nil
+
+}
+
+// setBookSeries links the book to its series. Like the publishers, a series with
+// an id has to exist and the others are looked up by name and created if there is none.
+func setBookSeries(tx *gorm.DB, book *models.Book) error {
+
+	book.SeriesID = nil
+	if book.Series == nil {
+		return nil
+	}
+
+	if err := findOrCreateSeries(tx, book.Series); err != nil {
+		return err
+	}
+
+	book.SeriesID = &book.Series.ID
+	return nil
+}
+
+// findOrCreateSeries replaces series with the stored series of the same id or,
+// without an id, of the same name ignoring case. A series with a new name is created.
+func findOrCreateSeries(tx *gorm.DB, series *models.Series) error {
+
+	if series.ID != 0 {
+		err := tx.Where("id = ?", series.ID).First(series).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrUnknownSeries
+		}
+		return err
+	}
+
+	// another request may create the same series in the meantime,
+	// in which case the insert does nothing and the series is read back
+	normalizeSeries(series)
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(series)
+	if result.Error != nil || result.RowsAffected == 1 {
+		return result.Error
+	}
+
+	name := series.Name
+	*series = models.Series{}
+	return tx.Where("LOWER(name) = LOWER(?)", name).First(series).Error
+}
+
+// normalizeSeries trims the name of the series and collapses its spaces
+func normalizeSeries(series *models.Series) {
+	series.Name = normalizeName(series.Name)
+}
+
+// seriesName returns the name of the series of the book, empty when it has none
+func seriesName(book *models.Book) string {
+
+	if book.Series == nil {
+		return ""
+	}
+
+	return book.Series.Name
+}
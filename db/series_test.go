@@ -0,0 +1,40 @@
+package db
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+)
+
+func TestSeriesProgress(t *testing.T) {
+
+	tests := []struct {
+		name        string
+		total       int
+		volumes     []int
+		owned       []int
+		wantMissing []int
+		wantOwned   []int
+		wantNext    int
+	}{
+		{"no books", 0, nil, nil, []int{}, []int{}, 1},
+		{"no books of a known total", 3, nil, nil, []int{1, 2, 3}, []int{}, 1},
+		{"gap", 5, []int{1, 2, 4}, []int{1, 2, 4}, []int{3, 5}, []int{1, 2, 4}, 5},
+		{"gap up to the last book", 0, []int{1, 2, 5}, []int{1, 2}, []int{3, 4, 5}, []int{1, 2}, 3},
+		{"duplicates", 0, []int{1, 2, 2, 3}, []int{2, 2, 3}, []int{1}, []int{2, 3}, 4},
+		{"books with no volume", 0, []int{0, 0}, []int{0}, []int{}, []int{}, 1},
+		{"books with no volume of a known total", 2, []int{0, 1}, []int{0, 1}, []int{2}, []int{1}, 2},
+		{"last volume", 3, []int{1, 2, 3}, []int{1, 2, 3}, []int{}, []int{1, 2, 3}, 0},
+		{"last volume after a gap", 3, []int{1, 2, 3}, []int{3}, []int{1, 2}, []int{3}, 0},
+	}
+
+	for _, tt := range tests {
+		series := &models.Series{ID: 7, TotalVolumes: tt.total}
+		progress := seriesProgress(series, tt.volumes, tt.owned)
+		if progress.SeriesID != 7 || progress.TotalVolumes != tt.total || !reflect.DeepEqual(progress.Owned, tt.wantOwned) ||
+			!reflect.DeepEqual(progress.Missing, tt.wantMissing) || progress.Next != tt.wantNext {
+			t.Errorf("%s: progress = %+v, want owned %v, missing %v, next %d", tt.name, progress, tt.wantOwned, tt.wantMissing, tt.wantNext)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package db
+
+import (
+	"errors"
+	"time"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrSessionNotFound No such session exists
+	ErrSessionNotFound = errors.New("no such session exists")
+	// ErrSessionRevoked The session was logged out or has expired
+	ErrSessionRevoked = errors.New("the session is revoked or expired")
+	// ErrRefreshTokenNotFound No session has such a refresh token
+	ErrRefreshTokenNotFound = errors.New("no such refresh token exists")
+	// ErrRefreshTokenReused The refresh token was already exchanged, the session is revoked
+	ErrRefreshTokenReused = errors.New("the refresh token was already used")
+)
+
+// CreateSession stores a new session of a user with its first refresh token
+func (gdb *GormDB) CreateSession(session *models.Session, refreshTokenHash string) error {
+
+	session.RefreshTokens = []models.RefreshToken{{TokenHash: refreshTokenHash}}
+	return gdb.db.Create(session).Error
+
+}
+
+// GetSession When there is an error nil is returned instead of a session
+func (gdb *GormDB) GetSession(id uint) (*models.Session, error) {
+
+	var session models.Session
+	err := gdb.db.Where("id = ?", id).First(&session).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrSessionNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &session, nil
+
+}
+
+// RotateRefreshToken exchanges a refresh token for a new one and returns its session.
+// When an already exchanged token is presented again, the token was stolen by someone,
+// so the whole session is revoked and ErrRefreshTokenReused is returned.
+func (gdb *GormDB) RotateRefreshToken(tokenHash string, newTokenHash string) (*models.Session, error) {
+
+	var session models.Session
+	var reused bool
+	err := gdb.db.Transaction(func(tx *gorm.DB) error {
+
+		// find the token and its session
+		var token models.RefreshToken
+		err := tx.Where("token_hash = ?", tokenHash).First(&token).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return ErrRefreshTokenNotFound
+		} else if err != nil {
+			return err
+		}
+		if err := tx.Where("id = ?", token.SessionID).First(&session).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		if session.RevokedAt != nil || now.After(session.ExpiresAt) {
+			return ErrSessionRevoked
+		}
+
+		// mark the token as used, unless a concurrent request did it first
+		result := tx.Model(&models.RefreshToken{}).Where("id = ? AND used_at IS NULL", token.ID).Update("used_at", now)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			reused = true
+			return tx.Model(&session).Update("revoked_at", now).Error
+		}
+
+		return tx.Create(&models.RefreshToken{SessionID: session.ID, TokenHash: newTokenHash}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	if reused {
+		return nil, ErrRefreshTokenReused
+	}
+
+	return &session, nil
+
+}
+
+// RevokeSession ends the session, its tokens are rejected afterwards
+func (gdb *GormDB) RevokeSession(id uint) error {
+
+	result := gdb.db.Model(&models.Session{}).Where("id = ? AND revoked_at IS NULL", id).Update("revoked_at", time.Now())
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		if _, err := gdb.GetSession(id); err != nil {
+			return err
+		}
+	}
+
+	return nil
+
+}
@@ -0,0 +1,135 @@
+package db
+
+import "github.com/Parsa-Sh-Y/book-manager-service/db/models"
+
+// UserStore keeps the user accounts of the service.
+type UserStore interface {
+	CreateUser(user *models.User) error
+	IsUsernamePresent(username string) (bool, error)
+	GetUserByUsername(username string) (*models.User, error)
+	GetUserByID(id uint) (*models.User, error)
+	GetAllUsers() ([]models.User, error)
+	UpdateUserRole(username string, role string) error
+	DeleteUser(username string) error
+}
+
+// SessionStore keeps the login sessions and their refresh tokens.
+type SessionStore interface {
+	CreateSession(session *models.Session, refreshTokenHash string) error
+	GetSession(id uint) (*models.Session, error)
+	RotateRefreshToken(tokenHash string, newTokenHash string) (*models.Session, error)
+	RevokeSession(id uint) error
+}
+
+// BookStore keeps the books with their table of contents, authors, publisher, category, series and tags.
+// The authors, the publisher and the series of a book are looked up by their id, or by their
+// name and created if none has it. The category has to exist.
+type BookStore interface {
+	CreateBook(book *models.Book) error
+	// CreateBooks creates the books like CreateBook, all at once. It returns the error of each
+	// book, nil for the books created, and an error when none of them could be created.
+	CreateBooks(books []*models.Book) ([]error, error)
+	GetBook(id int) (*models.Book, error)
+	// GetUserBookByISBN returns the book of the user with the ISBN, hyphenated or not,
+	// in either of its forms. A user has at most one book with each ISBN.
+	GetUserBookByISBN(userID uint, isbn string) (*models.Book, error)
+	GetAllBooks(query BookQuery) (*[]models.Book, int64, error)
+	SearchBooks(text string, limit int) ([]BookMatch, error)
+	// DeleteBook deletes the book if it is still at version,
+	// otherwise it returns ErrBookVersionMismatch
+	DeleteBook(id uint, version uint) error
+	DeleteUserBook(username string, bookId uint, version uint) error
+	// UpdateBook replaces the book with the same id, along with its table of contents,
+	// if the book is still at version. The version of book is set to the new version.
+	UpdateBook(book *models.Book, version uint) error
+	UpdateUserBook(username string, book *models.Book, version uint) error
+}
+
+// ContentStore keeps the tables of contents of the books.
+// Every change increments the version of the book, and is only made
+// if the book is still at version, see UpdateBook.
+type ContentStore interface {
+	GetContents(bookID uint) ([]models.Content, error)
+	CreateContent(content *models.Content, version uint) error
+	UpdateContent(content *models.Content, version uint) error
+	DeleteContent(bookID uint, contentID uint, version uint) error
+}
+
+// AuthorStore keeps the authors credited in the books.
+type AuthorStore interface {
+	CreateAuthor(author *models.Author) error
+	GetAuthor(id uint) (*models.Author, error)
+	GetAllAuthors(query AuthorQuery) ([]models.Author, int64, error)
+	// UpdateAuthor replaces the author with the same id
+	UpdateAuthor(author *models.Author) error
+	// DeleteAuthor deletes the author, or returns ErrAuthorHasBooks if a book credits it
+	DeleteAuthor(id uint) error
+}
+
+// PublisherStore keeps the publishers of the books.
+// The names of the publishers are unique, ignoring case.
+type PublisherStore interface {
+	CreatePublisher(publisher *models.Publisher) error
+	GetPublisher(id uint) (*models.Publisher, error)
+	GetAllPublishers(query PublisherQuery) ([]models.Publisher, int64, error)
+	// UpdatePublisher replaces the publisher with the same id
+	UpdatePublisher(publisher *models.Publisher) error
+	// DeletePublisher deletes the publisher, or returns ErrPublisherHasBooks if it published a book
+	DeletePublisher(id uint) error
+}
+
+// CategoryStore keeps the tree of the categories of the books.
+// The names of the categories with the same parent are unique, ignoring case.
+type CategoryStore interface {
+	CreateCategory(category *models.Category) error
+	// GetCategory returns the category with its subcategories
+	GetCategory(id uint) (*models.Category, error)
+	GetCategoryTree() ([]models.Category, error)
+	// UpdateCategory renames the category with the same id and moves it under its parent
+	UpdateCategory(category *models.Category) error
+	// DeleteCategory deletes the category after moving its books and subcategories
+	// to the category moveTo, or returns ErrCategoryNotEmpty if moveTo is zero and it has any
+	DeleteCategory(id uint, moveTo uint) error
+}
+
+// TagStore keeps the tags of the books. A tag exists as long as a book has it.
+type TagStore interface {
+	GetAllTags(query TagQuery) ([]TagCount, int64, error)
+	// RenameTag renames the tag on all its books, merging it with the tag named newName if there is one
+	RenameTag(name string, newName string) error
+	DeleteTag(name string) error
+}
+
+// SeriesStore keeps the series the books are volumes of.
+// The names of the series are unique, ignoring case.
+type SeriesStore interface {
+	CreateSeries(series *models.Series) error
+	GetSeries(id uint) (*models.Series, error)
+	GetAllSeries(query SeriesQuery) ([]models.Series, int64, error)
+	// UpdateSeries replaces the series with the same id
+	UpdateSeries(series *models.Series) error
+	// DeleteSeries deletes the series, or returns ErrSeriesHasBooks if it has a book
+	DeleteSeries(id uint) error
+	// GetSeriesProgress returns the volumes of the series the user has books of and misses
+	GetSeriesProgress(seriesID uint, userID uint) (*SeriesProgress, error)
+}
+
+// Store is the complete storage used by the service.
+// Both GormDB and MemoryDB implement it.
+type Store interface {
+	UserStore
+	SessionStore
+	BookStore
+	ContentStore
+	AuthorStore
+	PublisherStore
+	CategoryStore
+	TagStore
+	SeriesStore
+	CreateSchema() error
+}
+
+var (
+	_ Store = (*GormDB)(nil)
+	_ Store = (*MemoryDB)(nil)
+)
@@ -0,0 +1,490 @@
+package db
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/config"
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+)
+
+// testPostgresDSN is the environment variable with the DSN of a postgres database
+// the tests also run on. The database is emptied by every test.
+const testPostgresDSN = "TEST_POSTGRES_DSN"
+
+// forEachStore runs test on a new MemoryDB, a new GormDB on an in-memory sqlite database
+// and, when TEST_POSTGRES_DSN is set, a GormDB on an empty postgres database
+func forEachStore(t *testing.T, test func(t *testing.T, store Store)) {
+
+	t.Run("memory", func(t *testing.T) {
+		test(t, CreateNewMemoryDB())
+	})
+
+	t.Run("sqlite", func(t *testing.T) {
+		var conf config.Config
+		conf.Database.Driver, conf.Database.Path = DriverSQLite, SQLiteInMemory
+		gdb, err := CreateNewGormDB(conf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		test(t, newTestGormDB(t, gdb))
+	})
+
+	dsn := os.Getenv(testPostgresDSN)
+	if dsn == "" {
+		return
+	}
+	t.Run("postgres", func(t *testing.T) {
+		db, err := gorm.Open(postgres.Open(dsn), &gorm.Config{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Exec("DROP SCHEMA public CASCADE; CREATE SCHEMA public").Error; err != nil {
+			t.Fatal(err)
+		}
+		test(t, newTestGormDB(t, &GormDB{db: db}))
+	})
+}
+
+// newTestGormDB creates the schema of gdb and closes it at the end of the test
+func newTestGormDB(t *testing.T, gdb *GormDB) *GormDB {
+
+	t.Helper()
+	sqlDB, err := gdb.db.DB()
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { sqlDB.Close() })
+
+	if err := gdb.CreateSchema(); err != nil {
+		t.Fatal(err)
+	}
+
+	return gdb
+}
+
+// createUsers creates the users with the names and returns them in order
+func createUsers(t *testing.T, store Store, names ...string) []models.User {
+
+	t.Helper()
+	users := make([]models.User, len(names))
+	for i, name := range names {
+		users[i] = models.User{Username: name, Email: name + "@example.com", Password: "Passw0rd!", Role: models.RoleMember}
+		if err := store.CreateUser(&users[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return users
+}
+
+// date returns the midnight of the day in UTC
+func date(year int, month time.Month, day int) time.Time {
+	return time.Date(year, month, day, 0, 0, 0, 0, time.UTC)
+}
+
+// bookIDs returns the ids of the books
+func bookIDs(books []models.Book) []uint {
+
+	ids := []uint{}
+	for _, book := range books {
+		ids = append(ids, book.ID)
+	}
+	return ids
+}
+
+func TestStoreGetAllBooks(t *testing.T) {
+
+	forEachStore(t, func(t *testing.T, store Store) {
+
+		users := createUsers(t, store, "ali", "reza")
+		fiction := models.Category{Name: "Fiction"}
+		history := models.Category{Name: "History"}
+		for _, category := range []*models.Category{&fiction, &history} {
+			if err := store.CreateCategory(category); err != nil {
+				t.Fatal(err)
+			}
+		}
+		scienceFiction := models.Category{Name: "Science Fiction", ParentID: &fiction.ID}
+		if err := store.CreateCategory(&scienceFiction); err != nil {
+			t.Fatal(err)
+		}
+
+		author := func(first, last string) []models.BookAuthor {
+			return []models.BookAuthor{{Role: models.AuthorRoleAuthor, Author: models.Author{FirstName: first, LastName: last}}}
+		}
+		books := []models.Book{
+			{Name: "Dune", ISBN13: "9780441172719", Authors: author("Frank", "Herbert"), Publisher: &models.Publisher{Name: "Ace Books"},
+				Category: &models.Category{ID: scienceFiction.ID}, Series: &models.Series{Name: "Dune"}, Volumn: 1,
+				PublishedAt: date(1965, time.August, 1), Tags: []models.Tag{{Name: "classic"}, {Name: "sci-fi"}}, UserID: users[0].ID},
+			{Name: "Dune Messiah", Authors: author("Frank", "Herbert"), Publisher: &models.Publisher{Name: "Putnam"},
+				Category: &models.Category{ID: scienceFiction.ID}, Series: &models.Series{Name: "Dune"}, Volumn: 2,
+				PublishedAt: date(1969, time.October, 15), Tags: []models.Tag{{Name: "sci-fi"}}, UserID: users[0].ID},
+			{Name: "Emma", Authors: author("Jane", "Austen"), Publisher: &models.Publisher{Name: "John Murray"},
+				Category: &models.Category{ID: fiction.ID}, PublishedAt: date(1815, time.December, 23),
+				Tags: []models.Tag{{Name: "Classic"}}, UserID: users[1].ID},
+			{Name: "The Guns of August", Authors: author("Barbara", "Tuchman"), Publisher: &models.Publisher{Name: "Macmillan"},
+				Category: &models.Category{ID: history.ID}, PublishedAt: date(1962, time.January, 1), UserID: users[1].ID},
+			{Name: "Children of Dune", Authors: author("Frank", "Herbert"), Publisher: &models.Publisher{Name: "Putnam"},
+				Category: &models.Category{ID: scienceFiction.ID}, Series: &models.Series{Name: "Dune"}, Volumn: 3,
+				PublishedAt: date(1976, time.April, 1), UserID: users[0].ID},
+			// no author, publisher or category
+			{Name: "Anonymous Notes", Series: &models.Series{Name: "Notes"}, Volumn: 2, PublishedAt: date(2001, time.January, 1), UserID: users[0].ID},
+		}
+		for i := range books {
+			if err := store.CreateBook(&books[i]); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		// the books by their number, from 1
+		ids := func(numbers ...int) []uint {
+			list := []uint{}
+			for _, n := range numbers {
+				list = append(list, books[n-1].ID)
+			}
+			return list
+		}
+		volume := func(v int) *int { return &v }
+		after, before := date(1965, time.August, 1), date(1970, time.January, 1)
+
+		tests := []struct {
+			name  string
+			query BookQuery
+			want  []uint
+			total int64
+		}{
+			{"all", BookQuery{}, ids(1, 2, 3, 4, 5, 6), 6},
+			{"by id", BookQuery{SortBy: SortByID}, ids(1, 2, 3, 4, 5, 6), 6},
+			{"by name", BookQuery{SortBy: SortByName}, ids(6, 5, 1, 2, 3, 4), 6},
+			{"by category", BookQuery{SortBy: SortByCategory}, ids(6, 3, 4, 1, 2, 5), 6},
+			{"by publisher", BookQuery{SortBy: SortByPublisher}, ids(6, 1, 3, 4, 2, 5), 6},
+			{"by author", BookQuery{SortBy: SortByAuthor}, ids(6, 3, 1, 2, 5, 4), 6},
+			{"by series", BookQuery{SortBy: SortBySeries}, ids(3, 4, 1, 2, 5, 6), 6},
+			{"by volume", BookQuery{SortBy: SortByVolume}, ids(3, 4, 1, 2, 6, 5), 6},
+			{"by published date", BookQuery{SortBy: SortByPublishedAt}, ids(3, 4, 1, 2, 5, 6), 6},
+			{"by volume descending", BookQuery{SortBy: SortByVolume, Descending: true}, ids(5, 6, 2, 1, 4, 3), 6},
+			{"by author descending", BookQuery{SortBy: SortByAuthor, Descending: true}, ids(4, 5, 2, 1, 3, 6), 6},
+			{"page", BookQuery{SortBy: SortByName, Limit: 2, Offset: 1}, ids(5, 1), 6},
+			{"past the last page", BookQuery{Limit: 2, Offset: 6}, ids(), 6},
+			{"category and its subcategories", BookQuery{Category: "fiction"}, ids(1, 2, 3, 5), 4},
+			{"category id", BookQuery{CategoryID: history.ID}, ids(4), 1},
+			{"tag", BookQuery{Tags: []string{"Classic"}}, ids(1, 3), 2},
+			{"every tag", BookQuery{Tags: []string{"classic", "sci-fi"}}, ids(1), 1},
+			{"publisher", BookQuery{Publisher: "putnam"}, ids(2, 5), 2},
+			{"publisher id", BookQuery{PublisherID: *books[0].PublisherID}, ids(1), 1},
+			{"part of an author", BookQuery{Author: "HERB"}, ids(1, 2, 5), 3},
+			{"full name of an author", BookQuery{Author: "jane austen"}, ids(3), 1},
+			{"author id", BookQuery{AuthorID: books[3].Authors[0].AuthorID}, ids(4), 1},
+			{"series", BookQuery{SeriesID: *books[0].SeriesID, SortBy: SortByVolume, Descending: true}, ids(5, 2, 1), 3},
+			{"isbn", BookQuery{ISBN13: "9780441172719"}, ids(1), 1},
+			{"user", BookQuery{UserID: users[1].ID}, ids(3, 4), 2},
+			{"after id", BookQuery{AfterID: books[3].ID}, ids(5, 6), 2},
+			{"volumes", BookQuery{MinVolume: volume(2)}, ids(2, 5, 6), 3},
+			{"volumes up to", BookQuery{MaxVolume: volume(1)}, ids(1, 3, 4), 3},
+			{"published between", BookQuery{PublishedAfter: &after, PublishedBefore: &before}, ids(1, 2), 2},
+			{"filters and order", BookQuery{Category: "Fiction", UserID: users[0].ID, SortBy: SortByName, Descending: true}, ids(2, 1, 5), 3},
+			{"nothing", BookQuery{Publisher: "Penguin"}, ids(), 0},
+		}
+
+		for _, tt := range tests {
+			got, total, err := store.GetAllBooks(tt.query)
+			if err != nil {
+				t.Errorf("%s: %v", tt.name, err)
+				continue
+			}
+			if ids := bookIDs(*got); !reflect.DeepEqual(ids, tt.want) || total != tt.total {
+				t.Errorf("%s: books %v of %d, want %v of %d", tt.name, ids, total, tt.want, tt.total)
+			}
+		}
+
+		if _, _, err := store.GetAllBooks(BookQuery{SortBy: "rating"}); !errors.Is(err, ErrInvalidSortField) {
+			t.Errorf("sort by rating: %v, want %v", err, ErrInvalidSortField)
+		}
+	})
+}
+
+func TestStoreISBNIsUnique(t *testing.T) {
+
+	forEachStore(t, func(t *testing.T, store Store) {
+
+		users := createUsers(t, store, "ali", "reza")
+		ali, reza := users[0].ID, users[1].ID
+
+		dune := models.Book{Name: "Dune", ISBN13: "9780441172719", UserID: ali}
+		if err := store.CreateBook(&dune); err != nil {
+			t.Fatal(err)
+		}
+
+		tests := []struct {
+			name string
+			book models.Book
+			want error
+		}{
+			{"same isbn 13", models.Book{Name: "Dune", ISBN13: "9780441172719", UserID: ali}, ErrISBNIsInUse},
+			{"same number as an isbn 10", models.Book{Name: "Dune", ISBN10: "0441172717", UserID: ali}, ErrISBNIsInUse},
+			{"hyphenated", models.Book{Name: "Dune", ISBN13: "978-0-441-17271-9", UserID: ali}, ErrISBNIsInUse},
+			{"another user", models.Book{Name: "Dune", ISBN13: "9780441172719", UserID: reza}, nil},
+			{"without an isbn", models.Book{Name: "Notes", UserID: ali}, nil},
+			{"another one without an isbn", models.Book{Name: "Notes", UserID: ali}, nil},
+			{"mismatched isbns", models.Book{Name: "SICP", ISBN10: "0262510871", ISBN13: "9780441172719", UserID: ali}, ErrISBNMismatch},
+		}
+
+		for _, tt := range tests {
+			if err := store.CreateBook(&tt.book); !errors.Is(err, tt.want) {
+				t.Errorf("%s: CreateBook = %v, want %v", tt.name, err, tt.want)
+			}
+		}
+
+		// the isbn of another book of the user
+		sicp := models.Book{Name: "SICP", ISBN10: "0262510871", UserID: ali}
+		if err := store.CreateBook(&sicp); err != nil {
+			t.Fatal(err)
+		}
+		sicp.ISBN10, sicp.ISBN13 = "", "9780441172719"
+		if err := store.UpdateBook(&sicp, sicp.Version); !errors.Is(err, ErrISBNIsInUse) {
+			t.Errorf("UpdateBook = %v, want %v", err, ErrISBNIsInUse)
+		}
+		if err := store.UpdateUserBook("ali", &sicp, sicp.Version); !errors.Is(err, ErrISBNIsInUse) {
+			t.Errorf("UpdateUserBook = %v, want %v", err, ErrISBNIsInUse)
+		}
+		if book, err := store.GetBook(int(sicp.ID)); err != nil || book.ISBN10 != "0262510871" || book.Version != 1 {
+			t.Errorf("GetBook after the failed updates = %+v, %v, want SICP at version 1", book, err)
+		}
+
+		for _, number := range []string{"9780441172719", "0-441-17271-7", "978-0441172719"} {
+			book, err := store.GetUserBookByISBN(ali, number)
+			if err != nil || book.ID != dune.ID {
+				t.Errorf("GetUserBookByISBN(%q) = %v, %v, want the book %d", number, book, err, dune.ID)
+			}
+		}
+		if _, err := store.GetUserBookByISBN(ali, "9780804429573"); !errors.Is(err, ErrBookNotFound) {
+			t.Errorf("GetUserBookByISBN of another isbn = %v, want %v", err, ErrBookNotFound)
+		}
+	})
+}
+
+func TestStoreCreateBooks(t *testing.T) {
+
+	forEachStore(t, func(t *testing.T, store Store) {
+
+		ali := createUsers(t, store, "ali")[0].ID
+		books := []*models.Book{
+			{Name: "Dune", ISBN13: "9780441172719", Publisher: &models.Publisher{Name: "Ace Books"}, UserID: ali},
+			{Name: "Dune again", ISBN10: "0441172717", UserID: ali},
+			// the publisher of a book that is not created is not created either
+			{Name: "Ghost", Publisher: &models.Publisher{Name: "Ghost Press"}, Category: &models.Category{ID: 42}, UserID: ali},
+			{Name: "Emma", Series: &models.Series{Name: "Austen"}, UserID: ali},
+		}
+
+		errs, err := store.CreateBooks(books)
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := []error{nil, ErrISBNIsInUse, ErrUnknownCategory, nil}
+		if len(errs) != len(want) {
+			t.Fatalf("%d errors, want %d", len(errs), len(want))
+		}
+		for i := range want {
+			if !errors.Is(errs[i], want[i]) {
+				t.Errorf("book %d: %v, want %v", i, errs[i], want[i])
+			}
+		}
+
+		got, total, err := store.GetAllBooks(BookQuery{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if ids := bookIDs(*got); total != 2 || !reflect.DeepEqual(ids, []uint{books[0].ID, books[3].ID}) {
+			t.Errorf("books %v of %d, want %v", ids, total, []uint{books[0].ID, books[3].ID})
+		}
+
+		publishers, _, err := store.GetAllPublishers(PublisherQuery{})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(publishers) != 1 || publishers[0].Name != "Ace Books" {
+			t.Errorf("publishers = %+v, want only Ace Books", publishers)
+		}
+	})
+}
+
+func TestStoreRotateRefreshToken(t *testing.T) {
+
+	forEachStore(t, func(t *testing.T, store Store) {
+
+		ali := createUsers(t, store, "ali")[0].ID
+		session := models.Session{UserID: ali, ExpiresAt: time.Now().Add(time.Hour)}
+		if err := store.CreateSession(&session, "token-1"); err != nil {
+			t.Fatal(err)
+		}
+
+		rotated, err := store.RotateRefreshToken("token-1", "token-2")
+		if err != nil || rotated.ID != session.ID {
+			t.Fatalf("RotateRefreshToken = %v, %v, want the session %d", rotated, err, session.ID)
+		}
+
+		// the used token presented again revokes the session with its newer tokens
+		if _, err := store.RotateRefreshToken("token-1", "token-3"); !errors.Is(err, ErrRefreshTokenReused) {
+			t.Errorf("reused token: %v, want %v", err, ErrRefreshTokenReused)
+		}
+		if revoked, err := store.GetSession(session.ID); err != nil || revoked.RevokedAt == nil {
+			t.Errorf("GetSession = %+v, %v, want a revoked session", revoked, err)
+		}
+		if _, err := store.RotateRefreshToken("token-2", "token-4"); !errors.Is(err, ErrSessionRevoked) {
+			t.Errorf("token of the revoked session: %v, want %v", err, ErrSessionRevoked)
+		}
+		if _, err := store.RotateRefreshToken("token-3", "token-5"); !errors.Is(err, ErrRefreshTokenNotFound) {
+			t.Errorf("token of the reuse: %v, want %v", err, ErrRefreshTokenNotFound)
+		}
+
+		expired := models.Session{UserID: ali, ExpiresAt: time.Now().Add(-time.Minute)}
+		if err := store.CreateSession(&expired, "token-6"); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := store.RotateRefreshToken("token-6", "token-7"); !errors.Is(err, ErrSessionRevoked) {
+			t.Errorf("token of the expired session: %v, want %v", err, ErrSessionRevoked)
+		}
+
+		if _, err := store.RotateRefreshToken("unknown", "token-8"); !errors.Is(err, ErrRefreshTokenNotFound) {
+			t.Errorf("unknown token: %v, want %v", err, ErrRefreshTokenNotFound)
+		}
+	})
+}
+
+func TestStoreUsers(t *testing.T) {
+
+	forEachStore(t, func(t *testing.T, store Store) {
+
+		ali := models.User{Username: "ali", Email: "ali@example.com", Password: "Passw0rd!", PhoneNumber: "09121234567"}
+		if err := store.CreateUser(&ali); err != nil {
+			t.Fatal(err)
+		}
+		if ali.ID == 0 || ali.Role != models.RoleMember || ali.Password == "Passw0rd!" {
+			t.Errorf("created user = %+v, want an id, the member role and a hashed password", ali)
+		}
+
+		tests := []struct {
+			name string
+			user models.User
+			want error
+		}{
+			{"same username", models.User{Username: "ali", Email: "ali2@example.com"}, ErrUsernameIsInUse},
+			{"same email", models.User{Username: "ali2", Email: "ali@example.com"}, ErrEmailIsInUse},
+			{"same phone number", models.User{Username: "ali3", Email: "ali3@example.com", PhoneNumber: "09121234567"}, ErrPhoneNumberIsInUse},
+			{"no phone number", models.User{Username: "reza", Email: "reza@example.com"}, nil},
+			{"no phone number either", models.User{Username: "sara", Email: "sara@example.com"}, nil},
+		}
+
+		for _, tt := range tests {
+			tt.user.Password = "Passw0rd!"
+			if err := store.CreateUser(&tt.user); !errors.Is(err, tt.want) {
+				t.Errorf("%s: CreateUser = %v, want %v", tt.name, err, tt.want)
+			}
+		}
+
+		user, err := store.GetUserByUsername("ali")
+		if err != nil || user.ID != ali.ID || user.Email != ali.Email || user.PhoneNumber != ali.PhoneNumber {
+			t.Errorf("GetUserByUsername(ali) = %+v, %v, want %+v", user, err, ali)
+		}
+		if _, err := store.GetUserByUsername("Ali"); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("GetUserByUsername(Ali) = %v, want %v", err, ErrUserNotFound)
+		}
+		if _, err := store.GetUserByUsername("nobody"); !errors.Is(err, ErrUserNotFound) {
+			t.Errorf("GetUserByUsername(nobody) = %v, want %v", err, ErrUserNotFound)
+		}
+		if present, err := store.IsUsernamePresent("reza"); err != nil || !present {
+			t.Errorf("IsUsernamePresent(reza) = %v, %v, want true", present, err)
+		}
+	})
+}
+
+func TestStoreGetBook(t *testing.T) {
+
+	forEachStore(t, func(t *testing.T, store Store) {
+
+		ali := createUsers(t, store, "ali")[0].ID
+		dune := models.Book{Name: "Dune", UserID: ali, TableOfContents: models.ContentsFromNames([]string{"Book One", "Book Two"})}
+		if err := store.CreateBook(&dune); err != nil {
+			t.Fatal(err)
+		}
+
+		book, err := store.GetBook(int(dune.ID))
+		if err != nil || book.Name != "Dune" || book.Version != 1 || book.UserID != ali ||
+			!reflect.DeepEqual(models.ContentNames(book.TableOfContents), []string{"Book One", "Book Two"}) {
+			t.Errorf("GetBook(%d) = %+v, %v", dune.ID, book, err)
+		}
+		if _, err := store.GetBook(int(dune.ID) + 1); !errors.Is(err, ErrBookNotFound) {
+			t.Errorf("GetBook of a missing book = %v, want %v", err, ErrBookNotFound)
+		}
+	})
+}
+
+func TestStoreUserBooks(t *testing.T) {
+
+	forEachStore(t, func(t *testing.T, store Store) {
+
+		createUsers(t, store, "ali", "reza")
+		owner, err := store.GetUserByUsername("ali")
+		if err != nil {
+			t.Fatal(err)
+		}
+		dune := models.Book{Name: "Dune", UserID: owner.ID}
+		emma := models.Book{Name: "Emma", UserID: owner.ID}
+		for _, book := range []*models.Book{&dune, &emma} {
+			if err := store.CreateBook(book); err != nil {
+				t.Fatal(err)
+			}
+		}
+		missing := emma.ID + 1
+
+		tests := []struct {
+			name     string
+			username string
+			id       uint
+			version  uint
+			want     error
+		}{
+			{"another user", "reza", dune.ID, 1, ErrPermissionDenied},
+			{"another user at a stale version", "reza", dune.ID, 7, ErrPermissionDenied},
+			{"unknown user", "nobody", dune.ID, 1, ErrUserNotFound},
+			{"missing book", "ali", missing, 1, ErrBookNotFound},
+			{"stale version", "ali", dune.ID, 7, ErrBookVersionMismatch},
+		}
+
+		for _, tt := range tests {
+			book := models.Book{ID: tt.id, Name: "Dune Messiah"}
+			if err := store.UpdateUserBook(tt.username, &book, tt.version); !errors.Is(err, tt.want) {
+				t.Errorf("%s: UpdateUserBook = %v, want %v", tt.name, err, tt.want)
+			}
+			if err := store.DeleteUserBook(tt.username, tt.id, tt.version); !errors.Is(err, tt.want) {
+				t.Errorf("%s: DeleteUserBook = %v, want %v", tt.name, err, tt.want)
+			}
+		}
+
+		// the failed changes left the book as it was
+		if book, err := store.GetBook(int(dune.ID)); err != nil || book.Name != "Dune" || book.Version != 1 {
+			t.Errorf("GetBook = %+v, %v, want Dune at version 1", book, err)
+		}
+
+		book := models.Book{ID: dune.ID, Name: "Dune Messiah"}
+		if err := store.UpdateUserBook("ali", &book, 1); err != nil || book.Version != 2 {
+			t.Errorf("UpdateUserBook by the owner = %v, version %d, want the version 2", err, book.Version)
+		}
+		if stored, err := store.GetBook(int(dune.ID)); err != nil || stored.Name != "Dune Messiah" || stored.UserID != owner.ID {
+			t.Errorf("GetBook after the update = %+v, %v", stored, err)
+		}
+
+		if err := store.DeleteUserBook("ali", emma.ID, 1); err != nil {
+			t.Errorf("DeleteUserBook by the owner = %v", err)
+		}
+		if _, err := store.GetBook(int(emma.ID)); !errors.Is(err, ErrBookNotFound) {
+			t.Errorf("GetBook of the deleted book = %v, want %v", err, ErrBookNotFound)
+		}
+	})
+}
@@ -0,0 +1,214 @@
+package db
+
+import (
+	"errors"
+	"sort"
+	"strings"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// ErrTagNotFound No book has the tag
+	ErrTagNotFound = errors.New("no book has this tag")
+)
+
+// TagQuery filters and paginates the tags returned by GetAllTags.
+// Only the tags of at least one book are returned, ordered by name.
+type TagQuery struct {
+	// Name matches a part of the name, ignoring case
+	Name string
+
+	// Limit is the maximum number of tags returned, zero means no limit
+	Limit  int
+	Offset int
+}
+
+// TagCount is a tag with the number of books having it
+type TagCount struct {
+	Name  string `json:"name"`
+	Books int64  `json:"books"`
+}
+
+// usedTagCondition matches the tags of at least one book
+const usedTagCondition = "EXISTS (SELECT 1 FROM book_tags WHERE book_tags.tag_id = tags.id)"
+
+// GetAllTags returns a page of the tags matching the query with the number of their books,
+// and the number of matching tags on all the pages
+func (gdb *GormDB) GetAllTags(query TagQuery) ([]TagCount, int64, error) {
+
+	tx := gdb.db.Model(&models.Tag{}).Where(usedTagCondition)
+	if query.Name != "" {
+		tx = tx.Where(`tags.name LIKE ? ESCAPE '\'`, "%"+escapeLike(normalizeTag(query.Name))+"%")
+	}
+	tx = tx.Session(&gorm.Session{})
+
+	var total int64
+	if err := tx.Count(&total).Error; err != nil {
+		return nil, 0, err
+	}
+
+	tx = tx.Select("tags.name, (SELECT COUNT(*) FROM book_tags WHERE book_tags.tag_id = tags.id) AS books").Order("tags.name")
+	if query.Limit > 0 {
+		tx = tx.Limit(query.Limit)
+	}
+	if query.Offset > 0 {
+		tx = tx.Offset(query.Offset)
+	}
+
+	tags := []TagCount{}
+	if err := tx.Scan(&tags).Error; err != nil {
+		return nil, 0, err
+	}
+
+	return tags, total, nil
+
+}
+
+// RenameTag renames the tag on all its books. Renaming it to another tag merges the two.
+func (gdb *GormDB) RenameTag(name string, newName string) error {
+
+	return gdb.db.Transaction(func(tx *gorm.DB) error {
+
+		tag, err := findUsedTag(tx, name)
+		if err != nil {
+			return err
+		}
+
+		target := models.Tag{Name: normalizeTag(newName)}
+		if target.Name == tag.Name {
+			return nil
+		}
+		if err := findOrCreateTag(tx, &target); err != nil {
+			return err
+		}
+		if err := bumpTaggedBooks(tx, tag.ID); err != nil {
+			return err
+		}
+
+		// the books having both tags keep the new one
+		err = tx.Exec(`INSERT INTO book_tags (book_id, tag_id) SELECT book_id, ? FROM book_tags
+			WHERE tag_id = ? AND book_id NOT IN (SELECT book_id FROM book_tags WHERE tag_id = ?)`, target.ID, tag.ID, target.ID).Error
+		if err != nil {
+			return err
+		}
+
+		return tx.Delete(tag).Error
+	})
+
+}
+
+// DeleteTag removes the tag from all its books
+func (gdb *GormDB) DeleteTag(name string) error {
+
+	return gdb.db.Transaction(func(tx *gorm.DB) error {
+
+		tag, err := findUsedTag(tx, name)
+		if err != nil {
+			return err
+		}
+		if err := bumpTaggedBooks(tx, tag.ID); err != nil {
+			return err
+		}
+
+		// the tag is taken off the books by cascade
+		return tx.Delete(tag).Error
+	})
+
+}
+
+// findUsedTag returns the tag with the name if a book has it
+func findUsedTag(tx *gorm.DB, name string) (*models.Tag, error) {
+
+	var tag models.Tag
+	err := tx.Where("name = ?", normalizeTag(name)).Where(usedTagCondition).First(&tag).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrTagNotFound
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &tag, nil
+}
+
+// bumpTaggedBooks increments the version of the books having the tag
+func bumpTaggedBooks(tx *gorm.DB, tagID uint) error {
+
+	return tx.Model(&models.Book{}).Where("id IN (SELECT book_id FROM book_tags WHERE tag_id = ?)", tagID).
+		Update("version", gorm.Expr("version + 1")).Error
+}
+
+// setBookTags puts the tags on the book and returns them ordered by name.
+// The tags are created the first time a book has them.
+func setBookTags(tx *gorm.DB, bookID uint, tags []models.Tag) ([]models.Tag, error) {
+
+	names := tagNames(tags)
+	stored := make([]models.Tag, 0, len(names))
+	for _, name := range names {
+		tag := models.Tag{Name: name}
+		if err := findOrCreateTag(tx, &tag); err != nil {
+			return nil, err
+		}
+		if err := tx.Create(&models.BookTag{BookID: bookID, TagID: tag.ID}).Error; err != nil {
+			return nil, err
+		}
+		stored = append(stored, tag)
+	}
+
+	return stored, nil
+}
+
+// findOrCreateTag replaces tag with the stored tag of the same name, creating it if there is none
+func findOrCreateTag(tx *gorm.DB, tag *models.Tag) error {
+
+	// another request may create the same tag in the meantime,
+	// in which case the insert does nothing and the tag is read back
+	result := tx.Clauses(clause.OnConflict{DoNothing: true}).Create(tag)
+	if result.Error != nil || result.RowsAffected == 1 {
+		return result.Error
+	}
+
+	name := tag.Name
+	*tag = models.Tag{}
+	return tx.Where("name = ?", name).First(tag).Error
+}
+
+// tagNames returns the normalized names of the tags, without the empty and the repeated ones, ordered by name
+func tagNames(tags []models.Tag) []string {
+
+	seen := make(map[string]bool, len(tags))
+	names := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		name := normalizeTag(tag.Name)
+		if name != "" && !seen[name] {
+			seen[name] = true
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+// normalizeTag lower cases the name of a tag, trims it and collapses its spaces
+func normalizeTag(name string) string {
+	return strings.ToLower(normalizeName(name))
+}
+
+// hasTags reports whether the book has every one of the tags, like the tag filter of filterBooks
+func hasTags(book *models.Book, tags []string) bool {
+
+	for _, name := range tags {
+		found := false
+		for _, tag := range book.Tags {
+			found = found || tag.Name == normalizeTag(name)
+		}
+		if !found {
+			return false
+		}
+	}
+
+	return true
+}
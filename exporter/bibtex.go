@@ -0,0 +1,133 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+)
+
+// bibTeXEscaper escapes the characters BibTeX and LaTeX give a meaning to
+var bibTeXEscaper = strings.NewReplacer(
+	`\`, `\textbackslash{}`, `{`, `\{`, `}`, `\}`, `&`, `\&`, `%`, `\%`,
+	`$`, `\$`, `#`, `\#`, `_`, `\_`, `~`, `\textasciitilde{}`, `^`, `\textasciicircum{}`,
+)
+
+// bibTeXRoles are the fields of the credits of each role, the fields of biblatex
+// besides author and editor are kept by the citation managers
+var bibTeXRoles = []string{models.AuthorRoleAuthor, models.AuthorRoleEditor, models.AuthorRoleTranslator, models.AuthorRoleIllustrator}
+
+// BibTeXWriter writes the books as @book entries. The keys are the last name of the
+// first author and the year, such as herbert1965, with a letter added when a key
+// is taken. The table of contents is written in the contents field.
+type BibTeXWriter struct {
+	w io.Writer
+	// keys are the keys of the entries written
+	keys map[string]bool
+}
+
+func NewBibTeXWriter(w io.Writer) *BibTeXWriter {
+	return &BibTeXWriter{w: w, keys: make(map[string]bool)}
+}
+
+func (b *BibTeXWriter) WriteBook(book *models.Book) error {
+
+	var entry strings.Builder
+	fmt.Fprintf(&entry, "@book{%s,\n", b.key(book))
+
+	field := func(name string, value string) {
+		if value = strings.TrimSpace(value); value != "" {
+			fmt.Fprintf(&entry, "  %s = {%s},\n", name, bibTeXEscaper.Replace(value))
+		}
+	}
+
+	field("title", book.Name)
+	for _, role := range bibTeXRoles {
+		var names []string
+		for _, author := range credits(book, role) {
+			names = append(names, invertedName(author))
+		}
+		field(role, strings.Join(names, " and "))
+	}
+	field("publisher", publisherName(book))
+	if year := publishedYear(book); year != 0 {
+		field("year", strconv.Itoa(year))
+	}
+	field("isbn", bookISBN(book))
+	field("series", seriesName(book))
+	if book.Volumn != 0 {
+		field("volume", strconv.Itoa(book.Volumn))
+	}
+	field("keywords", strings.Join(tagNames(book), ", "))
+	field("abstract", book.Summary)
+	field("contents", strings.Join(models.ContentNames(book.TableOfContents), "; "))
+	entry.WriteString("}\n\n")
+
+	_, err := io.WriteString(b.w, entry.String())
+	return err
+}
+
+func (b *BibTeXWriter) Close() error {
+	return nil
+}
+
+// key returns an unused key of the entry of the book
+func (b *BibTeXWriter) key(book *models.Book) string {
+
+	// the first author, or whoever is credited first when the book has no author
+	name := ""
+	if authors := credits(book, models.AuthorRoleAuthor); len(authors) > 0 {
+		name = keyPart(authors[0].LastName)
+	} else if len(book.Authors) > 0 {
+		name = keyPart(book.Authors[0].Author.LastName)
+	}
+	if name == "" {
+		name = keyPart(strings.Join(strings.Fields(book.Name), ""))
+	}
+	if name == "" {
+		name = "book"
+	}
+
+	key := name
+	if year := publishedYear(book); year != 0 {
+		key += strconv.Itoa(year)
+	}
+
+	// the second entry with a key gets an a, the third one a b, and so on
+	base := key
+	for n := 0; b.keys[key]; n++ {
+		key = base + keySuffix(n)
+	}
+	b.keys[key] = true
+
+	return key
+}
+
+// keyPart keeps the ASCII letters and digits of s, in lower case, up to 20 of them
+func keyPart(s string) string {
+
+	var part strings.Builder
+	for _, r := range strings.ToLower(s) {
+		if (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9') {
+			part.WriteRune(r)
+		}
+		if part.Len() == 20 {
+			break
+		}
+	}
+
+	return part.String()
+}
+
+// keySuffix returns the n-th suffix of a taken key: a to z, then aa, ab and so on
+func keySuffix(n int) string {
+
+	suffix := string(rune('a' + n%26))
+	if n >= 26 {
+		suffix = keySuffix(n/26-1) + suffix
+	}
+
+	return suffix
+}
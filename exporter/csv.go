@@ -0,0 +1,139 @@
+package exporter
+
+import (
+	"encoding/csv"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"github.com/Parsa-Sh-Y/book-manager-service/importer"
+)
+
+// The columns of the exported CSV files besides the fields read by the importer
+const (
+	columnID           = "id"
+	columnEditors      = "editors"
+	columnTranslators  = "translators"
+	columnIllustrators = "illustrators"
+)
+
+// csvHeader are the columns of the exported CSV files. The columns named after the
+// fields of the importer are read back by it, the others are ignored by it.
+var csvHeader = []string{
+	columnID, importer.FieldName, importer.FieldISBN10, importer.FieldISBN13, importer.FieldAuthors,
+	columnEditors, columnTranslators, columnIllustrators, importer.FieldPublisher, importer.FieldCategory,
+	importer.FieldSeries, importer.FieldVolume, importer.FieldPublishedAt, importer.FieldSummary,
+	importer.FieldTags, importer.FieldTableOfContents, importer.FieldRating,
+}
+
+// CSVWriter writes the books as the rows of a CSV file with a header row, in the format
+// of the files read by the importer. The lists are separated by semicolons, and the authors
+// are written as "Last, First". The table of contents is flattened, each entry before its children.
+type CSVWriter struct {
+	writer *csv.Writer
+	header bool
+}
+
+func NewCSVWriter(w io.Writer) *CSVWriter {
+	return &CSVWriter{writer: csv.NewWriter(w)}
+}
+
+func (c *CSVWriter) WriteBook(book *models.Book) error {
+
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	volume := ""
+	if book.Volumn != 0 {
+		volume = strconv.Itoa(book.Volumn)
+	}
+	publishedAt := ""
+	if !book.PublishedAt.IsZero() {
+		publishedAt = book.PublishedAt.Format("2006-01-02")
+	}
+	rating := ""
+	if book.Rating != 0 {
+		rating = strconv.Itoa(book.Rating)
+	}
+
+	return c.writer.Write([]string{
+		strconv.FormatUint(uint64(book.ID), 10),
+		book.Name,
+		book.ISBN10,
+		book.ISBN13,
+		joinNames(credits(book, models.AuthorRoleAuthor)),
+		joinNames(credits(book, models.AuthorRoleEditor)),
+		joinNames(credits(book, models.AuthorRoleTranslator)),
+		joinNames(credits(book, models.AuthorRoleIllustrator)),
+		publisherName(book),
+		categoryName(book),
+		seriesName(book),
+		volume,
+		publishedAt,
+		book.Summary,
+		strings.Join(tagNames(book), "; "),
+		strings.Join(models.ContentNames(book.TableOfContents), "; "),
+		rating,
+	})
+}
+
+// Close writes the header when no book was written, and flushes the rows
+func (c *CSVWriter) Close() error {
+
+	if err := c.writeHeader(); err != nil {
+		return err
+	}
+
+	c.writer.Flush()
+	return c.writer.Error()
+}
+
+func (c *CSVWriter) writeHeader() error {
+
+	if c.header {
+		return nil
+	}
+	c.header = true
+
+	return c.writer.Write(csvHeader)
+}
+
+// joinNames joins the names of the authors as "Last, First", separated by semicolons
+func joinNames(authors []models.Author) string {
+
+	names := make([]string, 0, len(authors))
+	for _, author := range authors {
+		names = append(names, invertedName(author))
+	}
+
+	return strings.Join(names, "; ")
+}
+
+func publisherName(book *models.Book) string {
+
+	if book.Publisher == nil {
+		return ""
+	}
+
+	return book.Publisher.Name
+}
+
+func categoryName(book *models.Book) string {
+
+	if book.Category == nil {
+		return ""
+	}
+
+	return book.Category.Name
+}
+
+func seriesName(book *models.Book) string {
+
+	if book.Series == nil {
+		return ""
+	}
+
+	return book.Series.Name
+}
@@ -0,0 +1,166 @@
+// Package exporter writes books to files for spreadsheets and citation managers:
+// CSV with the columns read by the importer, a JSON array of books, BibTeX and RIS.
+// The books are read from the store a page at a time and written as they are read,
+// so a library of any size is exported without holding it in memory.
+package exporter
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db"
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+)
+
+// ErrUnknownFormat The books can not be exported in the requested format
+var ErrUnknownFormat = errors.New("unknown export format")
+
+// The formats of the exported files
+const (
+	FormatCSV    = "csv"
+	FormatJSON   = "json"
+	FormatBibTeX = "bibtex"
+	FormatRIS    = "ris"
+)
+
+// Formats are the formats of the exported files, in the order they are listed to the users
+var Formats = []string{FormatCSV, FormatJSON, FormatBibTeX, FormatRIS}
+
+// pageSize is the number of books read from the store at a time
+const pageSize = 100
+
+// formatFiles are the media type and the file extension of each format
+var formatFiles = map[string]struct {
+	mediaType string
+	extension string
+}{
+	FormatCSV:    {"text/csv; charset=utf-8", "csv"},
+	FormatJSON:   {"application/json", "json"},
+	FormatBibTeX: {"application/x-bibtex; charset=utf-8", "bib"},
+	FormatRIS:    {"application/x-research-info-systems; charset=utf-8", "ris"},
+}
+
+// Writer writes the books of an export one by one. Close writes what
+// the format needs after the last book and flushes the buffered books.
+type Writer interface {
+	WriteBook(book *models.Book) error
+	Close() error
+}
+
+// NewWriter creates the writer of the format writing to w
+func NewWriter(format string, w io.Writer) (Writer, error) {
+
+	switch format {
+	case FormatCSV:
+		return NewCSVWriter(w), nil
+	case FormatJSON:
+		return NewJSONWriter(w), nil
+	case FormatBibTeX:
+		return NewBibTeXWriter(w), nil
+	case FormatRIS:
+		return NewRISWriter(w), nil
+	}
+
+	return nil, fmt.Errorf("%w %q, it is one of %s", ErrUnknownFormat, format, strings.Join(Formats, ", "))
+}
+
+// MediaType returns the media type of the files of the format
+func MediaType(format string) string {
+	return formatFiles[format].mediaType
+}
+
+// FileName returns the name of an exported file of the format, such as books.bib
+func FileName(format string) string {
+	return "books." + formatFiles[format].extension
+}
+
+// Export writes every book matching the filters of query with writer and closes it.
+// The books are written in the order of their ids with their tables of contents,
+// the order and the page of query are ignored. It returns the number of books written.
+func Export(store db.BookStore, query db.BookQuery, writer Writer) (int, error) {
+
+	query.SortBy, query.Descending = "", false
+	query.Limit, query.Offset = pageSize, 0
+	query.Contents = true
+
+	count := 0
+	for {
+		books, _, err := store.GetAllBooks(query)
+		if err != nil {
+			return count, err
+		}
+
+		for i := range *books {
+			if err := writer.WriteBook(&(*books)[i]); err != nil {
+				return count, err
+			}
+			count++
+		}
+
+		if len(*books) < pageSize {
+			break
+		}
+		query.AfterID = (*books)[len(*books)-1].ID
+	}
+
+	return count, writer.Close()
+}
+
+// credits returns the authors of the book with the role, in the order of the credits
+func credits(book *models.Book, role string) []models.Author {
+
+	var authors []models.Author
+	for _, credit := range book.Authors {
+		if credit.Role == role {
+			authors = append(authors, credit.Author)
+		}
+	}
+
+	return authors
+}
+
+// invertedName returns the name of the author as "Last, First", the form read back by the
+// importer and by the citation managers, or the last name alone when there is no first name
+func invertedName(author models.Author) string {
+
+	if author.FirstName == "" {
+		return author.LastName
+	}
+	if author.LastName == "" {
+		return author.FirstName
+	}
+
+	return author.LastName + ", " + author.FirstName
+}
+
+// publishedYear returns the year the book was published, zero when the date is unknown
+func publishedYear(book *models.Book) int {
+
+	if book.PublishedAt.IsZero() {
+		return 0
+	}
+
+	return book.PublishedAt.Year()
+}
+
+// bookISBN returns the ISBN-13 of the book, or its ISBN-10 when it only has that one
+func bookISBN(book *models.Book) string {
+
+	if book.ISBN13 != "" {
+		return book.ISBN13
+	}
+
+	return book.ISBN10
+}
+
+func tagNames(book *models.Book) []string {
+
+	names := make([]string, 0, len(book.Tags))
+	for _, tag := range book.Tags {
+		names = append(names, tag.Name)
+	}
+
+	return names
+}
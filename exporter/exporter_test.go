@@ -0,0 +1,329 @@
+package exporter
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db"
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"github.com/Parsa-Sh-Y/book-manager-service/importer"
+)
+
+// bookIDs is a writer keeping the ids of the books written
+type bookIDs struct {
+	ids    []uint
+	closed bool
+}
+
+func (b *bookIDs) WriteBook(book *models.Book) error {
+
+	b.ids = append(b.ids, book.ID)
+	return nil
+}
+
+func (b *bookIDs) Close() error {
+
+	b.closed = true
+	return nil
+}
+
+// newLibrary creates a store with a Fiction & Drama category and n books of the user 1,
+// every third book belonging to the user 2. The names have the characters of LaTeX.
+func newLibrary(t *testing.T, n int) *db.MemoryDB {
+
+	t.Helper()
+	store := db.CreateNewMemoryDB()
+	if err := store.CreateCategory(&models.Category{Name: "Fiction & Drama"}); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 1; i <= n; i++ {
+		book := models.Book{
+			Name:        fmt.Sprintf("Book {%d} & 100%% More", i),
+			UserID:      1,
+			PublishedAt: time.Date(1960+i%5, time.March, 1, 0, 0, 0, 0, time.UTC),
+			Category:    &models.Category{Name: "Fiction & Drama"},
+			Authors: []models.BookAuthor{
+				{Role: models.AuthorRoleAuthor, Author: models.Author{FirstName: "Ann", LastName: fmt.Sprintf("O'Neil%d", i%3)}},
+			},
+			Tags:                []models.Tag{{Name: "b&w"}, {Name: "classic"}},
+			TableOfContentsJson: []string{"Part I", "Part II"},
+			Rating:              i % 6,
+		}
+		book.TableOfContents = models.ContentsFromNames(book.TableOfContentsJson)
+		if i%3 == 0 {
+			book.UserID = 2
+		}
+		if err := store.CreateBook(&book); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return store
+}
+
+func TestExport(t *testing.T) {
+
+	for _, n := range []int{0, 1, pageSize - 1, pageSize, pageSize + 1, 2*pageSize + 5} {
+		t.Run(fmt.Sprint(n), func(t *testing.T) {
+			store := newLibrary(t, n)
+
+			// the page and the order of the query are ignored
+			writer := &bookIDs{}
+			count, err := Export(store, db.BookQuery{SortBy: "name", Descending: true, Limit: 3, Offset: 7}, writer)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if count != n || len(writer.ids) != n {
+				t.Fatalf("Export() = %d with %d books written, want %d", count, len(writer.ids), n)
+			}
+			for i, id := range writer.ids {
+				if id != uint(i+1) {
+					t.Fatalf("book %d written is %d, want the books in the order of their ids", i, id)
+				}
+			}
+			if !writer.closed {
+				t.Error("the writer was not closed")
+			}
+		})
+	}
+}
+
+func TestExportFilters(t *testing.T) {
+
+	store := newLibrary(t, 2*pageSize+5)
+
+	writer := &bookIDs{}
+	count, err := Export(store, db.BookQuery{UserID: 2}, writer)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := (2*pageSize + 5) / 3; count != want {
+		t.Errorf("Export() of the books of the user 2 = %d, want %d", count, want)
+	}
+	for _, id := range writer.ids {
+		if id%3 != 0 {
+			t.Errorf("book %d of the user 1 was exported", id)
+		}
+	}
+}
+
+func TestBibTeXWriter(t *testing.T) {
+
+	herbert := models.BookAuthor{Role: models.AuthorRoleAuthor, Author: models.Author{FirstName: "Frank", LastName: "Herbert"}}
+	dune := time.Date(1965, time.August, 1, 0, 0, 0, 0, time.UTC)
+
+	books := []models.Book{
+		{
+			Name:            "Dune {Deluxe} & 50% More",
+			PublishedAt:     dune,
+			ISBN10:          "0441172717",
+			ISBN13:          "9780441172719",
+			Authors:         []models.BookAuthor{herbert, {Role: models.AuthorRoleEditor, Author: models.Author{FirstName: "J_R", LastName: "Smith #1"}}},
+			Publisher:       &models.Publisher{Name: "Chilton $ Books"},
+			Series:          &models.Series{Name: "Dune"},
+			Volumn:          1,
+			Tags:            []models.Tag{{Name: "sci~fi"}, {Name: "c^2"}},
+			Summary:         `A \ and more`,
+			TableOfContents: []models.Content{{ContentName: "Book One", Children: []models.Content{{ContentName: "Chapter 1"}}}},
+		},
+		{Name: "Dune Messiah", PublishedAt: dune, Authors: []models.BookAuthor{herbert}},
+		{Name: "Children of Dune", PublishedAt: dune, Authors: []models.BookAuthor{herbert}},
+		// a key made like a suffixed one does not take it
+		{Name: "Herbert", PublishedAt: time.Date(1965, 1, 1, 0, 0, 0, 0, time.UTC), Authors: []models.BookAuthor{{Role: models.AuthorRoleAuthor, Author: models.Author{LastName: "Herbert"}}}},
+		{Name: "Edited", Authors: []models.BookAuthor{{Role: models.AuthorRoleEditor, Author: models.Author{LastName: "Smith"}}}},
+		{Name: "The Book of Ünknown Authors, 2nd"},
+		{Name: "ÜÖ"},
+	}
+
+	var out bytes.Buffer
+	writer := NewBibTeXWriter(&out)
+	for i := range books {
+		if err := writer.WriteBook(&books[i]); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := writer.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	entries := strings.Split(strings.TrimSuffix(out.String(), "\n\n"), "\n\n")
+	if len(entries) != len(books) {
+		t.Fatalf("%d entries, want %d:\n%s", len(entries), len(books), out.String())
+	}
+
+	want := `@book{herbert1965,
+  title = {Dune \{Deluxe\} \& 50\% More},
+  author = {Herbert, Frank},
+  editor = {Smith \#1, J\_R},
+  publisher = {Chilton \$ Books},
+  year = {1965},
+  isbn = {9780441172719},
+  series = {Dune},
+  volume = {1},
+  keywords = {sci\textasciitilde{}fi, c\textasciicircum{}2},
+  abstract = {A \textbackslash{} and more},
+  contents = {Book One; Chapter 1},
+}`
+	if entries[0] != want {
+		t.Errorf("entry =\n%s\nwant\n%s", entries[0], want)
+	}
+
+	var keys []string
+	for _, entry := range entries {
+		key := strings.TrimSuffix(strings.TrimPrefix(strings.SplitN(entry, "\n", 2)[0], "@book{"), ",")
+		keys = append(keys, key)
+	}
+	wantKeys := []string{"herbert1965", "herbert1965a", "herbert1965b", "herbert1965c", "smith", "thebookofnknownautho", "book"}
+	if !reflect.DeepEqual(keys, wantKeys) {
+		t.Errorf("keys = %v, want %v", keys, wantKeys)
+	}
+}
+
+func TestKeySuffix(t *testing.T) {
+
+	tests := []struct {
+		n    int
+		want string
+	}{
+		{0, "a"},
+		{1, "b"},
+		{25, "z"},
+		{26, "aa"},
+		{27, "ab"},
+		{51, "az"},
+		{52, "ba"},
+		{701, "zz"},
+		{702, "aaa"},
+	}
+
+	for _, tt := range tests {
+		if got := keySuffix(tt.n); got != tt.want {
+			t.Errorf("keySuffix(%d) = %q, want %q", tt.n, got, tt.want)
+		}
+	}
+}
+
+func TestBibTeXKeysAreUnique(t *testing.T) {
+
+	writer := NewBibTeXWriter(&bytes.Buffer{})
+	book := &models.Book{Name: "Dune", Authors: []models.BookAuthor{{Role: models.AuthorRoleAuthor, Author: models.Author{LastName: "Herbert"}}}}
+
+	for i := 0; i < 1000; i++ {
+		writer.WriteBook(book)
+	}
+	if len(writer.keys) != 1000 {
+		t.Errorf("%d keys for 1000 entries", len(writer.keys))
+	}
+}
+
+func TestRISWriter(t *testing.T) {
+
+	book := &models.Book{
+		ID:          7,
+		Name:        "Dune\n{Deluxe}  & 50% More",
+		PublishedAt: time.Date(1965, time.August, 1, 0, 0, 0, 0, time.UTC),
+		ISBN10:      "0441172717",
+		ISBN13:      "9780441172719",
+		Authors: []models.BookAuthor{
+			{Role: models.AuthorRoleTranslator, Author: models.Author{FirstName: "Ali", LastName: "Ahmadi"}},
+			{Role: models.AuthorRoleAuthor, Author: models.Author{FirstName: "Frank", LastName: "Herbert"}},
+			{Role: models.AuthorRoleEditor, Author: models.Author{LastName: "Smith"}},
+			{Role: models.AuthorRoleIllustrator, Author: models.Author{FirstName: "John"}},
+		},
+		Publisher:       &models.Publisher{Name: "Chilton Books"},
+		Series:          &models.Series{Name: "Dune"},
+		Volumn:          1,
+		Tags:            []models.Tag{{Name: "classic"}, {Name: "sci-fi"}},
+		Summary:         "A planet.\n\nA desert.",
+		TableOfContents: []models.Content{{ContentName: "Book One", Children: []models.Content{{ContentName: "Chapter 1"}}}},
+	}
+
+	var out bytes.Buffer
+	writer := NewRISWriter(&out)
+	writer.WriteBook(book)
+	writer.WriteBook(&models.Book{ID: 8, Name: "Untitled"})
+	writer.Close()
+
+	want := strings.Join([]string{
+		"TY  - BOOK",
+		"ID  - 7",
+		"TI  - Dune {Deluxe} & 50% More",
+		"AU  - Herbert, Frank",
+		"ED  - Smith",
+		"A4  - Ahmadi, Ali",
+		"A4  - John",
+		"PB  - Chilton Books",
+		"PY  - 1965",
+		"SN  - 9780441172719",
+		"SN  - 0441172717",
+		"T3  - Dune",
+		"VL  - 1",
+		"KW  - classic",
+		"KW  - sci-fi",
+		"AB  - A planet. A desert.",
+		"N1  - Contents: Book One; Chapter 1",
+		"ER  - ",
+		"",
+		"TY  - BOOK",
+		"ID  - 8",
+		"TI  - Untitled",
+		"ER  - ",
+		"",
+		"",
+	}, "\r\n")
+	if out.String() != want {
+		t.Errorf("RIS =\n%q\nwant\n%q", out.String(), want)
+	}
+}
+
+func TestCSVRoundTrip(t *testing.T) {
+
+	n := 2*pageSize + 5
+	source := newLibrary(t, n)
+
+	var exported bytes.Buffer
+	if _, err := Export(source, db.BookQuery{}, NewCSVWriter(&exported)); err != nil {
+		t.Fatal(err)
+	}
+
+	// the export of the books of the user 1 and 2 imported by the user 1 of another store
+	target := db.CreateNewMemoryDB()
+	if err := target.CreateCategory(&models.Category{Name: "Fiction & Drama"}); err != nil {
+		t.Fatal(err)
+	}
+	reader, err := importer.NewCSVReader(bytes.NewReader(exported.Bytes()), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	report, err := importer.Import(target, reader, 1, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Created != n {
+		t.Fatalf("%d books created, want %d: %+v", report.Created, n, report)
+	}
+
+	// the ids are given in the same order, so the books are exported the same
+	var reexported bytes.Buffer
+	if _, err := Export(target, db.BookQuery{}, NewCSVWriter(&reexported)); err != nil {
+		t.Fatal(err)
+	}
+	if exported.String() != reexported.String() {
+		t.Errorf("export of the imported books =\n%s\nwant\n%s", reexported.String(), exported.String())
+	}
+
+	book, err := target.GetBook(n)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := fmt.Sprintf("Book {%d} & 100%% More", n); book.Name != want || book.Category == nil || book.Category.Name != "Fiction & Drama" {
+		t.Errorf("imported book %d = %q in %v", n, book.Name, book.Category)
+	}
+}
@@ -0,0 +1,51 @@
+package exporter
+
+import (
+	"encoding/json"
+	"io"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+)
+
+// JSONWriter writes the books as a JSON array, each book like the body of GET /api/v1/books/{id}
+type JSONWriter struct {
+	w     io.Writer
+	count int
+}
+
+func NewJSONWriter(w io.Writer) *JSONWriter {
+	return &JSONWriter{w: w}
+}
+
+func (j *JSONWriter) WriteBook(book *models.Book) error {
+
+	book.TableOfContentsJson = models.ContentNames(book.TableOfContents)
+	data, err := json.Marshal(book)
+	if err != nil {
+		return err
+	}
+
+	separator := ",\n"
+	if j.count == 0 {
+		separator = "[\n"
+	}
+	j.count++
+
+	if _, err := io.WriteString(j.w, separator); err != nil {
+		return err
+	}
+	_, err = j.w.Write(data)
+	return err
+}
+
+// Close ends the array, an empty one when no book was written
+func (j *JSONWriter) Close() error {
+
+	end := "\n]\n"
+	if j.count == 0 {
+		end = "[]\n"
+	}
+
+	_, err := io.WriteString(j.w, end)
+	return err
+}
@@ -0,0 +1,77 @@
+package exporter
+
+import (
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+)
+
+// risRoles are the tags of the credits of each role. Translators and illustrators
+// are subsidiary authors, RIS has no tag of their own for them.
+var risRoles = []struct {
+	role string
+	tag  string
+}{
+	{models.AuthorRoleAuthor, "AU"},
+	{models.AuthorRoleEditor, "ED"},
+	{models.AuthorRoleTranslator, "A4"},
+	{models.AuthorRoleIllustrator, "A4"},
+}
+
+// RISWriter writes the books as BOOK references of the RIS format, read by most citation
+// managers. The series is the tertiary title and the table of contents is written as a note.
+type RISWriter struct {
+	w io.Writer
+}
+
+func NewRISWriter(w io.Writer) *RISWriter {
+	return &RISWriter{w: w}
+}
+
+func (r *RISWriter) WriteBook(book *models.Book) error {
+
+	var reference strings.Builder
+	tag := func(name string, value string) {
+		// the values of the tags are on a single line
+		if value = strings.Join(strings.Fields(value), " "); value != "" {
+			fmt.Fprintf(&reference, "%s  - %s\r\n", name, value)
+		}
+	}
+
+	tag("TY", "BOOK")
+	tag("ID", strconv.FormatUint(uint64(book.ID), 10))
+	tag("TI", book.Name)
+	for _, role := range risRoles {
+		for _, author := range credits(book, role.role) {
+			tag(role.tag, invertedName(author))
+		}
+	}
+	tag("PB", publisherName(book))
+	if year := publishedYear(book); year != 0 {
+		tag("PY", strconv.Itoa(year))
+	}
+	tag("SN", book.ISBN13)
+	tag("SN", book.ISBN10)
+	tag("T3", seriesName(book))
+	if book.Volumn != 0 {
+		tag("VL", strconv.Itoa(book.Volumn))
+	}
+	for _, name := range tagNames(book) {
+		tag("KW", name)
+	}
+	tag("AB", book.Summary)
+	if names := models.ContentNames(book.TableOfContents); len(names) > 0 {
+		tag("N1", "Contents: "+strings.Join(names, "; "))
+	}
+	reference.WriteString("ER  - \r\n\r\n")
+
+	_, err := io.WriteString(r.w, reference.String())
+	return err
+}
+
+func (r *RISWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/auth"
+	"github.com/Parsa-Sh-Y/book-manager-service/db"
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"github.com/Parsa-Sh-Y/book-manager-service/validation"
+)
+
+type authorCollection struct {
+	Authors  []models.Author `json:"authors"`
+	Total    int64           `json:"total"`
+	Limit    int             `json:"limit"`
+	Offset   int             `json:"offset"`
+	Next     string          `json:"next,omitempty"`
+	Previous string          `json:"previous,omitempty"`
+}
+
+// HandleAuthorsRoot serves /api/v1/authors
+func (s *Server) HandleAuthorsRoot(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case http.MethodGet:
+		s.HandleGetAllAuthors(w, r)
+	case http.MethodPost:
+		s.HandleCreateAuthor(w, r)
+	default:
+		s.methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+	}
+
+}
+
+// HandleAuthorsSubtree serves /api/v1/authors/{id} and the books of the author under /api/v1/authors/{id}/books
+func (s *Server) HandleAuthorsSubtree(w http.ResponseWriter, r *http.Request) {
+
+	if segments := authorPathSegments(r); len(segments) > 1 {
+		if segments[1] != "books" || len(segments) > 2 {
+			s.writeProblem(w, r, http.StatusNotFound, codeNotFound, "")
+			return
+		}
+		if r.Method != http.MethodGet {
+			s.methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		s.HandleGetAuthorBooks(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.HandleGetAuthor(w, r)
+	case http.MethodPut:
+		s.HandleUpdateAuthor(w, r)
+	case http.MethodDelete:
+		s.HandleDeleteAuthor(w, r)
+	default:
+		s.methodNotAllowed(w, r, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+
+}
+
+func (s *Server) HandleGetAllAuthors(w http.ResponseWriter, r *http.Request) {
+
+	// check if user can read the books
+	if !auth.Can(currentUser(r).Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	// parse the filter and the page
+	query, err := parseAuthorQuery(r.URL.Query())
+	if err != nil {
+		s.writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	authors := authorCollection{Limit: query.Limit, Offset: query.Offset}
+	authors.Authors, authors.Total, err = s.db.GetAllAuthors(query)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	// link the neighbouring pages
+	authors.Next, authors.Previous = pageLinks(r, authors.Total, query.Limit, query.Offset)
+
+	s.writeJSON(w, r, http.StatusOK, authors)
+}
+
+// HandleCreateAuthor adds an author. Anyone who can add books can add the authors of their books.
+func (s *Server) HandleCreateAuthor(w http.ResponseWriter, r *http.Request) {
+
+	if !auth.Can(currentUser(r).Role, auth.PermissionCreateBook) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	author, ok := s.readAuthor(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.db.CreateAuthor(author); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusCreated, author)
+}
+
+func (s *Server) HandleGetAuthor(w http.ResponseWriter, r *http.Request) {
+
+	// check if user can read the books
+	if !auth.Can(currentUser(r).Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	authorID, ok := s.authorIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	author, err := s.db.GetAuthor(authorID)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, author)
+}
+
+// HandleUpdateAuthor replaces the author. The authors are shared by the books
+// of every user, so only the roles managing the authors can change them.
+func (s *Server) HandleUpdateAuthor(w http.ResponseWriter, r *http.Request) {
+
+	if !auth.Can(currentUser(r).Role, auth.PermissionManageAuthors) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	authorID, ok := s.authorIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	author, ok := s.readAuthor(w, r)
+	if !ok {
+		return
+	}
+
+	author.ID = authorID
+	if err := s.db.UpdateAuthor(author); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, author)
+}
+
+// HandleDeleteAuthor deletes the author if no book credits it
+func (s *Server) HandleDeleteAuthor(w http.ResponseWriter, r *http.Request) {
+
+	if !auth.Can(currentUser(r).Role, auth.PermissionManageAuthors) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	authorID, ok := s.authorIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.db.DeleteAuthor(authorID); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeMessage(w, r, http.StatusOK, "author was deleted successfully")
+}
+
+// HandleGetAuthorBooks lists the books crediting the author in any role.
+// It takes the filters, the order and the page of GET /api/v1/books.
+func (s *Server) HandleGetAuthorBooks(w http.ResponseWriter, r *http.Request) {
+
+	// check if user can read the books
+	if !auth.Can(currentUser(r).Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	authorID, ok := s.authorIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	// an unknown author has no books, but it is not an empty list
+	if _, err := s.db.GetAuthor(authorID); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	query, err := parseBookQuery(r.URL.Query())
+	if err != nil {
+		s.writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	query.AuthorID = authorID
+
+	s.writeBooks(w, r, query)
+}
+
+// readAuthor reads and validates the author in the request body.
+// The id of the author is never taken from the body.
+func (s *Server) readAuthor(w http.ResponseWriter, r *http.Request) (*models.Author, bool) {
+
+	var author models.Author
+	if !s.readJSON(w, r, &author) {
+		return nil, false
+	}
+
+	author.ID = 0
+	if err := validation.Validate(&author); err != nil {
+		s.writeError(w, r, err)
+		return nil, false
+	}
+
+	return &author, true
+}
+
+// authorPathSegments returns the segments of the path after /api/v1/authors/
+func authorPathSegments(r *http.Request) []string {
+	return strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/authors/"), "/"), "/")
+}
+
+// authorIDFromPath returns the id in /api/v1/authors/{id}.
+// It writes the problem response and returns false when the id is not a number.
+func (s *Server) authorIDFromPath(w http.ResponseWriter, r *http.Request) (uint, bool) {
+
+	authorID, err := strconv.ParseUint(authorPathSegments(r)[0], 10, 0)
+	if err != nil {
+		s.writeError(w, r, db.ErrAuthorNotFound)
+		return 0, false
+	}
+
+	return uint(authorID), true
+}
@@ -0,0 +1,57 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db"
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+)
+
+func TestCreateBookWithOldAuthor(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		body   string
+		status int
+		author string
+	}{
+		{"name", `{"name": "Dune", "author": "Frank Herbert"}`, http.StatusOK, "Frank Herbert"},
+		{"object", `{"name": "Dune", "author": {"first_name": "Frank", "last_name": "Herbert"}}`, http.StatusOK, "Frank Herbert"},
+		{"empty", `{"name": "Dune", "author": {}}`, http.StatusOK, ""},
+		{"null", `{"name": "Dune", "author": null}`, http.StatusOK, ""},
+		{"with authors", `{"name": "Dune", "author": "Frank Herbert", "authors": [{"author": {"last_name": "Herbert"}}]}`, http.StatusBadRequest, ""},
+		{"not an author", `{"name": "Dune", "author": 7}`, http.StatusBadRequest, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			resp := ts.do(t, "ali", http.MethodPost, "/api/v1/books", tt.body, "Content-Type", "application/json")
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status != http.StatusOK {
+				if code := problemCode(t, resp); code != codeInvalidRequestBody {
+					t.Errorf("code = %q, want %q", code, codeInvalidRequestBody)
+				}
+				return
+			}
+
+			books, _, err := ts.store.GetAllBooks(db.BookQuery{})
+			if err != nil || len(*books) != 1 {
+				t.Fatalf("GetAllBooks = %v, %v, want the created book", books, err)
+			}
+			book := (*books)[0]
+			if tt.author == "" {
+				if len(book.Authors) != 0 {
+					t.Errorf("authors = %+v, want none", book.Authors)
+				}
+				return
+			}
+			if len(book.Authors) != 1 || book.Authors[0].Role != models.AuthorRoleAuthor || book.Authors[0].Author.FullName() != tt.author {
+				t.Errorf("authors = %+v, want %s as the author", book.Authors, tt.author)
+			}
+		})
+	}
+}
@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/auth"
+	"github.com/Parsa-Sh-Y/book-manager-service/db"
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"github.com/Parsa-Sh-Y/book-manager-service/validation"
+)
+
+type categoryTree struct {
+	Categories []models.Category `json:"categories"`
+}
+
+// HandleCategoriesRoot serves /api/v1/categories
+func (s *Server) HandleCategoriesRoot(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case http.MethodGet:
+		s.HandleGetCategoryTree(w, r)
+	case http.MethodPost:
+		s.HandleCreateCategory(w, r)
+	default:
+		s.methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+	}
+
+}
+
+// HandleCategoriesSubtree serves /api/v1/categories/{id} and the books of the category under /api/v1/categories/{id}/books
+func (s *Server) HandleCategoriesSubtree(w http.ResponseWriter, r *http.Request) {
+
+	if segments := categoryPathSegments(r); len(segments) > 1 {
+		if segments[1] != "books" || len(segments) > 2 {
+			s.writeProblem(w, r, http.StatusNotFound, codeNotFound, "")
+			return
+		}
+		if r.Method != http.MethodGet {
+			s.methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		s.HandleGetCategoryBooks(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.HandleGetCategory(w, r)
+	case http.MethodPut:
+		s.HandleUpdateCategory(w, r)
+	case http.MethodDelete:
+		s.HandleDeleteCategory(w, r)
+	default:
+		s.methodNotAllowed(w, r, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+
+}
+
+// HandleGetCategoryTree returns the whole tree of the categories
+func (s *Server) HandleGetCategoryTree(w http.ResponseWriter, r *http.Request) {
+
+	// check if user can read the books
+	if !auth.Can(currentUser(r).Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	categories, err := s.db.GetCategoryTree()
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, categoryTree{Categories: categories})
+}
+
+// HandleCreateCategory adds a category under its parent. The tree is managed,
+// so unlike the authors and the publishers the users can not add categories.
+func (s *Server) HandleCreateCategory(w http.ResponseWriter, r *http.Request) {
+
+	if !auth.Can(currentUser(r).Role, auth.PermissionManageCategories) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	category, ok := s.readCategory(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.db.CreateCategory(category); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusCreated, category)
+}
+
+// HandleGetCategory returns the category with its subcategories
+func (s *Server) HandleGetCategory(w http.ResponseWriter, r *http.Request) {
+
+	// check if user can read the books
+	if !auth.Can(currentUser(r).Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	categoryID, ok := s.categoryIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	category, err := s.db.GetCategory(categoryID)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, category)
+}
+
+// HandleUpdateCategory renames the category and moves it, with its subcategories,
+// under the parent in the body
+func (s *Server) HandleUpdateCategory(w http.ResponseWriter, r *http.Request) {
+
+	if !auth.Can(currentUser(r).Role, auth.PermissionManageCategories) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	categoryID, ok := s.categoryIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	category, ok := s.readCategory(w, r)
+	if !ok {
+		return
+	}
+
+	category.ID = categoryID
+	if err := s.db.UpdateCategory(category); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, category)
+}
+
+// HandleDeleteCategory deletes the category. With ?move_to={id} its books and
+// subcategories are moved to another category first, otherwise it has to be empty.
+func (s *Server) HandleDeleteCategory(w http.ResponseWriter, r *http.Request) {
+
+	if !auth.Can(currentUser(r).Role, auth.PermissionManageCategories) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	categoryID, ok := s.categoryIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	var moveTo uint
+	if v := r.URL.Query().Get("move_to"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 0)
+		if err != nil || id == 0 {
+			s.writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "move_to must be the id of a category")
+			return
+		}
+		moveTo = uint(id)
+	}
+
+	if err := s.db.DeleteCategory(categoryID, moveTo); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeMessage(w, r, http.StatusOK, "category was deleted successfully")
+}
+
+// HandleGetCategoryBooks lists the books in the category or in its subcategories.
+// It takes the filters, the order and the page of GET /api/v1/books.
+func (s *Server) HandleGetCategoryBooks(w http.ResponseWriter, r *http.Request) {
+
+	// check if user can read the books
+	if !auth.Can(currentUser(r).Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	categoryID, ok := s.categoryIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	// an unknown category has no books, but it is not an empty list
+	if _, err := s.db.GetCategory(categoryID); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	query, err := parseBookQuery(r.URL.Query())
+	if err != nil {
+		s.writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	query.CategoryID = categoryID
+
+	s.writeBooks(w, r, query)
+}
+
+// readCategory reads and validates the category in the request body.
+// The id of the category is never taken from the body.
+func (s *Server) readCategory(w http.ResponseWriter, r *http.Request) (*models.Category, bool) {
+
+	var category models.Category
+	if !s.readJSON(w, r, &category) {
+		return nil, false
+	}
+
+	category.ID = 0
+	if err := validation.Validate(&category); err != nil {
+		s.writeError(w, r, err)
+		return nil, false
+	}
+
+	return &category, true
+}
+
+// categoryPathSegments returns the segments of the path after /api/v1/categories/
+func categoryPathSegments(r *http.Request) []string {
+	return strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/categories/"), "/"), "/")
+}
+
+// categoryIDFromPath returns the id in /api/v1/categories/{id}.
+// It writes the problem response and returns false when the id is not a number.
+func (s *Server) categoryIDFromPath(w http.ResponseWriter, r *http.Request) (uint, bool) {
+
+	categoryID, err := strconv.ParseUint(categoryPathSegments(r)[0], 10, 0)
+	if err != nil {
+		s.writeError(w, r, db.ErrCategoryNotFound)
+		return 0, false
+	}
+
+	return uint(categoryID), true
+}
@@ -0,0 +1,54 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+)
+
+// bookETag returns the entity tag of the book, made of its version
+func bookETag(book *models.Book) string {
+	return `"` + strconv.FormatUint(uint64(book.Version), 10) + `"`
+}
+
+// etagMatches reports whether etag is in header, a comma separated list of
+// entity tags or "*". The weak comparison ignores the W/ prefix of weak tags.
+func etagMatches(header string, etag string, weak bool) bool {
+
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.TrimSpace(tag)
+		if tag == "*" {
+			return true
+		}
+		if weak {
+			tag = strings.TrimPrefix(tag, "W/")
+		}
+		if tag == etag {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkIfMatch makes sure the client changes the version of the book it has seen.
+// It writes 428 Precondition Required when the request has no If-Match header and
+// 412 Precondition Failed when the book has changed, and then returns false.
+func (s *Server) checkIfMatch(w http.ResponseWriter, r *http.Request, book *models.Book) bool {
+
+	header := r.Header.Get("If-Match")
+	if header == "" {
+		s.writeProblem(w, r, http.StatusPreconditionRequired, codePreconditionRequired, "the request needs an If-Match header with the ETag of the book")
+		return false
+	}
+
+	if !etagMatches(header, bookETag(book), false) {
+		w.Header().Set("ETag", bookETag(book))
+		s.writeProblem(w, r, http.StatusPreconditionFailed, codePreconditionFailed, "the book was changed by another request")
+		return false
+	}
+
+	return true
+}
@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/auth"
+	"github.com/Parsa-Sh-Y/book-manager-service/db"
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"github.com/Parsa-Sh-Y/book-manager-service/validation"
+)
+
+type contentCollection struct {
+	Contents []models.Content `json:"contents"`
+}
+
+// contentRequestBody is an entry of the table of contents.
+// A missing position puts new entries last and keeps moved entries in place.
+type contentRequestBody struct {
+	Name     string           `json:"name" validate:"required,max=255"`
+	Kind     string           `json:"kind" validate:"oneof=part chapter section"`
+	Page     *int             `json:"page" validate:"min=1"`
+	ParentID *uint            `json:"parent_id"`
+	Position *int             `json:"position" validate:"min=0"`
+	Children []models.Content `json:"children" validate:"dive"`
+}
+
+// HandleContents serves /api/v1/books/{id}/contents and /api/v1/books/{id}/contents/{contentID}
+func (s *Server) HandleContents(w http.ResponseWriter, r *http.Request) {
+
+	if len(bookPathSegments(r)) == 2 {
+		switch r.Method {
+		case http.MethodGet:
+			s.HandleGetContents(w, r)
+		case http.MethodPost:
+			s.HandleCreateContent(w, r)
+		default:
+			s.methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+		}
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		s.HandleUpdateContent(w, r)
+	case http.MethodDelete:
+		s.HandleDeleteContent(w, r)
+	default:
+		s.methodNotAllowed(w, r, http.MethodPut, http.MethodDelete)
+	}
+
+}
+
+func (s *Server) HandleGetContents(w http.ResponseWriter, r *http.Request) {
+
+	// check if user can read the books
+	if !auth.Can(currentUser(r).Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	bookID, ok := s.bookIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	contents, err := s.db.GetContents(uint(bookID))
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	if contents == nil {
+		contents = []models.Content{}
+	}
+
+	s.writeJSON(w, r, http.StatusOK, contentCollection{Contents: contents})
+}
+
+func (s *Server) HandleCreateContent(w http.ResponseWriter, r *http.Request) {
+
+	// get the request body
+	var reqBody contentRequestBody
+	if !s.readJSON(w, r, &reqBody) {
+		return
+	}
+
+	// check the permission before the fields of the entry
+	book, ok := s.editableBook(w, r)
+	if !ok {
+		return
+	}
+	if err := validation.Validate(&reqBody); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	content := reqBody.content(book.ID)
+	if err := s.db.CreateContent(&content, book.Version); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	book.Version++
+	w.Header().Set("ETag", bookETag(book))
+	s.writeJSON(w, r, http.StatusCreated, content)
+}
+
+// HandleUpdateContent renames the entry and moves it to its parent and position
+func (s *Server) HandleUpdateContent(w http.ResponseWriter, r *http.Request) {
+
+	contentID, ok := s.contentIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	// get the request body
+	var reqBody contentRequestBody
+	if !s.readJSON(w, r, &reqBody) {
+		return
+	}
+
+	// check the permission before the fields of the entry
+	book, ok := s.editableBook(w, r)
+	if !ok {
+		return
+	}
+	if err := validation.Validate(&reqBody); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	content := reqBody.content(book.ID)
+	content.ID = contentID
+	if err := s.db.UpdateContent(&content, book.Version); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	book.Version++
+	w.Header().Set("ETag", bookETag(book))
+	s.writeMessage(w, r, http.StatusOK, "the entry was updated successfully")
+}
+
+// HandleDeleteContent deletes the entry with the entries nested in it
+func (s *Server) HandleDeleteContent(w http.ResponseWriter, r *http.Request) {
+
+	contentID, ok := s.contentIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	book, ok := s.editableBook(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.db.DeleteContent(book.ID, contentID, book.Version); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	book.Version++
+	w.Header().Set("ETag", bookETag(book))
+	s.writeMessage(w, r, http.StatusOK, "the entry was deleted successfully")
+}
+
+// editableBook returns the book of the path if the user can edit it
+// and the If-Match header has its current ETag.
+// Otherwise it writes the problem response and returns false.
+func (s *Server) editableBook(w http.ResponseWriter, r *http.Request) (*models.Book, bool) {
+
+	bookID, ok := s.bookIDFromPath(w, r)
+	if !ok {
+		return nil, false
+	}
+
+	book, err := s.db.GetBook(bookID)
+	if err != nil {
+		s.writeError(w, r, err)
+		return nil, false
+	}
+
+	// the owner of a book never changes, so it can be checked before the update
+	user := currentUser(r)
+	if !auth.Can(user.Role, auth.PermissionEditAnyBook) &&
+		!(auth.Can(user.Role, auth.PermissionEditOwnBook) && book.UserID == user.ID) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return nil, false
+	}
+
+	if !s.checkIfMatch(w, r, book) {
+		return nil, false
+	}
+
+	return book, true
+}
+
+// contentIDFromPath returns the id in /api/v1/books/{id}/contents/{contentID}.
+// It writes the problem response and returns false when the id is not a number.
+func (s *Server) contentIDFromPath(w http.ResponseWriter, r *http.Request) (uint, bool) {
+
+	contentID, err := strconv.ParseUint(bookPathSegments(r)[2], 10, 0)
+	if err != nil {
+		s.writeError(w, r, db.ErrContentNotFound)
+		return 0, false
+	}
+
+	return uint(contentID), true
+}
+
+func (body *contentRequestBody) content(bookID uint) models.Content {
+
+	content := models.Content{
+		ContentName: body.Name,
+		BookId:      bookID,
+		ParentID:    body.ParentID,
+		Position:    -1,
+		Kind:        body.Kind,
+		Page:        body.Page,
+		Children:    body.Children,
+	}
+	if body.Position != nil {
+		content.Position = *body.Position
+	}
+
+	return content
+}
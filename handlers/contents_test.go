@@ -0,0 +1,53 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+)
+
+func TestContentsPermission(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		user   string
+		method string
+		entry  bool
+		body   string
+		status int
+		code   string
+	}{
+		// the permission is checked before the fields
+		{"create invalid by a member", "reza", http.MethodPost, false, `{"name": ""}`, http.StatusForbidden, "permission_denied"},
+		{"create by a member", "reza", http.MethodPost, false, `{"name": "Prologue", "kind": "chapter"}`, http.StatusForbidden, "permission_denied"},
+		{"create by a librarian", "sara", http.MethodPost, false, `{"name": "Prologue", "kind": "chapter"}`, http.StatusForbidden, "permission_denied"},
+		{"update invalid by a member", "reza", http.MethodPut, true, `{"name": ""}`, http.StatusForbidden, "permission_denied"},
+		{"delete by a member", "reza", http.MethodDelete, true, "", http.StatusForbidden, "permission_denied"},
+		{"create invalid by the owner", "ali", http.MethodPost, false, `{"name": ""}`, http.StatusUnprocessableEntity, codeValidationFailed},
+		{"update invalid by the owner", "ali", http.MethodPut, true, `{"name": ""}`, http.StatusUnprocessableEntity, codeValidationFailed},
+		{"create by the owner", "ali", http.MethodPost, false, `{"name": "Prologue", "kind": "chapter"}`, http.StatusCreated, ""},
+		{"create by an admin", "root", http.MethodPost, false, `{"name": "Prologue", "kind": "chapter"}`, http.StatusCreated, ""},
+		{"delete by an admin", "root", http.MethodDelete, true, "", http.StatusOK, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			book := ts.createDune(t, "ali")
+			path := fmt.Sprintf("/api/v1/books/%d/contents", book.ID)
+			if tt.entry {
+				path += fmt.Sprintf("/%d", book.TableOfContents[0].ID)
+			}
+
+			resp := ts.do(t, tt.user, tt.method, path, tt.body, "Content-Type", "application/json", "If-Match", bookETag(&book))
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.code != "" {
+				if code := problemCode(t, resp); code != tt.code {
+					t.Errorf("code = %q, want %q", code, tt.code)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/auth"
+	"github.com/Parsa-Sh-Y/book-manager-service/db"
+	"github.com/Parsa-Sh-Y/book-manager-service/metadata"
+	"github.com/Parsa-Sh-Y/book-manager-service/validation"
+)
+
+// problem is the body of every error response, in the problem details
+// format of RFC 7807. Code is stable, so the clients can branch on it.
+type problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Code      string `json:"code"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	// Errors are the invalid fields of the request body
+	Errors validation.Errors `json:"errors,omitempty"`
+}
+
+// The codes of the errors raised by the handlers themselves
+const (
+	codeInvalidRequestBody   = "invalid_request_body"
+	codeInvalidParameter     = "invalid_parameter"
+	codeMethodNotAllowed     = "method_not_allowed"
+	codeUnsupportedMediaType = "unsupported_media_type"
+	codeFileTooLarge         = "file_too_large"
+	codeNotFound             = "not_found"
+	codeMissingToken         = "missing_token"
+	codeInvalidCredentials   = "invalid_credentials"
+	codePreconditionRequired = "precondition_required"
+	codePreconditionFailed   = "precondition_failed"
+	codeValidationFailed     = "validation_failed"
+	codeInternalError        = "internal_error"
+)
+
+// errorCode is the status and the code of a sentinel error
+type errorCode struct {
+	err    error
+	status int
+	code   string
+}
+
+// errorCodes maps the sentinel errors of db, auth and metadata to their responses
+var errorCodes = []errorCode{
+	{db.ErrEmailIsInUse, http.StatusConflict, "email_in_use"},
+	{db.ErrUsernameIsInUse, http.StatusConflict, "username_in_use"},
+	{db.ErrPhoneNumberIsInUse, http.StatusConflict, "phone_number_in_use"},
+	{db.ErrUserNotFound, http.StatusNotFound, "user_not_found"},
+	{db.ErrBookNotFound, http.StatusNotFound, "book_not_found"},
+	{db.ErrContentNotFound, http.StatusNotFound, "content_not_found"},
+	{db.ErrInvalidContentParent, http.StatusUnprocessableEntity, "invalid_content_parent"},
+	{db.ErrAuthorNotFound, http.StatusNotFound, "author_not_found"},
+	{db.ErrAuthorHasBooks, http.StatusConflict, "author_has_books"},
+	{db.ErrUnknownAuthor, http.StatusUnprocessableEntity, "unknown_author"},
+	{db.ErrPublisherNotFound, http.StatusNotFound, "publisher_not_found"},
+	{db.ErrPublisherNameIsInUse, http.StatusConflict, "publisher_name_in_use"},
+	{db.ErrPublisherHasBooks, http.StatusConflict, "publisher_has_books"},
+	{db.ErrUnknownPublisher, http.StatusUnprocessableEntity, "unknown_publisher"},
+	{db.ErrCategoryNotFound, http.StatusNotFound, "category_not_found"},
+	{db.ErrCategoryNameIsInUse, http.StatusConflict, "category_name_in_use"},
+	{db.ErrInvalidCategoryParent, http.StatusUnprocessableEntity, "invalid_category_parent"},
+	{db.ErrCategoryNotEmpty, http.StatusConflict, "category_not_empty"},
+	{db.ErrUnknownCategory, http.StatusUnprocessableEntity, "unknown_category"},
+	{db.ErrTagNotFound, http.StatusNotFound, "tag_not_found"},
+	{db.ErrSeriesNotFound, http.StatusNotFound, "series_not_found"},
+	{db.ErrSeriesNameIsInUse, http.StatusConflict, "series_name_in_use"},
+	{db.ErrSeriesHasBooks, http.StatusConflict, "series_has_books"},
+	{db.ErrUnknownSeries, http.StatusUnprocessableEntity, "unknown_series"},
+	{db.ErrNoNextVolume, http.StatusNotFound, "no_next_volume"},
+	{db.ErrISBNIsInUse, http.StatusConflict, "isbn_in_use"},
+	{db.ErrISBNMismatch, http.StatusUnprocessableEntity, "isbn_mismatch"},
+	{metadata.ErrNotFound, http.StatusNotFound, "catalog_book_not_found"},
+	{metadata.ErrUnavailable, http.StatusBadGateway, "catalog_unavailable"},
+	{db.ErrPermissionDenied, http.StatusForbidden, "permission_denied"},
+	{db.ErrBookVersionMismatch, http.StatusPreconditionFailed, codePreconditionFailed},
+	{db.ErrInvalidSortField, http.StatusBadRequest, "invalid_sort_field"},
+	{db.ErrEmptySearch, http.StatusBadRequest, "empty_search"},
+	{db.ErrSessionNotFound, http.StatusUnauthorized, "session_not_found"},
+	{db.ErrSessionRevoked, http.StatusUnauthorized, "session_revoked"},
+	{db.ErrRefreshTokenNotFound, http.StatusUnauthorized, "invalid_refresh_token"},
+	{db.ErrRefreshTokenReused, http.StatusUnauthorized, "refresh_token_reused"},
+	{auth.ErrEmptyTokenString, http.StatusUnauthorized, codeMissingToken},
+	{auth.ErrInvalidToken, http.StatusUnauthorized, "invalid_token"},
+	{auth.ErrAnuthorizedToken, http.StatusUnauthorized, "invalid_token"},
+	{auth.ErrExpiredToken, http.StatusUnauthorized, "token_expired"},
+	{auth.ErrRevokedToken, http.StatusUnauthorized, "token_revoked"},
+}
+
+// writeError writes the problem of a db, auth, metadata or validation error.
+// Unknown errors are logged and answered with 500 Internal Server Error.
+func (s *Server) writeError(w http.ResponseWriter, r *http.Request, err error) {
+
+	var fieldErrors validation.Errors
+	if errors.As(err, &fieldErrors) {
+		s.writeProblemWithErrors(w, r, http.StatusUnprocessableEntity, codeValidationFailed, "the request body has invalid fields", fieldErrors)
+		return
+	}
+
+	for _, c := range errorCodes {
+		if errors.Is(err, c.err) {
+			if c.status == http.StatusUnauthorized {
+				w.Header().Set("WWW-Authenticate", `Bearer error="invalid_token"`)
+			}
+			s.writeProblem(w, r, c.status, c.code, err.Error())
+			return
+		}
+	}
+
+	s.log(r).WithError(err).Error("unexpected error")
+	s.writeProblem(w, r, http.StatusInternalServerError, codeInternalError, "")
+}
+
+// writeProblem writes a problem response
+func (s *Server) writeProblem(w http.ResponseWriter, r *http.Request, status int, code string, detail string) {
+	s.writeProblemWithErrors(w, r, status, code, detail, nil)
+}
+
+// writeProblemWithErrors writes a problem response listing the invalid fields
+func (s *Server) writeProblemWithErrors(w http.ResponseWriter, r *http.Request, status int, code string, detail string, fieldErrors validation.Errors) {
+
+	body, err := json.Marshal(problem{
+		Type:      "/problems/" + strings.ReplaceAll(code, "_", "-"),
+		Title:     http.StatusText(status),
+		Status:    status,
+		Code:      code,
+		Detail:    detail,
+		Instance:  r.URL.Path,
+		RequestID: requestID(r),
+		Errors:    fieldErrors,
+	})
+	if err != nil {
+		s.log(r).WithError(err).Error("error trying to marshal the problem")
+		w.WriteHeader(http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// methodNotAllowed writes a 405 problem naming the allowed methods
+func (s *Server) methodNotAllowed(w http.ResponseWriter, r *http.Request, allowed ...string) {
+
+	w.Header().Set("Allow", strings.Join(allowed, ", "))
+	s.writeProblem(w, r, http.StatusMethodNotAllowed, codeMethodNotAllowed, r.Method+" is not allowed")
+}
+
+// writeJSON writes v as the JSON body of the response
+func (s *Server) writeJSON(w http.ResponseWriter, r *http.Request, status int, v interface{}) {
+
+	body, err := json.Marshal(v)
+	if err != nil {
+		s.log(r).WithError(err).Error("error trying to marshal the respone")
+		s.writeProblem(w, r, http.StatusInternalServerError, codeInternalError, "")
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// writeMessage writes a {"message": message} response
+func (s *Server) writeMessage(w http.ResponseWriter, r *http.Request, status int, message string) {
+	s.writeJSON(w, r, status, map[string]string{"message": message})
+}
@@ -0,0 +1,60 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/auth"
+	"github.com/Parsa-Sh-Y/book-manager-service/db"
+	"github.com/Parsa-Sh-Y/book-manager-service/exporter"
+)
+
+// HandleExportBooks writes every book the current user can read as a file of the format in
+// ?format=, one of csv, json, bibtex and ris. The books are filtered like GET /api/v1/books,
+// and ?mine=true keeps the books of the current user only. The file is written as the
+// books are read, so an error after the first books can only cut the file short.
+func (s *Server) HandleExportBooks(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		s.methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	account := currentUser(r)
+	if !auth.Can(account.Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	values := r.URL.Query()
+	query, err := parseBookQuery(values)
+	if err != nil {
+		s.writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	if v := values.Get("mine"); v != "" {
+		mine, err := strconv.ParseBool(v)
+		if err != nil {
+			s.writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "mine must be true or false")
+			return
+		}
+		if mine {
+			query.UserID = account.ID
+		}
+	}
+
+	format := values.Get("format")
+	writer, err := exporter.NewWriter(format, w)
+	if err != nil {
+		s.writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	w.Header().Set("Content-Type", exporter.MediaType(format))
+	w.Header().Set("Content-Disposition", `attachment; filename="`+exporter.FileName(format)+`"`)
+
+	count, err := exporter.Export(s.db, query, writer)
+	if err != nil {
+		s.log(r).WithError(err).WithField("books", count).Error("the export stopped before the last book")
+	}
+}
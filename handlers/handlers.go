@@ -2,45 +2,53 @@ package handlers
 
 import (
 	"encoding/json"
+	"fmt"
 	"io"
-	"log"
+	"mime"
 	"net/http"
-	"path"
+	"sort"
 	"strconv"
+	"strings"
 
 	"github.com/Parsa-Sh-Y/book-manager-service/auth"
 	"github.com/Parsa-Sh-Y/book-manager-service/config"
 	"github.com/Parsa-Sh-Y/book-manager-service/db"
 	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"github.com/Parsa-Sh-Y/book-manager-service/metadata"
+	"github.com/Parsa-Sh-Y/book-manager-service/validation"
 	"github.com/sirupsen/logrus"
 )
 
 type Server struct {
-	db     *db.GormDB
-	logger *logrus.Logger
-	auth   *auth.Auth
-}
-
-type tableOfContents struct {
-	Contents []string `json:"table_of_contents"`
+	db      db.Store
+	logger  *logrus.Logger
+	auth    *auth.Auth
+	catalog metadata.Provider
+	// importMaxBytes is the size of the largest file imported
+	importMaxBytes int64
 }
 
 type bookCollection struct {
-	Books *[]models.Book `json:"books"`
+	Books    *[]models.Book `json:"books"`
+	Total    int64          `json:"total"`
+	Limit    int            `json:"limit"`
+	Offset   int            `json:"offset"`
+	Next     string         `json:"next,omitempty"`
+	Previous string         `json:"previous,omitempty"`
 }
 
-type updateRequestBody struct {
-	Name     string `json:"name"`
-	Category string `json:"category"`
+type searchResult struct {
+	Book       models.Book       `json:"book"`
+	Rank       float64           `json:"rank"`
+	Highlights map[string]string `json:"highlights"`
 }
 
-type respone struct {
-	Message string `json:"message"`
+type searchResults struct {
+	Results []searchResult `json:"results"`
 }
 
-func (r *respone) json() []byte {
-	res, _ := json.Marshal(r.Message)
-	return res
+type refreshRequestBody struct {
+	RefreshToken string `json:"refresh_token"`
 }
 
 func CreateNewServer(conf config.Config) *Server {
@@ -55,7 +63,11 @@ func CreateNewServer(conf config.Config) *Server {
 	if err != nil {
 		logger.WithError(err).Fatal("error in connecting to the database")
 	}
-	logger.Infof("connected to the %s database", conf.Database.Name)
+	if conf.Database.Driver == db.DriverSQLite {
+		logger.Infof("connected to the %s sqlite database", conf.Database.Path)
+	} else {
+		logger.Infof("connected to the %s database", conf.Database.Name)
+	}
 
 	// Create schema
 	// Create any tables if they don't exits
@@ -65,16 +77,49 @@ func CreateNewServer(conf config.Config) *Server {
 	}
 	logger.Infoln("migrate tables and models successfully")
 
+	// Load the jwt signing keys
+	keys, err := auth.LoadKeySet(conf.JwtSigningKeys, conf.JwtKeyFile, conf.JwtActiveKeyID)
+	if err != nil {
+		logger.WithError(err).Fatal("can not load the jwt signing keys")
+	}
+	if keys == nil {
+		logger.Warn("no jwt signing key is configured, the tokens will not survive a restart")
+		keys, err = auth.GenerateKeySet()
+		if err != nil {
+			logger.WithError(err).Fatal("can not generate a jwt signing key")
+		}
+	}
+
 	// Create authenticate
-	auth, err := auth.NewAuth(gormDB, conf.JwtExpirationInMinutes)
+	auth, err := auth.NewAuth(gormDB, keys, auth.NewOptions(conf))
 	if err != nil {
 		logger.WithError(err).Fatal("can not create the authenticate instance")
 	}
 
+	// Create the catalog the books are looked up in
+	catalog, err := metadata.NewProvider(conf)
+	if err != nil {
+		logger.WithError(err).Fatal("can not create the metadata provider")
+	}
+
+	server := NewServer(gormDB, auth, catalog, logger)
+	server.importMaxBytes = conf.Import.MaxFileSizeInMegabytes << 20
+
+	return server
+
+}
+
+// NewServer creates a Server on top of an already prepared store and catalog.
+// It lets the handlers run against any db.Store, e.g. db.MemoryDB,
+// and any metadata.Provider, e.g. metadata.Fixture.
+func NewServer(store db.Store, auth *auth.Auth, catalog metadata.Provider, logger *logrus.Logger) *Server {
+
 	return &Server{
-		db:     gormDB,
-		logger: logger,
-		auth:   auth,
+		db:             store,
+		logger:         logger,
+		auth:           auth,
+		catalog:        catalog,
+		importMaxBytes: defaultImportMaxBytes,
 	}
 
 }
@@ -83,42 +128,34 @@ func (s *Server) HandleSignup(w http.ResponseWriter, r *http.Request) {
 
 	// check if method is post
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		s.methodNotAllowed(w, r, http.MethodPost)
 		return
 	}
 
 	// parse the request body
-	reqData, err := io.ReadAll(r.Body)
-	if err != nil {
-		s.logger.WithError(err).Warn("Can not read the request body")
-		w.WriteHeader(http.StatusInternalServerError)
+	var user models.User
+	if !s.readJSON(w, r, &user) {
 		return
 	}
 
-	var user models.User
+	// new accounts are always members, admins grant the other roles
+	user.Role = models.RoleMember
 
-	err = json.Unmarshal(reqData, &user)
-	if err != nil {
-		s.logger.WithError(err).Warn("can not unmarshal the request body")
-		w.WriteHeader(http.StatusBadRequest)
+	// check the fields of the user
+	if err := validation.Validate(&user); err != nil {
+		s.writeError(w, r, err)
 		return
 	}
 
 	// add the user to the database
-	// TODO : handle different errors individually
-	err = s.db.CreateUser(&user)
+	err := s.db.CreateUser(&user)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.logger.WithError(err).Warn("can not create a new user")
+		s.log(r).WithError(err).Warn("can not create a new user")
+		s.writeError(w, r, err)
 		return
 	}
 
-	response := map[string]interface{}{
-		"message": "user has been created",
-	}
-	resBody, _ := json.Marshal(response)
-	w.WriteHeader(http.StatusOK)
-	w.Write(resBody)
+	s.writeMessage(w, r, http.StatusOK, "user has been created")
 
 }
 
@@ -126,67 +163,73 @@ func (s *Server) HandleLogin(w http.ResponseWriter, r *http.Request) {
 
 	// check if mehtod is POST
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusBadRequest)
+		s.methodNotAllowed(w, r, http.MethodPost)
 		return
 	}
 
-	// check if request body is empty
-	if r.Body == nil {
-		w.WriteHeader(http.StatusBadRequest)
+	// parse the request body
+	var cred auth.UserCredentials
+	if !s.readJSON(w, r, &cred) {
 		return
 	}
 
-	// get the request body
-	body, err := io.ReadAll(r.Body)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.WithError(err).Error("error reading the request body")
+	// an unknown username and a wrong password get the same answer,
+	// so the usernames in use can not be found out by logging in
+	tokens, err := s.auth.Login(&cred)
+	if err == db.ErrUserNotFound || err == auth.ErrIncorrectPassword {
+		s.writeProblem(w, r, http.StatusUnauthorized, codeInvalidCredentials, "the username or the password is incorrect")
+		return
+	} else if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, tokens)
+}
+
+func (s *Server) HandleRefresh(w http.ResponseWriter, r *http.Request) {
+
+	// check if mehtod is POST
+	if r.Method != http.MethodPost {
+		s.methodNotAllowed(w, r, http.MethodPost)
 		return
 	}
 
-	var cred auth.UserCredentials
 	// parse the request body
-	err = json.Unmarshal(body, &cred)
+	var reqBody refreshRequestBody
+	if !s.readJSON(w, r, &reqBody) {
+		return
+	}
+
+	// exchange the refresh token for new tokens
+	tokens, err := s.auth.Refresh(reqBody.RefreshToken)
+	if err == db.ErrRefreshTokenReused {
+		s.log(r).Warn("a used refresh token was presented again, its session is revoked")
+	}
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.logger.WithError(err).Warn("could not parse the request body")
+		s.writeError(w, r, err)
 		return
 	}
 
-	token, err := s.auth.Login(&cred)
-	if err == db.ErrUserNotFound {
-		respone, err := json.Marshal(map[string]interface{}{"message": "no such username exists"})
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			s.logger.WithError(err).Error("error trying to marshal the respone message")
-			return
-		}
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(respone)
-	} else if err == auth.ErrIncorrectPassword {
-		respone, err := json.Marshal(map[string]interface{}{"message": "incorrect password"})
-		if err != nil {
-			w.WriteHeader(http.StatusInternalServerError)
-			s.logger.WithError(err).Error("error trying to marshal the respone message")
-			return
-		}
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(respone)
-	} else if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.WithError(err).Error("error while logging in the user")
+	s.writeJSON(w, r, http.StatusOK, tokens)
+}
+
+func (s *Server) HandleLogout(w http.ResponseWriter, r *http.Request) {
+
+	// check if mehtod is POST
+	if r.Method != http.MethodPost {
+		s.methodNotAllowed(w, r, http.MethodPost)
 		return
 	}
 
-	respone, err := json.Marshal(map[string]string{"access_token": token})
+	// revoke the session of the token
+	err := s.auth.Logout(currentIdentity(r))
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.WithError(err).Error("error trying to marshal respone message")
+		s.writeError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(respone)
+	s.writeMessage(w, r, http.StatusOK, "logged out successfully")
 }
 
 func (s *Server) HandleBooksRoot(w http.ResponseWriter, r *http.Request) {
@@ -197,13 +240,31 @@ func (s *Server) HandleBooksRoot(w http.ResponseWriter, r *http.Request) {
 	case http.MethodGet:
 		s.HandleGetAllBooks(w, r)
 	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		s.methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
 	}
 
 }
 
+// HandleBooksSubtree serves /api/v1/books/{id}, the table of contents under /api/v1/books/{id}/contents
+// and the lookup of the books of the user by ISBN under /api/v1/books/isbn/{isbn}
 func (s *Server) HandleBooksSubtree(w http.ResponseWriter, r *http.Request) {
 
+	if segments := bookPathSegments(r); segments[0] == "isbn" {
+		if len(segments) != 2 {
+			s.writeProblem(w, r, http.StatusNotFound, codeNotFound, "")
+			return
+		}
+		s.HandleGetBookByISBN(w, r, segments[1])
+		return
+	} else if len(segments) > 1 {
+		if segments[1] != "contents" || len(segments) > 3 {
+			s.writeProblem(w, r, http.StatusNotFound, codeNotFound, "")
+			return
+		}
+		s.HandleContents(w, r)
+		return
+	}
+
 	switch r.Method {
 	case http.MethodDelete:
 		s.HandleDelete(w, r)
@@ -211,8 +272,10 @@ func (s *Server) HandleBooksSubtree(w http.ResponseWriter, r *http.Request) {
 		s.HandleGetBook(w, r)
 	case http.MethodPut:
 		s.HandleUpdate(w, r)
+	case http.MethodPatch:
+		s.HandlePatch(w, r)
 	default:
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		s.methodNotAllowed(w, r, http.MethodGet, http.MethodPut, http.MethodPatch, http.MethodDelete)
 	}
 
 }
@@ -221,273 +284,508 @@ func (s *Server) HandleCreateBook(w http.ResponseWriter, r *http.Request) {
 
 	// check if method is POST
 	if r.Method != http.MethodPost {
-		w.WriteHeader(http.StatusBadRequest)
+		s.methodNotAllowed(w, r, http.MethodPost)
 		return
 	}
 
-	token := r.Header.Get("Authorization")
+	account := currentUser(r)
+	if !auth.Can(account.Role, auth.PermissionCreateBook) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
 
-	username, err := s.auth.GetUsernameByToken(token)
-	if err != nil {
-		if err == auth.ErrCanNotValidateToken {
-			w.WriteHeader(http.StatusInternalServerError)
-			s.logger.WithError(err).Error("error validating user token")
-			return
-		} else {
-			w.WriteHeader(http.StatusBadRequest)
-			s.logger.WithError(err).Warn("the was a problem with the token provided")
-			return
-		}
+	// parse the request body
+	var book models.Book
+	if !s.readJSON(w, r, &book) {
+		return
 	}
+	book.UserID = account.ID // set the use who made the request as the owner of the book
 
-	account, err := s.db.GetUserByUsername(username)
-	if err == db.ErrUserNotFound {
-		w.WriteHeader(http.StatusBadRequest)
+	// check the fields of the book
+	if err := validation.Validate(&book); err != nil {
+		s.writeError(w, r, err)
 		return
-	} else if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.WithError(err).Error("error retrieving the user from database")
+	}
+
+	// add each content to the book instance
+	setBookContents(&book)
+
+	err := s.db.CreateBook(&book)
+	if err != nil {
+		s.writeError(w, r, err)
 		return
 	}
 
-	var book models.Book
-	var table tableOfContents
-	// check if request body is empty
-	if r.Body == nil {
-		w.WriteHeader(http.StatusBadRequest)
+	s.writeMessage(w, r, http.StatusOK, "book was created successfully")
+}
+
+func (s *Server) HandleGetBook(w http.ResponseWriter, r *http.Request) {
+
+	// check if method is get
+	if r.Method != http.MethodGet {
+		s.methodNotAllowed(w, r, http.MethodGet)
 		return
 	}
-	reqData, err := io.ReadAll(r.Body)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.WithError(err).Error("error reading request body")
+
+	// check if user can read the books
+	if !auth.Can(currentUser(r).Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
 		return
 	}
 
-	err = json.Unmarshal(reqData, &table)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.logger.WithError(err).Warn("there was an error when parsing the request body(table of contents)")
+	bookID, ok := s.bookIDFromPath(w, r)
+	if !ok {
 		return
 	}
-	err = json.Unmarshal(reqData, &book)
+
+	// Get the book from the database
+	book, err := s.db.GetBook(bookID)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.logger.WithError(err).Warn("there was an error when parsing the request body(book)")
+		s.writeError(w, r, err)
 		return
 	}
-	book.UserID = account.ID // set the use who made the request as the owner of the book
 
-	// add each content to the book instance
-	for _, content := range table.Contents {
-		book.TableOfContents = append(book.TableOfContents, models.Content{ContentName: content})
+	// the client already has this version of the book
+	w.Header().Set("ETag", bookETag(book))
+	if header := r.Header.Get("If-None-Match"); header != "" && etagMatches(header, bookETag(book), true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
 	}
 
-	err = s.db.CreateBook(&book)
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		log.Print(err.Error())
+	// Populate TableOfContentsJson field
+	book.TableOfContentsJson = models.ContentNames(book.TableOfContents)
+
+	s.writeJSON(w, r, http.StatusOK, book)
+}
+
+// HandleGetBookByISBN returns the book of the current user with the ISBN.
+// The books of all the users with an ISBN are listed by GET /api/v1/books?isbn=
+func (s *Server) HandleGetBookByISBN(w http.ResponseWriter, r *http.Request, number string) {
+
+	if r.Method != http.MethodGet {
+		s.methodNotAllowed(w, r, http.MethodGet)
 		return
 	}
 
-	message := map[string]interface{}{
-		"message": "book was created successfully",
+	account := currentUser(r)
+	if !auth.Can(account.Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
 	}
 
-	respone, err := json.Marshal(message)
+	book, err := s.db.GetUserBookByISBN(account.ID, number)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.WithError(err).Error("error trying to marshal the respone message")
+		s.writeError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(respone)
+	// the client already has this version of the book
+	w.Header().Set("ETag", bookETag(book))
+	if header := r.Header.Get("If-None-Match"); header != "" && etagMatches(header, bookETag(book), true) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+
+	book.TableOfContentsJson = models.ContentNames(book.TableOfContents)
+
+	s.writeJSON(w, r, http.StatusOK, book)
 }
 
-func (s *Server) HandleGetBook(w http.ResponseWriter, r *http.Request) {
+func (s *Server) HandleGetAllBooks(w http.ResponseWriter, r *http.Request) {
 
-	// check if method is get
+	// check if method is GET
 	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		s.methodNotAllowed(w, r, http.MethodGet)
 		return
 	}
 
-	// check if user is logged in
-	token := r.Header.Get("Authorization")
-	_, err := s.auth.GetUsernameByToken(token)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.logger.WithError(err).Warn("could not log in the user")
+	// check if user can read the books
+	if !auth.Can(currentUser(r).Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
 		return
 	}
 
-	bookID, err := strconv.Atoi(path.Base(r.URL.Path))
+	// parse the filters and the page
+	query, err := parseBookQuery(r.URL.Query())
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
 		return
 	}
 
-	// Get the book from the database
-	book, err := s.db.GetBook(bookID)
-	if err != nil {
-		// TODO : check for different errors
-		w.WriteHeader(http.StatusBadRequest)
-		return
-	}
+	s.writeBooks(w, r, query)
+}
 
-	// Populate TableOfContentsJson field
-	for _, content := range book.TableOfContents {
-		book.TableOfContentsJson = append(book.TableOfContentsJson, content.ContentName)
-	}
+// writeBooks writes the page of books matching the query
+func (s *Server) writeBooks(w http.ResponseWriter, r *http.Request, query db.BookQuery) {
 
-	// Create the response
-	response, err := json.Marshal(&book)
+	books := bookCollection{Limit: query.Limit, Offset: query.Offset}
+	// get the page of books from the database
+	var err error
+	books.Books, books.Total, err = s.db.GetAllBooks(query)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.WithError(err).Error("error while trying to marshal a requested book")
+		s.writeError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(response)
+	// link the neighbouring pages
+	books.Next, books.Previous = pageLinks(r, books.Total, query.Limit, query.Offset)
+
+	s.writeJSON(w, r, http.StatusOK, books)
 }
 
-func (s *Server) HandleGetAllBooks(w http.ResponseWriter, r *http.Request) {
+func (s *Server) HandleSearchBooks(w http.ResponseWriter, r *http.Request) {
 
 	// check if method is GET
 	if r.Method != http.MethodGet {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		s.methodNotAllowed(w, r, http.MethodGet)
 		return
 	}
 
-	// check if user is logged in
-	token := r.Header.Get("Authorization")
-	_, err := s.auth.GetUsernameByToken(token)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.logger.WithError(err).Warn("could not log in the user")
+	// check if user can read the books
+	if !auth.Can(currentUser(r).Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
 		return
 	}
 
-	var books bookCollection
-	// get all books from the database
-	books.Books, err = s.db.GetAllBooks()
-	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.WithError(err).Error("error retrieving all the books from the database")
-		return
+	// parse the search text and the number of results
+	text := r.URL.Query().Get("q")
+	limit := defaultPageLimit
+	if v := r.URL.Query().Get("limit"); v != "" {
+		var err error
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxPageLimit {
+			s.writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "limit must be between 1 and 100")
+			return
+		}
 	}
 
-	// create the respone
-	respone, err := json.Marshal(&books)
+	// search the books
+	matches, err := s.db.SearchBooks(text, limit)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.WithError(err).Error("error trying to marshal the respone")
+		s.writeError(w, r, err)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
-	w.Write(respone)
+	results := searchResults{Results: make([]searchResult, 0, len(matches))}
+	for _, match := range matches {
+		// Populate TableOfContentsJson field
+		match.Book.TableOfContentsJson = models.ContentNames(match.Book.TableOfContents)
+		results.Results = append(results.Results, searchResult{Book: match.Book, Rank: match.Rank, Highlights: match.Highlights})
+	}
+
+	s.writeJSON(w, r, http.StatusOK, results)
 }
 
 func (s *Server) HandleDelete(w http.ResponseWriter, r *http.Request) {
 
 	// check if method is delete
 	if r.Method != http.MethodDelete {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		s.methodNotAllowed(w, r, http.MethodDelete)
 		return
 	}
 
-	// check if user is logged in
-	token := r.Header.Get("Authorization")
-	username, err := s.auth.GetUsernameByToken(token)
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.logger.WithError(err).Warn("could not log in the user")
+	user := currentUser(r)
+
+	// get the book id
+	bookID, ok := s.bookIDFromPath(w, r)
+	if !ok {
 		return
 	}
 
-	// get the book id
-	bookID, err := strconv.Atoi(path.Base(r.URL.Path))
+	// get the current book
+	stored, err := s.db.GetBook(bookID)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+		s.writeError(w, r, err)
 		return
 	}
 
-	// delete the book
-	err = s.db.DeleteUserBook(username, uint(bookID))
-	var res respone
-	if err == db.ErrBookNotFound || err == db.ErrUserNotFound || err == db.ErrPermissionDenied {
-		res.Message = err.Error()
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(res.json())
-	} else if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.WithError(err).Error("error deleting a book")
+	// check the permission before the version, which the other users do not get to see
+	if !auth.Can(user.Role, auth.PermissionDeleteAnyBook) &&
+		!(auth.Can(user.Role, auth.PermissionDeleteOwnBook) && stored.UserID == user.ID) {
+		s.writeError(w, r, db.ErrPermissionDenied)
 		return
+	}
+	if !s.checkIfMatch(w, r, stored) {
+		return
+	}
+
+	// delete the book, any book or only the user's own books
+	if auth.Can(user.Role, auth.PermissionDeleteAnyBook) {
+		err = s.db.DeleteBook(stored.ID, stored.Version)
+	} else if auth.Can(user.Role, auth.PermissionDeleteOwnBook) {
+		err = s.db.DeleteUserBook(user.Username, stored.ID, stored.Version)
 	} else {
-		res.Message = "Book was deleted successfully"
-		w.WriteHeader(http.StatusOK)
-		w.Write(res.json())
+		err = db.ErrPermissionDenied
+	}
+	if err != nil {
+		s.writeError(w, r, err)
+		return
 	}
+
+	s.writeMessage(w, r, http.StatusOK, "Book was deleted successfully")
 }
 
+// HandleUpdate replaces the whole book, the fields missing from the request body are cleared
 func (s *Server) HandleUpdate(w http.ResponseWriter, r *http.Request) {
 
 	// check if method is PUT
 	if r.Method != http.MethodPut {
-		w.WriteHeader(http.StatusMethodNotAllowed)
+		s.methodNotAllowed(w, r, http.MethodPut)
+		return
+	}
+
+	// get book id
+	bookID, ok := s.bookIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	// get the request body
+	var book models.Book
+	if !s.readJSON(w, r, &book) {
 		return
 	}
 
-	// check if user is logged in
-	token := r.Header.Get("Authorization")
-	username, err := s.auth.GetUsernameByToken(token)
+	// get the current book
+	stored, err := s.db.GetBook(bookID)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		s.logger.WithError(err).Warn("could not log in the user")
+		s.writeError(w, r, err)
+		return
+	}
+	book.ID = stored.ID
+
+	s.replaceBook(w, r, &book, stored)
+}
+
+// HandlePatch changes the fields of the book in the request body,
+// which is a JSON Merge Patch (RFC 7386) of the book
+func (s *Server) HandlePatch(w http.ResponseWriter, r *http.Request) {
+
+	// check if method is PATCH
+	if r.Method != http.MethodPatch {
+		s.methodNotAllowed(w, r, http.MethodPatch)
+		return
+	}
+
+	// check the media type of the patch
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != mergePatchMediaType && mediaType != "application/json" {
+		w.Header().Set("Accept-Patch", mergePatchMediaType)
+		s.writeProblem(w, r, http.StatusUnsupportedMediaType, codeUnsupportedMediaType, "the patch must be "+mergePatchMediaType)
 		return
 	}
 
 	// get book id
-	bookID, err := strconv.Atoi(path.Base(r.URL.Path))
-	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
+	bookID, ok := s.bookIDFromPath(w, r)
+	if !ok {
 		return
 	}
 
 	// get the request body
+	var patch interface{}
+	if !s.readJSON(w, r, &patch) {
+		return
+	}
 
-	var reqBody updateRequestBody
-
-	reqData, err := io.ReadAll(r.Body)
+	// get the current book
+	stored, err := s.db.GetBook(bookID)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.WithError(err).Error("can not read request body")
+		s.writeError(w, r, err)
 		return
 	}
-	err = json.Unmarshal(reqData, &reqBody)
+
+	// apply the patch to the JSON document of the book
+	book, err := patchBook(stored, patch)
 	if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.WithError(err).Error("error trying to parse request body")
+		s.writeProblem(w, r, http.StatusBadRequest, codeInvalidRequestBody, err.Error())
 		return
 	}
+	book.ID = stored.ID
 
-	// update the book
-	err = s.db.UpdateUserBook(username, uint(bookID), reqBody.Name, reqBody.Category)
-	var res respone
-	if err == db.ErrBookNotFound || err == db.ErrUserNotFound || err == db.ErrPermissionDenied {
-		res.Message = err.Error()
-		w.WriteHeader(http.StatusBadRequest)
-		w.Write(res.json())
-	} else if err != nil {
-		w.WriteHeader(http.StatusInternalServerError)
-		s.logger.WithError(err).Error("error updating a book")
+	s.replaceBook(w, r, book, stored)
+}
+
+// replaceBook validates the book and saves it in place of stored,
+// if stored is still at the version of the If-Match header
+func (s *Server) replaceBook(w http.ResponseWriter, r *http.Request, book *models.Book, stored *models.Book) {
+
+	user := currentUser(r)
+
+	// check the permission before the version and the fields of the book
+	if err := canReplaceBook(user, stored, book); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	if !s.checkIfMatch(w, r, stored) {
 		return
+	}
+
+	// check the fields of the book
+	if err := validation.Validate(book); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	setBookContents(book)
+
+	// update the book, the users' own books only while they still own them
+	var err error
+	if stored.UserID == user.ID && !auth.Can(user.Role, auth.PermissionEditAnyBook) {
+		err = s.db.UpdateUserBook(user.Username, book, stored.Version)
 	} else {
-		res.Message = "Book was updated successfully"
-		w.WriteHeader(http.StatusOK)
-		w.Write(res.json())
+		err = s.db.UpdateBook(book, stored.Version)
+	}
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	w.Header().Set("ETag", bookETag(book))
+	s.writeMessage(w, r, http.StatusOK, "Book was updated successfully")
+}
+
+// canReplaceBook returns db.ErrPermissionDenied when the user may not replace stored with book.
+// The users edit every field of their own books, but only the catalog fields of the books of others
+// unless they may edit any book.
+func canReplaceBook(user *models.User, stored *models.Book, book *models.Book) error {
+
+	if auth.Can(user.Role, auth.PermissionEditAnyBook) ||
+		auth.Can(user.Role, auth.PermissionEditOwnBook) && stored.UserID == user.ID {
+		return nil
+	}
+	if !auth.Can(user.Role, auth.PermissionEditCatalog) {
+		return db.ErrPermissionDenied
+	}
+
+	if fields := ownerFieldChanges(stored, book); len(fields) > 0 {
+		return fmt.Errorf("%w: only the catalog fields of the books of other users can be changed, not %s",
+			db.ErrPermissionDenied, strings.Join(fields, ", "))
+	}
+	return nil
+}
+
+// ownerFieldChanges returns the names of the fields other than the catalog ones that differ
+// between stored and book: the name, the summary, the rating, the tags and the contents
+func ownerFieldChanges(stored *models.Book, book *models.Book) []string {
+
+	var fields []string
+	if book.Name != stored.Name {
+		fields = append(fields, "name")
+	}
+	if book.Summary != stored.Summary {
+		fields = append(fields, "summary")
+	}
+	if book.Rating != stored.Rating {
+		fields = append(fields, "rating")
+	}
+	if !sameTags(stored.Tags, book.Tags) {
+		fields = append(fields, "tags")
+	}
+
+	// the contents of the request may be a list of names
+	contents := book.TableOfContents
+	if len(contents) == 0 {
+		contents = models.ContentsFromNames(book.TableOfContentsJson)
+	}
+	if !sameContents(stored.TableOfContents, contents) {
+		fields = append(fields, "contents")
+	}
+
+	return fields
+}
+
+// sameTags reports whether a and b have the same tag names, in any order and case
+func sameTags(a []models.Tag, b []models.Tag) bool {
+
+	names := func(tags []models.Tag) []string {
+		list := make([]string, 0, len(tags))
+		for _, tag := range tags {
+			list = append(list, strings.ToLower(strings.TrimSpace(tag.Name)))
+		}
+		sort.Strings(list)
+		return list
+	}
+
+	x, y := names(a), names(b)
+	if len(x) != len(y) {
+		return false
+	}
+	for i := range x {
+		if x[i] != y[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// sameContents reports whether a and b are the same tree of names, kinds and pages
+func sameContents(a []models.Content, b []models.Content) bool {
+
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i].ContentName != b[i].ContentName || a[i].Kind != b[i].Kind ||
+			(a[i].Page == nil) != (b[i].Page == nil) || a[i].Page != nil && *a[i].Page != *b[i].Page ||
+			!sameContents(a[i].Children, b[i].Children) {
+			return false
+		}
+	}
+	return true
+}
+
+// readJSON parses the request body into each of targets.
+// It writes the problem response and returns false when the body can not be parsed.
+func (s *Server) readJSON(w http.ResponseWriter, r *http.Request, targets ...interface{}) bool {
+
+	// check if request body is empty
+	if r.Body == nil {
+		s.writeProblem(w, r, http.StatusBadRequest, codeInvalidRequestBody, "the request body is empty")
+		return false
+	}
+
+	reqData, err := io.ReadAll(r.Body)
+	if err != nil {
+		s.log(r).WithError(err).Error("error reading the request body")
+		s.writeProblem(w, r, http.StatusInternalServerError, codeInternalError, "")
+		return false
 	}
+
+	for _, target := range targets {
+		if err := json.Unmarshal(reqData, target); err != nil {
+			s.log(r).WithError(err).Warn("could not parse the request body")
+			s.writeProblem(w, r, http.StatusBadRequest, codeInvalidRequestBody, err.Error())
+			return false
+		}
+	}
+
+	return true
+}
+
+// setBookContents makes the table of contents of a book from a request body.
+// The contents are either a tree or, as a shorthand, a flat list of names.
+func setBookContents(book *models.Book) {
+
+	if len(book.TableOfContents) == 0 {
+		book.TableOfContents = models.ContentsFromNames(book.TableOfContentsJson)
+	}
+
+}
+
+// bookPathSegments returns the segments of the path after /api/v1/books/
+func bookPathSegments(r *http.Request) []string {
+	return strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/books/"), "/"), "/")
+}
+
+// bookIDFromPath returns the id in /api/v1/books/{id}.
+// It writes the problem response and returns false when the id is not a number.
+func (s *Server) bookIDFromPath(w http.ResponseWriter, r *http.Request) (int, bool) {
+
+	bookID, err := strconv.Atoi(bookPathSegments(r)[0])
+	if err != nil {
+		s.writeProblem(w, r, http.StatusNotFound, codeNotFound, "no such book exists")
+		return 0, false
+	}
+
+	return bookID, true
 }
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/auth"
+	"github.com/Parsa-Sh-Y/book-manager-service/db"
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"github.com/Parsa-Sh-Y/book-manager-service/metadata"
+	"github.com/sirupsen/logrus"
+)
+
+// testPassword is the password of the users of the test servers
+const testPassword = "Passw0rd!"
+
+// testServer is a Server on a MemoryDB behind an httptest.Server, with the users
+// ali and reza as members, sara as a librarian and root as an admin
+type testServer struct {
+	*Server
+	url    string
+	store  *db.MemoryDB
+	tokens map[string]string
+}
+
+func newTestServer(t *testing.T) *testServer {
+
+	t.Helper()
+	store := db.CreateNewMemoryDB()
+	users := []models.User{
+		{Username: "ali", Role: models.RoleMember},
+		{Username: "reza", Role: models.RoleMember},
+		{Username: "sara", Role: models.RoleLibrarian},
+		{Username: "root", Role: models.RoleAdmin},
+	}
+	for _, user := range users {
+		user.Email, user.Password = user.Username+"@example.com", testPassword
+		if err := store.CreateUser(&user); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	keys, err := auth.GenerateKeySet()
+	if err != nil {
+		t.Fatal(err)
+	}
+	authenticate, err := auth.NewAuth(store, keys, auth.Options{AccessTokenDuration: time.Hour, SessionDuration: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := logrus.New()
+	logger.SetOutput(io.Discard)
+	ts := &testServer{Server: NewServer(store, authenticate, &metadata.Fixture{}, logger), store: store, tokens: map[string]string{}}
+
+	for _, user := range users {
+		tokens, err := authenticate.Login(&auth.UserCredentials{Username: user.Username, Password: testPassword})
+		if err != nil {
+			t.Fatal(err)
+		}
+		ts.tokens[user.Username] = tokens.AccessToken
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v1/auth/login", ts.HandleLogin)
+	mux.HandleFunc("/api/v1/books", ts.Authenticate(ts.HandleBooksRoot))
+	mux.HandleFunc("/api/v1/books/", ts.Authenticate(ts.HandleBooksSubtree))
+	mux.HandleFunc("/api/v1/books/import", ts.Authenticate(ts.HandleImportBooks))
+	mux.HandleFunc("/api/v1/series/", ts.Authenticate(ts.HandleSeriesSubtree))
+	server := httptest.NewServer(ts.RequestID(mux))
+	t.Cleanup(server.Close)
+	ts.url = server.URL
+
+	return ts
+}
+
+// do sends the request as the user, the headers are name, value pairs
+func (ts *testServer) do(t *testing.T, user string, method string, path string, body string, headers ...string) *http.Response {
+
+	t.Helper()
+	req, err := http.NewRequest(method, ts.url+path, strings.NewReader(body))
+	if err != nil {
+		t.Fatal(err)
+	}
+	req.Header.Set("Authorization", "Bearer "+ts.tokens[user])
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+
+	return resp
+}
+
+// createBook creates the book of the user in the store
+func (ts *testServer) createBook(t *testing.T, user string, book models.Book) models.Book {
+
+	t.Helper()
+	owner, err := ts.store.GetUserByUsername(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	book.UserID = owner.ID
+	if err := ts.store.CreateBook(&book); err != nil {
+		t.Fatal(err)
+	}
+
+	return book
+}
+
+// createDune creates Dune as a book of the user, with an author, a publisher, a tag and two entries of contents
+func (ts *testServer) createDune(t *testing.T, user string) models.Book {
+
+	t.Helper()
+	return ts.createBook(t, user, models.Book{
+		Name:            "Dune",
+		ISBN13:          "9780441172719",
+		Summary:         "A desert planet.",
+		PublishedAt:     time.Date(1965, time.August, 1, 0, 0, 0, 0, time.UTC),
+		Publisher:       &models.Publisher{Name: "Ace Books"},
+		Authors:         []models.BookAuthor{{Role: models.AuthorRoleAuthor, Author: models.Author{FirstName: "Frank", LastName: "Herbert"}}},
+		Tags:            []models.Tag{{Name: "classic"}},
+		TableOfContents: models.ContentsFromNames([]string{"Book One", "Book Two"}),
+	})
+}
+
+// decode reads the JSON body of the response into v
+func decode(t *testing.T, resp *http.Response, v interface{}) {
+
+	t.Helper()
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// problemCode returns the code of the problem in the body of the response
+func problemCode(t *testing.T, resp *http.Response) string {
+
+	t.Helper()
+	var p problem
+	decode(t, resp, &p)
+	return p.Code
+}
@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"os"
+	"strconv"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/auth"
+	"github.com/Parsa-Sh-Y/book-manager-service/db"
+	"github.com/Parsa-Sh-Y/book-manager-service/importer"
+)
+
+// The media types of the files imported by POST /api/v1/books/import
+const (
+	csvMediaType        = "text/csv"
+	jsonLinesMediaType  = "application/x-ndjson"
+	jsonLinesMediaType2 = "application/jsonl"
+	sqliteMediaType     = "application/vnd.sqlite3"
+	sqliteMediaType2    = "application/x-sqlite3"
+)
+
+// sourceGoodreads is the source of the CSV files exported from Goodreads
+const sourceGoodreads = "goodreads"
+
+// defaultImportMaxBytes is the size of the largest file imported when it is not configured
+const defaultImportMaxBytes = 32 << 20
+
+// errFileTooLarge The imported file is larger than the limit of the server
+var errFileTooLarge = errors.New("the file is too large")
+
+// HandleImportBooks creates the books of a CSV or JSON Lines file, a Goodreads export or
+// the metadata.db of a Calibre library for the current user, and answers with the report
+// of every row. The columns of a CSV file are mapped to the fields of the books with
+// ?map=field:column, repeated for each field, or read as a Goodreads export with
+// ?source=goodreads. With ?preview=true nothing is created, the report tells what would be.
+func (s *Server) HandleImportBooks(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		s.methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	account := currentUser(r)
+	if !auth.Can(account.Role, auth.PermissionCreateBook) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	values := r.URL.Query()
+	preview := false
+	if v := values.Get("preview"); v != "" {
+		var err error
+		if preview, err = strconv.ParseBool(v); err != nil {
+			s.writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "preview must be true or false")
+			return
+		}
+	}
+	source := values.Get("source")
+	if source != "" && source != sourceGoodreads {
+		s.writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, "source must be "+sourceGoodreads)
+		return
+	}
+
+	r.Body = newImportBody(w, r.Body, s.importMaxBytes)
+
+	var reader importer.Reader
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	switch mediaType {
+	case csvMediaType:
+		if source == sourceGoodreads {
+			goodreadsReader, err := importer.NewGoodreadsReader(r.Body)
+			if errors.Is(err, errFileTooLarge) {
+				s.writeFileTooLarge(w, r, nil)
+				return
+			} else if err != nil {
+				s.writeProblem(w, r, http.StatusBadRequest, codeInvalidRequestBody, err.Error())
+				return
+			}
+			reader = goodreadsReader
+			break
+		}
+		mapping, err := importer.ParseMapping(r.URL.Query()["map"])
+		if err != nil {
+			s.writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+			return
+		}
+		csvReader, err := importer.NewCSVReader(r.Body, mapping)
+		if errors.Is(err, errFileTooLarge) {
+			s.writeFileTooLarge(w, r, nil)
+			return
+		} else if err != nil {
+			s.writeProblem(w, r, http.StatusBadRequest, codeInvalidRequestBody, err.Error())
+			return
+		}
+		reader = csvReader
+	case jsonLinesMediaType, jsonLinesMediaType2:
+		reader = importer.NewJSONLinesReader(r.Body)
+	case sqliteMediaType, sqliteMediaType2:
+		calibreReader, err := loadCalibre(r.Body)
+		if errors.Is(err, errFileTooLarge) {
+			s.writeFileTooLarge(w, r, nil)
+			return
+		} else if errors.Is(err, importer.ErrInvalidLibrary) {
+			s.writeProblem(w, r, http.StatusBadRequest, codeInvalidRequestBody, err.Error())
+			return
+		} else if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+		reader = calibreReader
+	default:
+		s.writeProblem(w, r, http.StatusUnsupportedMediaType, codeUnsupportedMediaType,
+			"the file must be "+csvMediaType+", "+jsonLinesMediaType+" or "+sqliteMediaType)
+		return
+	}
+
+	// the report tells which rows were imported even when the import stops early
+	var report *importer.Report
+	var err error
+	if preview {
+		report, err = importer.Preview(s.db, reader, account.ID)
+	} else {
+		report, err = importer.Import(s.db, reader, account.ID, importer.DefaultBatchSize)
+	}
+	if errors.Is(err, errFileTooLarge) {
+		s.writeFileTooLarge(w, r, report)
+		return
+	} else if err != nil {
+		s.log(r).WithError(err).Warn("the import stopped before the end of the file")
+	}
+
+	s.writeJSON(w, r, http.StatusOK, report)
+}
+
+// writeFileTooLarge answers a file larger than the limit with 413 Request Entity Too Large.
+// The detail tells how many books of the rows read before the limit were created.
+func (s *Server) writeFileTooLarge(w http.ResponseWriter, r *http.Request, report *importer.Report) {
+
+	detail := fmt.Sprintf("the file is larger than %d bytes", s.importMaxBytes)
+	if report != nil && !report.Preview {
+		detail += fmt.Sprintf(", %d books of the rows read before were created", report.Created)
+	}
+
+	s.writeProblem(w, r, http.StatusRequestEntityTooLarge, codeFileTooLarge, detail)
+}
+
+// importBody is the body of an import limited by http.MaxBytesReader,
+// whose error is errFileTooLarge when the limit is reached
+type importBody struct {
+	io.ReadCloser
+	// left is the number of bytes that can still be read
+	left int64
+}
+
+func newImportBody(w http.ResponseWriter, body io.ReadCloser, maxBytes int64) *importBody {
+	return &importBody{ReadCloser: http.MaxBytesReader(w, body, maxBytes), left: maxBytes}
+}
+
+func (b *importBody) Read(p []byte) (int, error) {
+
+	n, err := b.ReadCloser.Read(p)
+	b.left -= int64(n)
+	if err != nil && !errors.Is(err, io.EOF) && b.left <= 0 {
+		err = errFileTooLarge
+	}
+
+	return n, err
+}
+
+// loadCalibre reads the books of the metadata.db of a Calibre library sent in body.
+// sqlite only opens files, so the library is copied to a temporary file first.
+func loadCalibre(body io.Reader) (*importer.CalibreReader, error) {
+
+	file, err := os.CreateTemp("", "calibre-*.db")
+	if err != nil {
+		return nil, err
+	}
+	defer os.Remove(file.Name())
+
+	_, err = io.Copy(file, body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	return importer.LoadCalibre(file.Name())
+}
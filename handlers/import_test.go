@@ -0,0 +1,77 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/importer"
+)
+
+func TestImportBooksTooLarge(t *testing.T) {
+
+	ts := newTestServer(t)
+	ts.importMaxBytes = 1000
+
+	var file strings.Builder
+	file.WriteString("name\n")
+	for i := 0; file.Len() < 3000; i++ {
+		fmt.Fprintf(&file, "Book %d\n", i)
+	}
+
+	tests := []struct {
+		name        string
+		query       string
+		contentType string
+		body        string
+		status      int
+	}{
+		{"csv", "", csvMediaType, file.String(), http.StatusRequestEntityTooLarge},
+		{"csv preview", "?preview=true", csvMediaType, file.String(), http.StatusRequestEntityTooLarge},
+		{"header", "", csvMediaType, strings.Repeat("name", 500), http.StatusRequestEntityTooLarge},
+		{"goodreads", "?source=goodreads", csvMediaType, strings.Repeat("Title", 500), http.StatusRequestEntityTooLarge},
+		{"json lines", "", jsonLinesMediaType, strings.Repeat(`{"name": "Dune"}`+"\n", 100), http.StatusRequestEntityTooLarge},
+		{"calibre", "", sqliteMediaType, strings.Repeat("x", 2000), http.StatusRequestEntityTooLarge},
+		{"at the limit", "", csvMediaType, file.String()[:1000], http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := ts.do(t, "ali", http.MethodPost, "/api/v1/books/import"+tt.query, tt.body, "Content-Type", tt.contentType)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status == http.StatusRequestEntityTooLarge {
+				if code := problemCode(t, resp); code != codeFileTooLarge {
+					t.Errorf("code = %q, want %q", code, codeFileTooLarge)
+				}
+			}
+		})
+	}
+}
+
+func TestImportBooks(t *testing.T) {
+
+	ts := newTestServer(t)
+
+	resp := ts.do(t, "ali", http.MethodPost, "/api/v1/books/import", "Title\nDune\n\nEmma\n", "Content-Type", csvMediaType+"; charset=utf-8")
+	if resp.StatusCode != http.StatusBadRequest {
+		t.Errorf("import without a name column = %d, want %d", resp.StatusCode, http.StatusBadRequest)
+	}
+
+	resp = ts.do(t, "ali", http.MethodPost, "/api/v1/books/import?map=name:Title", "Title\nDune\nEmma\n", "Content-Type", csvMediaType)
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusOK)
+	}
+	var report importer.Report
+	decode(t, resp, &report)
+	if report.Created != 2 {
+		t.Errorf("%d books created, want 2", report.Created)
+	}
+
+	resp = ts.do(t, "ali", http.MethodPost, "/api/v1/books/import", "name\nDune\n", "Content-Type", "application/pdf")
+	if resp.StatusCode != http.StatusUnsupportedMediaType {
+		t.Errorf("import of a pdf = %d, want %d", resp.StatusCode, http.StatusUnsupportedMediaType)
+	}
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+	"testing"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/auth"
+)
+
+func TestLogin(t *testing.T) {
+
+	ts := newTestServer(t)
+
+	tests := []struct {
+		name     string
+		body     string
+		status   int
+		code     string
+		detail   string
+		loggedIn bool
+	}{
+		{"right password", `{"username": "ali", "password": "Passw0rd!"}`, http.StatusOK, "", "", true},
+		// the same answer, so the usernames in use can not be told apart
+		{"wrong password", `{"username": "ali", "password": "Passw0rd?"}`, http.StatusUnauthorized, codeInvalidCredentials, "the username or the password is incorrect", false},
+		{"unknown username", `{"username": "nobody", "password": "Passw0rd!"}`, http.StatusUnauthorized, codeInvalidCredentials, "the username or the password is incorrect", false},
+		{"not json", `{"username": `, http.StatusBadRequest, codeInvalidRequestBody, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := ts.do(t, "", http.MethodPost, "/api/v1/auth/login", tt.body, "Content-Type", "application/json")
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+
+			if tt.loggedIn {
+				var tokens auth.Tokens
+				decode(t, resp, &tokens)
+				if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+					t.Errorf("tokens = %+v", tokens)
+				}
+				return
+			}
+
+			var p problem
+			decode(t, resp, &p)
+			if p.Code != tt.code || tt.detail != "" && p.Detail != tt.detail {
+				t.Errorf("problem = %q, %q, want %q, %q", p.Code, p.Detail, tt.code, tt.detail)
+			}
+		})
+	}
+}
@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/auth"
+	"github.com/Parsa-Sh-Y/book-manager-service/db"
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"github.com/Parsa-Sh-Y/book-manager-service/isbn"
+	"github.com/Parsa-Sh-Y/book-manager-service/metadata"
+	"github.com/Parsa-Sh-Y/book-manager-service/validation"
+)
+
+// lookupRequest is the body of POST /api/v1/books/lookup
+type lookupRequest struct {
+	ISBN string `json:"isbn" validate:"required,isbn"`
+}
+
+// HandleLookupBook looks up the ISBN in the catalog and returns the book filled with
+// its metadata. The book is not saved, the client confirms it and creates it with
+// POST /api/v1/books, so only the users who can add books can look them up.
+func (s *Server) HandleLookupBook(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		s.methodNotAllowed(w, r, http.MethodPost)
+		return
+	}
+
+	if !auth.Can(currentUser(r).Role, auth.PermissionCreateBook) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	var lookup lookupRequest
+	if !s.readJSON(w, r, &lookup) {
+		return
+	}
+	if err := validation.Validate(&lookup); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	book, err := s.catalog.LookupISBN(r.Context(), isbn.Normalize(lookup.ISBN))
+	if err != nil {
+		if errors.Is(err, metadata.ErrUnavailable) {
+			s.log(r).WithError(err).Warn("could not look up the book in the catalog")
+		}
+		s.writeError(w, r, err)
+		return
+	}
+
+	book.TableOfContentsJson = models.ContentNames(book.TableOfContents)
+	s.writeJSON(w, r, http.StatusOK, book)
+}
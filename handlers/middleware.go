@@ -0,0 +1,153 @@
+package handlers
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/auth"
+	"github.com/Parsa-Sh-Y/book-manager-service/db"
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"github.com/sirupsen/logrus"
+)
+
+type contextKey int
+
+const (
+	userContextKey contextKey = iota
+	identityContextKey
+	requestIDContextKey
+)
+
+// maxRequestIDLength is the longest X-Request-ID taken from a client
+const maxRequestIDLength = 128
+
+// RequestID gives every request an id. The id of the X-Request-ID header is kept
+// when the client sends one, otherwise a random id is generated. The id is sent back
+// in the X-Request-ID header, in the error responses and in the log entries.
+func (s *Server) RequestID(next http.Handler) http.Handler {
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		id := r.Header.Get("X-Request-ID")
+		if !isValidRequestID(id) {
+			random := make([]byte, 16)
+			if _, err := rand.Read(random); err != nil {
+				s.logger.WithError(err).Error("can not generate a request id")
+			}
+			id = hex.EncodeToString(random)
+		}
+
+		w.Header().Set("X-Request-ID", id)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), requestIDContextKey, id)))
+	})
+}
+
+func isValidRequestID(id string) bool {
+
+	if id == "" || len(id) > maxRequestIDLength {
+		return false
+	}
+	for _, c := range id {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
+
+// requestID returns the id stored by RequestID
+func requestID(r *http.Request) string {
+
+	id, _ := r.Context().Value(requestIDContextKey).(string)
+	return id
+}
+
+// log returns the logger of the request, its entries carry the request id
+func (s *Server) log(r *http.Request) *logrus.Entry {
+	return s.logger.WithField("request_id", requestID(r))
+}
+
+// Authenticate makes next require a logged in user.
+// It validates the Bearer token of the Authorization header, loads the user and
+// stores both in the request context, where currentUser and currentIdentity find them.
+// Requests without a valid token get 401 Unauthorized.
+func (s *Server) Authenticate(next http.HandlerFunc) http.HandlerFunc {
+
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		token, ok := bearerToken(r.Header.Get("Authorization"))
+		if !ok {
+			w.Header().Set("WWW-Authenticate", "Bearer")
+			s.writeProblem(w, r, http.StatusUnauthorized, codeMissingToken, "the request has no bearer token")
+			return
+		}
+
+		identity, err := s.auth.Authenticate(token)
+		if err != nil {
+			s.log(r).WithError(err).Warn("could not log in the user")
+			s.writeError(w, r, err)
+			return
+		}
+
+		// the user of a valid token may have been deleted since
+		user, err := s.db.GetUserByUsername(identity.Username)
+		if err == db.ErrUserNotFound {
+			s.writeError(w, r, auth.ErrInvalidToken)
+			return
+		} else if err != nil {
+			s.writeError(w, r, err)
+			return
+		}
+
+		ctx := context.WithValue(r.Context(), userContextKey, user)
+		ctx = context.WithValue(ctx, identityContextKey, identity)
+		next(w, r.WithContext(ctx))
+	}
+}
+
+// Require makes next require a logged in user with the permission.
+// Users without it get 403 Forbidden.
+func (s *Server) Require(permission auth.Permission, next http.HandlerFunc) http.HandlerFunc {
+
+	return s.Authenticate(func(w http.ResponseWriter, r *http.Request) {
+
+		if !auth.Can(currentUser(r).Role, permission) {
+			s.writeError(w, r, db.ErrPermissionDenied)
+			return
+		}
+
+		next(w, r)
+	})
+}
+
+// currentUser returns the user stored by Authenticate.
+// The role of the user is read from the database on every request,
+// so it is the role checked against the permissions.
+func currentUser(r *http.Request) *models.User {
+
+	user, _ := r.Context().Value(userContextKey).(*models.User)
+	return user
+}
+
+// currentIdentity returns the identity of the access token stored by Authenticate
+func currentIdentity(r *http.Request) *auth.Identity {
+
+	identity, _ := r.Context().Value(identityContextKey).(*auth.Identity)
+	return identity
+}
+
+// bearerToken returns the token of a "Bearer <token>" Authorization header
+func bearerToken(header string) (string, bool) {
+
+	scheme, token, ok := strings.Cut(header, " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return "", false
+	}
+
+	token = strings.TrimSpace(token)
+	return token, token != ""
+}
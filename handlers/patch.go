@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"encoding/json"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+)
+
+// mergePatchMediaType is the media type of JSON Merge Patch documents
+const mergePatchMediaType = "application/merge-patch+json"
+
+// patchBook returns the book made by applying the merge patch to the JSON document of book
+func patchBook(book *models.Book, patch interface{}) (*models.Book, error) {
+
+	book.TableOfContentsJson = models.ContentNames(book.TableOfContents)
+
+	document, err := json.Marshal(book)
+	if err != nil {
+		return nil, err
+	}
+	var target map[string]interface{}
+	if err := json.Unmarshal(document, &target); err != nil {
+		return nil, err
+	}
+
+	// the table of contents is patched either as a tree or as a list of names,
+	// the other one is left out so it does not override the patched one
+	if patchObject, ok := patch.(map[string]interface{}); ok {
+		_, tree := patchObject["contents"]
+		_, names := patchObject["table_of_contents"]
+		if names && !tree {
+			delete(target, "contents")
+		} else {
+			delete(target, "table_of_contents")
+		}
+
+		// a patched publisher, category or series replaces the current one
+		// instead of being merged into it, which would keep the id of the current one
+		for _, field := range []string{"publisher", "category", "series"} {
+			if _, ok := patchObject[field]; ok {
+				delete(target, field)
+			}
+		}
+
+		// the single author books used to have replaces their authors
+		if _, ok := patchObject["author"]; ok {
+			delete(target, "authors")
+		}
+
+		// the two ISBNs are forms of one number, patching one of them
+		// derives the other one again instead of keeping the current one
+		_, isbn10 := patchObject["isbn_10"]
+		_, isbn13 := patchObject["isbn_13"]
+		if isbn10 || isbn13 {
+			delete(target, "isbn_10")
+			delete(target, "isbn_13")
+		}
+	}
+
+	document, err = json.Marshal(mergePatch(target, patch))
+	if err != nil {
+		return nil, err
+	}
+	var patched models.Book
+	if err := json.Unmarshal(document, &patched); err != nil {
+		return nil, err
+	}
+
+	return &patched, nil
+}
+
+// mergePatch applies the patch to target as described by RFC 7386:
+// the members of a patch object replace those of the target, null members
+// remove them, and any other patch replaces the whole target.
+func mergePatch(target interface{}, patch interface{}) interface{} {
+
+	patchObject, ok := patch.(map[string]interface{})
+	if !ok {
+		return patch
+	}
+
+	targetObject, ok := target.(map[string]interface{})
+	if !ok {
+		targetObject = make(map[string]interface{})
+	}
+
+	for name, value := range patchObject {
+		if value == nil {
+			delete(targetObject, name)
+		} else {
+			targetObject[name] = mergePatch(targetObject[name], value)
+		}
+	}
+
+	return targetObject
+}
@@ -0,0 +1,189 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+)
+
+func TestMergePatch(t *testing.T) {
+
+	// the examples of appendix A of RFC 7386
+	tests := []struct {
+		target string
+		patch  string
+		want   string
+	}{
+		{`{"a":"b"}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"b"}`, `{"b":"c"}`, `{"a":"b","b":"c"}`},
+		{`{"a":"b"}`, `{"a":null}`, `{}`},
+		{`{"a":"b","b":"c"}`, `{"a":null}`, `{"b":"c"}`},
+		{`{"a":["b"]}`, `{"a":"c"}`, `{"a":"c"}`},
+		{`{"a":"c"}`, `{"a":["b"]}`, `{"a":["b"]}`},
+		{`{"a":{"b":"c"}}`, `{"a":{"b":"d","c":null}}`, `{"a":{"b":"d"}}`},
+		{`{"a":[{"b":"c"}]}`, `{"a":[1]}`, `{"a":[1]}`},
+		{`["a","b"]`, `["c","d"]`, `["c","d"]`},
+		{`{"a":"b"}`, `["c"]`, `["c"]`},
+		{`{"a":"foo"}`, `null`, `null`},
+		{`{"a":"foo"}`, `"bar"`, `"bar"`},
+		{`{"e":null}`, `{"a":1}`, `{"e":null,"a":1}`},
+		{`[1,2]`, `{"a":"b","c":null}`, `{"a":"b"}`},
+		{`{}`, `{"a":{"bb":{"ccc":null}}}`, `{"a":{"bb":{}}}`},
+	}
+
+	for _, tt := range tests {
+		var target, patch, want interface{}
+		for _, v := range []struct {
+			doc string
+			ptr *interface{}
+		}{{tt.target, &target}, {tt.patch, &patch}, {tt.want, &want}} {
+			if err := json.Unmarshal([]byte(v.doc), v.ptr); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		if got := mergePatch(target, patch); !reflect.DeepEqual(got, want) {
+			t.Errorf("mergePatch(%s, %s) = %v, want %s", tt.target, tt.patch, got, tt.want)
+		}
+	}
+}
+
+// patchedBook creates Dune as a book of ali on the server, patches it and returns the response with the book after it
+func patchedBook(t *testing.T, ts *testServer, patch string, headers ...string) (*http.Response, *models.Book) {
+
+	t.Helper()
+	book := ts.createDune(t, "ali")
+
+	if len(headers) == 0 {
+		headers = []string{"Content-Type", mergePatchMediaType, "If-Match", bookETag(&book)}
+	}
+	resp := ts.do(t, "ali", http.MethodPatch, fmt.Sprintf("/api/v1/books/%d", book.ID), patch, headers...)
+
+	patched, err := ts.store.GetBook(int(book.ID))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return resp, patched
+}
+
+func TestPatchBook(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		patch string
+		check func(t *testing.T, book *models.Book)
+	}{
+		{"name", `{"name": "Dune Messiah"}`, func(t *testing.T, book *models.Book) {
+			if book.Name != "Dune Messiah" || book.ISBN13 != "9780441172719" || book.Summary != "A desert planet." ||
+				len(book.Authors) != 1 || len(book.Tags) != 1 || len(book.TableOfContents) != 2 || book.Publisher.Name != "Ace Books" {
+				t.Errorf("the other fields changed: %+v", book)
+			}
+		}},
+		{"isbn_13 derives isbn_10", `{"isbn_13": "978-0-262-51087-5"}`, func(t *testing.T, book *models.Book) {
+			if book.ISBN13 != "9780262510875" || book.ISBN10 != "0262510871" {
+				t.Errorf("ISBNs = %q, %q", book.ISBN10, book.ISBN13)
+			}
+		}},
+		{"isbn_10 derives isbn_13", `{"isbn_10": "080442957X"}`, func(t *testing.T, book *models.Book) {
+			if book.ISBN10 != "080442957X" || book.ISBN13 != "9780804429573" {
+				t.Errorf("ISBNs = %q, %q", book.ISBN10, book.ISBN13)
+			}
+		}},
+		{"isbn_13 without an isbn_10", `{"isbn_13": "9791032300824"}`, func(t *testing.T, book *models.Book) {
+			if book.ISBN10 != "" || book.ISBN13 != "9791032300824" {
+				t.Errorf("ISBNs = %q, %q", book.ISBN10, book.ISBN13)
+			}
+		}},
+		{"null isbns", `{"isbn_10": null, "isbn_13": null}`, func(t *testing.T, book *models.Book) {
+			if book.ISBN10 != "" || book.ISBN13 != "" {
+				t.Errorf("ISBNs = %q, %q", book.ISBN10, book.ISBN13)
+			}
+		}},
+		{"null summary", `{"summary": null}`, func(t *testing.T, book *models.Book) {
+			if book.Summary != "" {
+				t.Errorf("summary = %q", book.Summary)
+			}
+		}},
+		{"publisher replaced", `{"publisher": {"name": "Chilton Books"}}`, func(t *testing.T, book *models.Book) {
+			if book.Publisher == nil || book.Publisher.Name != "Chilton Books" {
+				t.Errorf("publisher = %+v", book.Publisher)
+			}
+		}},
+		{"table of contents as names", `{"table_of_contents": ["Prologue", "Book One", "Book Two"]}`, func(t *testing.T, book *models.Book) {
+			if names := models.ContentNames(book.TableOfContents); !reflect.DeepEqual(names, []string{"Prologue", "Book One", "Book Two"}) {
+				t.Errorf("contents = %v", names)
+			}
+		}},
+		{"table of contents as a tree", `{"contents": [{"name": "Book One", "children": [{"name": "Chapter 1"}]}]}`, func(t *testing.T, book *models.Book) {
+			if len(book.TableOfContents) != 1 || len(book.TableOfContents[0].Children) != 1 {
+				t.Errorf("contents = %+v", book.TableOfContents)
+			}
+		}},
+		{"authors replaced", `{"authors": [{"role": "editor", "author": {"first_name": "Brian", "last_name": "Herbert"}}]}`, func(t *testing.T, book *models.Book) {
+			if len(book.Authors) != 1 || book.Authors[0].Role != models.AuthorRoleEditor || book.Authors[0].Author.FirstName != "Brian" {
+				t.Errorf("authors = %+v", book.Authors)
+			}
+		}},
+		{"authors replaced by the old author", `{"author": "Brian Herbert"}`, func(t *testing.T, book *models.Book) {
+			if len(book.Authors) != 1 || book.Authors[0].Role != models.AuthorRoleAuthor || book.Authors[0].Author.FullName() != "Brian Herbert" {
+				t.Errorf("authors = %+v", book.Authors)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, book := patchedBook(t, newTestServer(t), tt.patch)
+			if resp.StatusCode != http.StatusOK {
+				t.Fatalf("status = %d: %s", resp.StatusCode, problemCode(t, resp))
+			}
+			if resp.Header.Get("ETag") != `"2"` || book.Version != 2 {
+				t.Errorf("ETag = %s, version %d, want the version 2", resp.Header.Get("ETag"), book.Version)
+			}
+			tt.check(t, book)
+		})
+	}
+}
+
+func TestPatchBookErrors(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		patch   string
+		headers []string
+		status  int
+		code    string
+	}{
+		{"invalid name", `{"name": null}`, nil, http.StatusUnprocessableEntity, codeValidationFailed},
+		{"invalid isbn", `{"isbn_13": "9780441172710"}`, nil, http.StatusUnprocessableEntity, codeValidationFailed},
+		{"mismatched isbns", `{"isbn_10": "0441172717", "isbn_13": "9780262510875"}`, nil, http.StatusUnprocessableEntity, "isbn_mismatch"},
+		{"author and authors", `{"author": "Brian Herbert", "authors": [{"author": {"last_name": "Herbert"}}]}`, nil, http.StatusBadRequest, codeInvalidRequestBody},
+		{"not a book", `"Dune"`, nil, http.StatusBadRequest, codeInvalidRequestBody},
+		{"not json", `{"name": `, nil, http.StatusBadRequest, codeInvalidRequestBody},
+		{"media type", `{"name": "Dune"}`, []string{"Content-Type", "text/plain", "If-Match", `"1"`}, http.StatusUnsupportedMediaType, codeUnsupportedMediaType},
+		{"no If-Match", `{"name": "Dune"}`, []string{"Content-Type", "application/json"}, http.StatusPreconditionRequired, codePreconditionRequired},
+		{"stale If-Match", `{"name": "Dune"}`, []string{"Content-Type", "application/json", "If-Match", `"7"`}, http.StatusPreconditionFailed, codePreconditionFailed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp, book := patchedBook(t, newTestServer(t), tt.patch, tt.headers...)
+			if resp.StatusCode != tt.status {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, tt.status)
+			}
+			if tt.status == http.StatusUnsupportedMediaType && resp.Header.Get("Accept-Patch") != mergePatchMediaType {
+				t.Errorf("Accept-Patch = %q", resp.Header.Get("Accept-Patch"))
+			}
+			if code := problemCode(t, resp); code != tt.code {
+				t.Errorf("code = %q, want %q", code, tt.code)
+			}
+			if book.Version != 1 || book.Name != "Dune" {
+				t.Errorf("the book changed: version %d, %q", book.Version, book.Name)
+			}
+		})
+	}
+}
@@ -0,0 +1,163 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+)
+
+func TestPatchBookOfOtherUser(t *testing.T) {
+
+	catalog := []string{
+		`{"publisher": {"name": "Chilton Books"}}`,
+		`{"isbn_13": "9780262510875"}`,
+		`{"authors": [{"role": "editor", "author": {"first_name": "Brian", "last_name": "Herbert"}}]}`,
+		`{"series": {"name": "Dune"}, "volumn": 1}`,
+		`{"published_at": "1990-09-01T00:00:00Z"}`,
+		// the fields of the owner set to what they are
+		`{"name": "Dune", "tags": ["Classic"], "table_of_contents": ["Book One", "Book Two"]}`,
+	}
+	owner := []string{
+		`{"name": "Dune Messiah"}`,
+		`{"summary": "A sand planet."}`,
+		`{"rating": 5}`,
+		`{"tags": ["classic", "sci-fi"]}`,
+		`{"table_of_contents": ["Book One"]}`,
+		`{"publisher": {"name": "Chilton Books"}, "summary": null}`,
+	}
+
+	tests := []struct {
+		user    string
+		patches []string
+		status  int
+	}{
+		{"reza", catalog, http.StatusForbidden},
+		{"reza", owner, http.StatusForbidden},
+		{"sara", catalog, http.StatusOK},
+		{"sara", owner, http.StatusForbidden},
+		{"root", catalog, http.StatusOK},
+		{"root", owner, http.StatusOK},
+		{"ali", catalog, http.StatusOK},
+		{"ali", owner, http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		for _, patch := range tt.patches {
+			ts := newTestServer(t)
+			book := ts.createDune(t, "ali")
+			resp := ts.do(t, tt.user, http.MethodPatch, fmt.Sprintf("/api/v1/books/%d", book.ID), patch,
+				"Content-Type", mergePatchMediaType, "If-Match", bookETag(&book))
+			if resp.StatusCode != tt.status {
+				t.Errorf("PATCH %s by %s = %d, want %d", patch, tt.user, resp.StatusCode, tt.status)
+				continue
+			}
+
+			stored, err := ts.store.GetBook(int(book.ID))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if tt.status == http.StatusForbidden {
+				if code := problemCode(t, resp); code != "permission_denied" || stored.Version != 1 {
+					t.Errorf("PATCH %s by %s: code %q, version %d", patch, tt.user, code, stored.Version)
+				}
+			} else if stored.Version != 2 || stored.UserID != book.UserID {
+				t.Errorf("PATCH %s by %s: version %d, owner %d", patch, tt.user, stored.Version, stored.UserID)
+			}
+		}
+	}
+}
+
+func TestPatchBookOfOtherUserInvalid(t *testing.T) {
+
+	// the permission is checked before the fields
+	ts := newTestServer(t)
+	book := ts.createDune(t, "ali")
+	resp := ts.do(t, "reza", http.MethodPatch, fmt.Sprintf("/api/v1/books/%d", book.ID), `{"name": null}`,
+		"Content-Type", mergePatchMediaType, "If-Match", bookETag(&book))
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("invalid PATCH by reza = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+}
+
+func TestDeleteBookOfOtherUser(t *testing.T) {
+
+	tests := []struct {
+		user   string
+		status int
+	}{
+		{"reza", http.StatusForbidden},
+		{"sara", http.StatusForbidden},
+		{"root", http.StatusOK},
+		{"ali", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		ts := newTestServer(t)
+		book := ts.createDune(t, "ali")
+		resp := ts.do(t, tt.user, http.MethodDelete, fmt.Sprintf("/api/v1/books/%d", book.ID), "", "If-Match", bookETag(&book))
+		if resp.StatusCode != tt.status {
+			t.Errorf("DELETE by %s = %d, want %d", tt.user, resp.StatusCode, tt.status)
+		}
+
+		_, err := ts.store.GetBook(int(book.ID))
+		if deleted, want := err != nil, tt.status == http.StatusOK; deleted != want {
+			t.Errorf("DELETE by %s: book deleted %v, want %v", tt.user, deleted, want)
+		}
+	}
+}
+
+func TestRoleChangeAppliesAtOnce(t *testing.T) {
+
+	// the token of sara was issued for the librarian role
+	ts := newTestServer(t)
+	book := ts.createDune(t, "ali")
+	if err := ts.store.UpdateUserRole("sara", models.RoleMember); err != nil {
+		t.Fatal(err)
+	}
+
+	resp := ts.do(t, "sara", http.MethodPatch, fmt.Sprintf("/api/v1/books/%d", book.ID), `{"publisher": {"name": "Chilton Books"}}`,
+		"Content-Type", mergePatchMediaType, "If-Match", bookETag(&book))
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("PATCH by a demoted librarian = %d, want %d", resp.StatusCode, http.StatusForbidden)
+	}
+}
+
+func TestOtherUserWithoutVersion(t *testing.T) {
+
+	// the permission is checked before the If-Match header, so the version is not shown
+	tests := []struct {
+		name    string
+		user    string
+		method  string
+		body    string
+		headers []string
+	}{
+		{"PUT without If-Match", "reza", http.MethodPut, `{"name": "Dune"}`, []string{"Content-Type", "application/json"}},
+		{"PUT with a stale If-Match", "reza", http.MethodPut, `{"name": "Dune"}`, []string{"Content-Type", "application/json", "If-Match", `"7"`}},
+		{"PATCH without If-Match", "reza", http.MethodPatch, `{"name": "Dune"}`, []string{"Content-Type", mergePatchMediaType}},
+		{"PATCH with a stale If-Match", "reza", http.MethodPatch, `{"name": "Dune"}`, []string{"Content-Type", mergePatchMediaType, "If-Match", `"7"`}},
+		{"PATCH of the summary by a librarian", "sara", http.MethodPatch, `{"summary": null}`, []string{"Content-Type", mergePatchMediaType, "If-Match", `"7"`}},
+		{"DELETE without If-Match", "reza", http.MethodDelete, "", nil},
+		{"DELETE with a stale If-Match", "reza", http.MethodDelete, "", []string{"If-Match", `"7"`}},
+		{"DELETE by a librarian", "sara", http.MethodDelete, "", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := newTestServer(t)
+			book := ts.createDune(t, "ali")
+			resp := ts.do(t, tt.user, tt.method, fmt.Sprintf("/api/v1/books/%d", book.ID), tt.body, tt.headers...)
+			if resp.StatusCode != http.StatusForbidden {
+				t.Fatalf("status = %d, want %d", resp.StatusCode, http.StatusForbidden)
+			}
+			if etag := resp.Header.Get("ETag"); etag != "" {
+				t.Errorf("ETag = %s, want none", etag)
+			}
+			if code := problemCode(t, resp); code != "permission_denied" {
+				t.Errorf("code = %q, want permission_denied", code)
+			}
+		})
+	}
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/auth"
+	"github.com/Parsa-Sh-Y/book-manager-service/db"
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"github.com/Parsa-Sh-Y/book-manager-service/validation"
+)
+
+type publisherCollection struct {
+	Publishers []models.Publisher `json:"publishers"`
+	Total      int64              `json:"total"`
+	Limit      int                `json:"limit"`
+	Offset     int                `json:"offset"`
+	Next       string             `json:"next,omitempty"`
+	Previous   string             `json:"previous,omitempty"`
+}
+
+// HandlePublishersRoot serves /api/v1/publishers
+func (s *Server) HandlePublishersRoot(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case http.MethodGet:
+		s.HandleGetAllPublishers(w, r)
+	case http.MethodPost:
+		s.HandleCreatePublisher(w, r)
+	default:
+		s.methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+	}
+
+}
+
+// HandlePublishersSubtree serves /api/v1/publishers/{id} and the books of the publisher under /api/v1/publishers/{id}/books
+func (s *Server) HandlePublishersSubtree(w http.ResponseWriter, r *http.Request) {
+
+	if segments := publisherPathSegments(r); len(segments) > 1 {
+		if segments[1] != "books" || len(segments) > 2 {
+			s.writeProblem(w, r, http.StatusNotFound, codeNotFound, "")
+			return
+		}
+		if r.Method != http.MethodGet {
+			s.methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		s.HandleGetPublisherBooks(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.HandleGetPublisher(w, r)
+	case http.MethodPut:
+		s.HandleUpdatePublisher(w, r)
+	case http.MethodDelete:
+		s.HandleDeletePublisher(w, r)
+	default:
+		s.methodNotAllowed(w, r, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+
+}
+
+func (s *Server) HandleGetAllPublishers(w http.ResponseWriter, r *http.Request) {
+
+	// check if user can read the books
+	if !auth.Can(currentUser(r).Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	// parse the filters and the page
+	query, err := parsePublisherQuery(r.URL.Query())
+	if err != nil {
+		s.writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	publishers := publisherCollection{Limit: query.Limit, Offset: query.Offset}
+	publishers.Publishers, publishers.Total, err = s.db.GetAllPublishers(query)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	// link the neighbouring pages
+	publishers.Next, publishers.Previous = pageLinks(r, publishers.Total, query.Limit, query.Offset)
+
+	s.writeJSON(w, r, http.StatusOK, publishers)
+}
+
+// HandleCreatePublisher adds a publisher. Anyone who can add books can add the publishers of their books.
+func (s *Server) HandleCreatePublisher(w http.ResponseWriter, r *http.Request) {
+
+	if !auth.Can(currentUser(r).Role, auth.PermissionCreateBook) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	publisher, ok := s.readPublisher(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.db.CreatePublisher(publisher); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusCreated, publisher)
+}
+
+func (s *Server) HandleGetPublisher(w http.ResponseWriter, r *http.Request) {
+
+	// check if user can read the books
+	if !auth.Can(currentUser(r).Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	publisherID, ok := s.publisherIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	publisher, err := s.db.GetPublisher(publisherID)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, publisher)
+}
+
+// HandleUpdatePublisher replaces the publisher. The publishers are shared by the books
+// of every user, so only the roles managing the publishers can change them.
+func (s *Server) HandleUpdatePublisher(w http.ResponseWriter, r *http.Request) {
+
+	if !auth.Can(currentUser(r).Role, auth.PermissionManagePublishers) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	publisherID, ok := s.publisherIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	publisher, ok := s.readPublisher(w, r)
+	if !ok {
+		return
+	}
+
+	publisher.ID = publisherID
+	if err := s.db.UpdatePublisher(publisher); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, publisher)
+}
+
+// HandleDeletePublisher deletes the publisher if it published no book
+func (s *Server) HandleDeletePublisher(w http.ResponseWriter, r *http.Request) {
+
+	if !auth.Can(currentUser(r).Role, auth.PermissionManagePublishers) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	publisherID, ok := s.publisherIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.db.DeletePublisher(publisherID); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeMessage(w, r, http.StatusOK, "publisher was deleted successfully")
+}
+
+// HandleGetPublisherBooks lists the books published by the publisher.
+// It takes the filters, the order and the page of GET /api/v1/books.
+func (s *Server) HandleGetPublisherBooks(w http.ResponseWriter, r *http.Request) {
+
+	// check if user can read the books
+	if !auth.Can(currentUser(r).Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	publisherID, ok := s.publisherIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	// an unknown publisher has no books, but it is not an empty list
+	if _, err := s.db.GetPublisher(publisherID); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	query, err := parseBookQuery(r.URL.Query())
+	if err != nil {
+		s.writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	query.PublisherID = publisherID
+
+	s.writeBooks(w, r, query)
+}
+
+// readPublisher reads and validates the publisher in the request body.
+// The id of the publisher is never taken from the body.
+func (s *Server) readPublisher(w http.ResponseWriter, r *http.Request) (*models.Publisher, bool) {
+
+	var publisher models.Publisher
+	if !s.readJSON(w, r, &publisher) {
+		return nil, false
+	}
+
+	publisher.ID = 0
+	if err := validation.Validate(&publisher); err != nil {
+		s.writeError(w, r, err)
+		return nil, false
+	}
+
+	return &publisher, true
+}
+
+// publisherPathSegments returns the segments of the path after /api/v1/publishers/
+func publisherPathSegments(r *http.Request) []string {
+	return strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/publishers/"), "/"), "/")
+}
+
+// publisherIDFromPath returns the id in /api/v1/publishers/{id}.
+// It writes the problem response and returns false when the id is not a number.
+func (s *Server) publisherIDFromPath(w http.ResponseWriter, r *http.Request) (uint, bool) {
+
+	publisherID, err := strconv.ParseUint(publisherPathSegments(r)[0], 10, 0)
+	if err != nil {
+		s.writeError(w, r, db.ErrPublisherNotFound)
+		return 0, false
+	}
+
+	return uint(publisherID), true
+}
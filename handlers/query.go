@@ -0,0 +1,240 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db"
+	"github.com/Parsa-Sh-Y/book-manager-service/isbn"
+)
+
+const (
+	defaultPageLimit = 20
+	maxPageLimit     = 100
+)
+
+// parseBookQuery reads the filters, the order and the page of GET /api/v1/books
+//
+//	category, publisher, author         filter by the given value, category also matches the subcategories
+//	category_id                         filter by the category, with its subcategories
+//	series_id                           filter by the series
+//	isbn                                filter by an ISBN-10 or ISBN-13, hyphenated or not
+//	tag                                 filter by tags, repeated or comma separated, the books need all of them
+//	volume, min_volume, max_volume      filter by volume, the ranges are inclusive
+//	published_after, published_before   filter by published date, as 2006-01-02 or RFC 3339
+//	sort                                sort field, prefixed with - for descending order
+//	limit, offset                       the page, limit defaults to 20 and can not exceed 100
+func parseBookQuery(values url.Values) (db.BookQuery, error) {
+
+	query := db.BookQuery{
+		Category:  values.Get("category"),
+		Publisher: values.Get("publisher"),
+		Author:    values.Get("author"),
+	}
+
+	for _, v := range values["tag"] {
+		for _, tag := range strings.Split(v, ",") {
+			if tag = strings.TrimSpace(tag); tag != "" {
+				query.Tags = append(query.Tags, tag)
+			}
+		}
+	}
+
+	if v := values.Get("category_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 0)
+		if err != nil {
+			return query, fmt.Errorf("category_id must be the id of a category")
+		}
+		query.CategoryID = uint(id)
+	}
+
+	if v := values.Get("series_id"); v != "" {
+		id, err := strconv.ParseUint(v, 10, 0)
+		if err != nil {
+			return query, fmt.Errorf("series_id must be the id of a series")
+		}
+		query.SeriesID = uint(id)
+	}
+	if v := values.Get("isbn"); v != "" {
+		isbn13, err := isbn.To13(v)
+		if err != nil {
+			return query, fmt.Errorf("isbn must be an ISBN-10 or an ISBN-13")
+		}
+		query.ISBN13 = isbn13
+	}
+
+	var err error
+	if v := values.Get("volume"); v != "" {
+		if query.MinVolume, err = parseIntParam("volume", v); err != nil {
+			return query, err
+		}
+		query.MaxVolume = query.MinVolume
+	}
+	if v := values.Get("min_volume"); v != "" {
+		if query.MinVolume, err = parseIntParam("min_volume", v); err != nil {
+			return query, err
+		}
+	}
+	if v := values.Get("max_volume"); v != "" {
+		if query.MaxVolume, err = parseIntParam("max_volume", v); err != nil {
+			return query, err
+		}
+	}
+	if v := values.Get("published_after"); v != "" {
+		if query.PublishedAfter, err = parseDateParam("published_after", v); err != nil {
+			return query, err
+		}
+	}
+	if v := values.Get("published_before"); v != "" {
+		if query.PublishedBefore, err = parseDateParam("published_before", v); err != nil {
+			return query, err
+		}
+	}
+
+	if sort := values.Get("sort"); sort != "" {
+		query.SortBy = strings.TrimPrefix(sort, "-")
+		query.Descending = strings.HasPrefix(sort, "-")
+		if err := query.Validate(); err != nil {
+			return query, fmt.Errorf("invalid sort field %q", query.SortBy)
+		}
+	}
+
+	query.Limit, query.Offset, err = parsePage(values)
+	return query, err
+}
+
+// parseAuthorQuery reads the filter and the page of GET /api/v1/authors
+//
+//	name            filter by a part of the name
+//	limit, offset   the page, like the books
+func parseAuthorQuery(values url.Values) (db.AuthorQuery, error) {
+
+	query := db.AuthorQuery{Name: values.Get("name")}
+
+	var err error
+	query.Limit, query.Offset, err = parsePage(values)
+	return query, err
+}
+
+// parsePublisherQuery reads the filters and the page of GET /api/v1/publishers
+//
+//	name            filter by a part of the name
+//	country         filter by the given value
+//	limit, offset   the page, like the books
+func parsePublisherQuery(values url.Values) (db.PublisherQuery, error) {
+
+	query := db.PublisherQuery{Name: values.Get("name"), Country: values.Get("country")}
+
+	var err error
+	query.Limit, query.Offset, err = parsePage(values)
+	return query, err
+}
+
+// parseTagQuery reads the filter and the page of GET /api/v1/tags
+//
+//	name            filter by a part of the name
+//	limit, offset   the page, like the books
+func parseTagQuery(values url.Values) (db.TagQuery, error) {
+
+	query := db.TagQuery{Name: values.Get("name")}
+
+	var err error
+	query.Limit, query.Offset, err = parsePage(values)
+	return query, err
+}
+
+// parseSeriesQuery reads the filter and the page of GET /api/v1/series
+//
+//	name            filter by a part of the name
+//	limit, offset   the page, like the books
+func parseSeriesQuery(values url.Values) (db.SeriesQuery, error) {
+
+	query := db.SeriesQuery{Name: values.Get("name")}
+
+	var err error
+	query.Limit, query.Offset, err = parsePage(values)
+	return query, err
+}
+
+// parsePage reads the limit and the offset of a page.
+// The limit defaults to 20 and can not exceed 100.
+func parsePage(values url.Values) (limit int, offset int, err error) {
+
+	limit = defaultPageLimit
+	if v := values.Get("limit"); v != "" {
+		n, err := parseIntParam("limit", v)
+		if err != nil {
+			return 0, 0, err
+		}
+		if *n < 1 || *n > maxPageLimit {
+			return 0, 0, fmt.Errorf("limit must be between 1 and %d", maxPageLimit)
+		}
+		limit = *n
+	}
+	if v := values.Get("offset"); v != "" {
+		n, err := parseIntParam("offset", v)
+		if err != nil {
+			return 0, 0, err
+		}
+		if *n < 0 {
+			return 0, 0, fmt.Errorf("offset can not be negative")
+		}
+		offset = *n
+	}
+
+	return limit, offset, nil
+}
+
+func parseIntParam(name string, value string) (*int, error) {
+
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("%s must be an integer", name)
+	}
+
+	return &n, nil
+}
+
+func parseDateParam(name string, value string) (*time.Time, error) {
+
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		return &t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return &t, nil
+	}
+
+	return nil, fmt.Errorf("%s must be a date like 2006-01-02", name)
+}
+
+// pageLinks returns the links to the pages of r before and after the page at offset,
+// empty when there is no such page
+func pageLinks(r *http.Request, total int64, limit int, offset int) (next string, previous string) {
+
+	if int64(offset+limit) < total {
+		next = pageLink(r, offset+limit)
+	}
+	if offset > 0 {
+		before := offset - limit
+		if before < 0 {
+			before = 0
+		}
+		previous = pageLink(r, before)
+	}
+
+	return next, previous
+}
+
+// pageLink returns the link to the page of r starting at offset
+func pageLink(r *http.Request, offset int) string {
+
+	values := r.URL.Query()
+	values.Set("offset", strconv.Itoa(offset))
+
+	link := url.URL{Path: r.URL.Path, RawQuery: values.Encode()}
+	return link.String()
+}
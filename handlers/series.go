@@ -0,0 +1,352 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/auth"
+	"github.com/Parsa-Sh-Y/book-manager-service/db"
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"github.com/Parsa-Sh-Y/book-manager-service/validation"
+)
+
+type seriesCollection struct {
+	Series   []models.Series `json:"series"`
+	Total    int64           `json:"total"`
+	Limit    int             `json:"limit"`
+	Offset   int             `json:"offset"`
+	Next     string          `json:"next,omitempty"`
+	Previous string          `json:"previous,omitempty"`
+}
+
+// seriesVolumes is a series with a page of its books in the order of their volumes
+type seriesVolumes struct {
+	models.Series
+	bookCollection
+}
+
+// nextVolume is the volume a user reads next in a series, with the books of that volume
+type nextVolume struct {
+	SeriesID uint          `json:"series_id"`
+	Volume   int           `json:"volume"`
+	Books    []models.Book `json:"books"`
+}
+
+// HandleSeriesRoot serves /api/v1/series
+func (s *Server) HandleSeriesRoot(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case http.MethodGet:
+		s.HandleGetAllSeries(w, r)
+	case http.MethodPost:
+		s.HandleCreateSeries(w, r)
+	default:
+		s.methodNotAllowed(w, r, http.MethodGet, http.MethodPost)
+	}
+
+}
+
+// HandleSeriesSubtree serves /api/v1/series/{id} and, under it, the books of the series,
+// and the progress and the next volume of the current user
+func (s *Server) HandleSeriesSubtree(w http.ResponseWriter, r *http.Request) {
+
+	if segments := seriesPathSegments(r); len(segments) > 1 {
+		handlers := map[string]http.HandlerFunc{
+			"books":    s.HandleGetSeriesBooks,
+			"progress": s.HandleGetSeriesProgress,
+			"next":     s.HandleGetNextVolume,
+		}
+		handler, ok := handlers[segments[1]]
+		if !ok || len(segments) > 2 {
+			s.writeProblem(w, r, http.StatusNotFound, codeNotFound, "")
+			return
+		}
+		if r.Method != http.MethodGet {
+			s.methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		handler(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodGet:
+		s.HandleGetSeries(w, r)
+	case http.MethodPut:
+		s.HandleUpdateSeries(w, r)
+	case http.MethodDelete:
+		s.HandleDeleteSeries(w, r)
+	default:
+		s.methodNotAllowed(w, r, http.MethodGet, http.MethodPut, http.MethodDelete)
+	}
+
+}
+
+func (s *Server) HandleGetAllSeries(w http.ResponseWriter, r *http.Request) {
+
+	// check if user can read the books
+	if !auth.Can(currentUser(r).Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	// parse the filter and the page
+	query, err := parseSeriesQuery(r.URL.Query())
+	if err != nil {
+		s.writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	series := seriesCollection{Limit: query.Limit, Offset: query.Offset}
+	series.Series, series.Total, err = s.db.GetAllSeries(query)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	// link the neighbouring pages
+	series.Next, series.Previous = pageLinks(r, series.Total, query.Limit, query.Offset)
+
+	s.writeJSON(w, r, http.StatusOK, series)
+}
+
+// HandleCreateSeries adds a series. Anyone who can add books can add the series of their books.
+func (s *Server) HandleCreateSeries(w http.ResponseWriter, r *http.Request) {
+
+	if !auth.Can(currentUser(r).Role, auth.PermissionCreateBook) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	series, ok := s.readSeries(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.db.CreateSeries(series); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusCreated, series)
+}
+
+// HandleGetSeries returns the series with a page of its books in the order of their volumes.
+// It takes the limit and the offset of GET /api/v1/books.
+func (s *Server) HandleGetSeries(w http.ResponseWriter, r *http.Request) {
+
+	// check if user can read the books
+	if !auth.Can(currentUser(r).Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	seriesID, ok := s.seriesIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	query := db.BookQuery{SeriesID: seriesID, SortBy: db.SortByVolume}
+	var err error
+	query.Limit, query.Offset, err = parsePage(r.URL.Query())
+	if err != nil {
+		s.writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	series, err := s.db.GetSeries(seriesID)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	volumes := seriesVolumes{Series: *series, bookCollection: bookCollection{Limit: query.Limit, Offset: query.Offset}}
+	volumes.Books, volumes.Total, err = s.db.GetAllBooks(query)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	volumes.Next, volumes.Previous = pageLinks(r, volumes.Total, query.Limit, query.Offset)
+
+	s.writeJSON(w, r, http.StatusOK, volumes)
+}
+
+// HandleUpdateSeries replaces the series. The series are shared by the books
+// of every user, so only the roles managing the series can change them.
+func (s *Server) HandleUpdateSeries(w http.ResponseWriter, r *http.Request) {
+
+	if !auth.Can(currentUser(r).Role, auth.PermissionManageSeries) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	seriesID, ok := s.seriesIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	series, ok := s.readSeries(w, r)
+	if !ok {
+		return
+	}
+
+	series.ID = seriesID
+	if err := s.db.UpdateSeries(series); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, series)
+}
+
+// HandleDeleteSeries deletes the series if it has no book
+func (s *Server) HandleDeleteSeries(w http.ResponseWriter, r *http.Request) {
+
+	if !auth.Can(currentUser(r).Role, auth.PermissionManageSeries) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	seriesID, ok := s.seriesIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	if err := s.db.DeleteSeries(seriesID); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeMessage(w, r, http.StatusOK, "series was deleted successfully")
+}
+
+// HandleGetSeriesBooks lists the books of the series, by default in the order of their volumes.
+// It takes the filters, the order and the page of GET /api/v1/books.
+func (s *Server) HandleGetSeriesBooks(w http.ResponseWriter, r *http.Request) {
+
+	// check if user can read the books
+	if !auth.Can(currentUser(r).Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	seriesID, ok := s.seriesIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	// an unknown series has no books, but it is not an empty list
+	if _, err := s.db.GetSeries(seriesID); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	query, err := parseBookQuery(r.URL.Query())
+	if err != nil {
+		s.writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	query.SeriesID = seriesID
+	if query.SortBy == "" {
+		query.SortBy = db.SortByVolume
+	}
+
+	s.writeBooks(w, r, query)
+}
+
+// HandleGetSeriesProgress returns the volumes of the series the current user has
+// books of, the volumes missing from their collection and the volume they read next
+func (s *Server) HandleGetSeriesProgress(w http.ResponseWriter, r *http.Request) {
+
+	account := currentUser(r)
+	if !auth.Can(account.Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	seriesID, ok := s.seriesIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	progress, err := s.db.GetSeriesProgress(seriesID, account.ID)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, progress)
+}
+
+// HandleGetNextVolume returns the volume after the last one the current user has,
+// with the books of that volume in the catalog. It is not found once the user has
+// the last volume of a series with a known number of volumes.
+func (s *Server) HandleGetNextVolume(w http.ResponseWriter, r *http.Request) {
+
+	account := currentUser(r)
+	if !auth.Can(account.Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	seriesID, ok := s.seriesIDFromPath(w, r)
+	if !ok {
+		return
+	}
+
+	progress, err := s.db.GetSeriesProgress(seriesID, account.ID)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+	if progress.Next == 0 {
+		s.writeError(w, r, db.ErrNoNextVolume)
+		return
+	}
+
+	volume := progress.Next
+	books, _, err := s.db.GetAllBooks(db.BookQuery{SeriesID: seriesID, MinVolume: &volume, MaxVolume: &volume})
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeJSON(w, r, http.StatusOK, nextVolume{SeriesID: seriesID, Volume: volume, Books: *books})
+}
+
+// readSeries reads and validates the series in the request body.
+// The id of the series is never taken from the body.
+func (s *Server) readSeries(w http.ResponseWriter, r *http.Request) (*models.Series, bool) {
+
+	var series models.Series
+	if !s.readJSON(w, r, &series) {
+		return nil, false
+	}
+
+	series.ID = 0
+	if err := validation.Validate(&series); err != nil {
+		s.writeError(w, r, err)
+		return nil, false
+	}
+
+	return &series, true
+}
+
+// seriesPathSegments returns the segments of the path after /api/v1/series/
+func seriesPathSegments(r *http.Request) []string {
+	return strings.Split(strings.Trim(strings.TrimPrefix(r.URL.Path, "/api/v1/series/"), "/"), "/")
+}
+
+// seriesIDFromPath returns the id in /api/v1/series/{id}.
+// It writes the problem response and returns false when the id is not a number.
+func (s *Server) seriesIDFromPath(w http.ResponseWriter, r *http.Request) (uint, bool) {
+
+	seriesID, err := strconv.ParseUint(seriesPathSegments(r)[0], 10, 0)
+	if err != nil {
+		s.writeError(w, r, db.ErrSeriesNotFound)
+		return 0, false
+	}
+
+	return uint(seriesID), true
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+)
+
+func TestGetSeriesPage(t *testing.T) {
+
+	ts := newTestServer(t)
+	var series *models.Series
+	for _, volume := range []int{3, 1, 2} {
+		book := ts.createBook(t, "ali", models.Book{Name: fmt.Sprintf("Dune %d", volume), Series: &models.Series{Name: "Dune"}, Volumn: volume})
+		series = book.Series
+	}
+
+	tests := []struct {
+		query    string
+		status   int
+		volumes  []int
+		next     string
+		previous string
+	}{
+		{"", http.StatusOK, []int{1, 2, 3}, "", ""},
+		{"?limit=2", http.StatusOK, []int{1, 2}, fmt.Sprintf("/api/v1/series/%d?limit=2&offset=2", series.ID), ""},
+		{"?limit=2&offset=2", http.StatusOK, []int{3}, "", fmt.Sprintf("/api/v1/series/%d?limit=2&offset=0", series.ID)},
+		{"?limit=101", http.StatusBadRequest, nil, "", ""},
+		{"?offset=-1", http.StatusBadRequest, nil, "", ""},
+	}
+
+	for _, tt := range tests {
+		resp := ts.do(t, "reza", http.MethodGet, fmt.Sprintf("/api/v1/series/%d%s", series.ID, tt.query), "")
+		if resp.StatusCode != tt.status {
+			t.Errorf("%s: status = %d, want %d", tt.query, resp.StatusCode, tt.status)
+			continue
+		}
+		if tt.status != http.StatusOK {
+			if code := problemCode(t, resp); code != codeInvalidParameter {
+				t.Errorf("%s: code = %q, want %q", tt.query, code, codeInvalidParameter)
+			}
+			continue
+		}
+
+		var page struct {
+			Name     string        `json:"name"`
+			Books    []models.Book `json:"books"`
+			Total    int64         `json:"total"`
+			Next     string        `json:"next"`
+			Previous string        `json:"previous"`
+		}
+		decode(t, resp, &page)
+		var volumes []int
+		for _, book := range page.Books {
+			volumes = append(volumes, book.Volumn)
+		}
+		if page.Name != "Dune" || page.Total != 3 || fmt.Sprint(volumes) != fmt.Sprint(tt.volumes) ||
+			page.Next != tt.next || page.Previous != tt.previous {
+			t.Errorf("%s: page = %q, total %d, volumes %v, next %q, previous %q, want volumes %v, next %q, previous %q",
+				tt.query, page.Name, page.Total, volumes, page.Next, page.Previous, tt.volumes, tt.next, tt.previous)
+		}
+	}
+}
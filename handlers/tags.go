@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/auth"
+	"github.com/Parsa-Sh-Y/book-manager-service/db"
+	"github.com/Parsa-Sh-Y/book-manager-service/validation"
+)
+
+type tagCollection struct {
+	Tags     []db.TagCount `json:"tags"`
+	Total    int64         `json:"total"`
+	Limit    int           `json:"limit"`
+	Offset   int           `json:"offset"`
+	Next     string        `json:"next,omitempty"`
+	Previous string        `json:"previous,omitempty"`
+}
+
+// tagRename is the body of PUT /api/v1/tags/{name}
+type tagRename struct {
+	Name string `json:"name" validate:"required,max=50"`
+}
+
+// HandleTagsRoot serves /api/v1/tags
+func (s *Server) HandleTagsRoot(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodGet {
+		s.methodNotAllowed(w, r, http.MethodGet)
+		return
+	}
+
+	// check if user can read the books
+	if !auth.Can(currentUser(r).Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	// parse the filter and the page
+	query, err := parseTagQuery(r.URL.Query())
+	if err != nil {
+		s.writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+
+	tags := tagCollection{Limit: query.Limit, Offset: query.Offset}
+	tags.Tags, tags.Total, err = s.db.GetAllTags(query)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	// link the neighbouring pages
+	tags.Next, tags.Previous = pageLinks(r, tags.Total, query.Limit, query.Offset)
+
+	s.writeJSON(w, r, http.StatusOK, tags)
+}
+
+// HandleTagsSubtree serves /api/v1/tags/{name} and the books having the tag under /api/v1/tags/{name}/books
+func (s *Server) HandleTagsSubtree(w http.ResponseWriter, r *http.Request) {
+
+	segments, ok := tagPathSegments(r)
+	if !ok || len(segments) > 2 || len(segments) == 2 && segments[1] != "books" {
+		s.writeProblem(w, r, http.StatusNotFound, codeNotFound, "")
+		return
+	}
+
+	if len(segments) == 2 {
+		if r.Method != http.MethodGet {
+			s.methodNotAllowed(w, r, http.MethodGet)
+			return
+		}
+		s.HandleGetTagBooks(w, r, segments[0])
+		return
+	}
+
+	switch r.Method {
+	case http.MethodPut:
+		s.HandleRenameTag(w, r, segments[0])
+	case http.MethodDelete:
+		s.HandleDeleteTag(w, r, segments[0])
+	default:
+		s.methodNotAllowed(w, r, http.MethodPut, http.MethodDelete)
+	}
+
+}
+
+// HandleRenameTag renames the tag on every book having it. Renaming it to
+// an existing tag merges the two.
+func (s *Server) HandleRenameTag(w http.ResponseWriter, r *http.Request, name string) {
+
+	if !auth.Can(currentUser(r).Role, auth.PermissionManageTags) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	var rename tagRename
+	if !s.readJSON(w, r, &rename) {
+		return
+	}
+	if err := validation.Validate(&rename); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	if err := s.db.RenameTag(name, rename.Name); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeMessage(w, r, http.StatusOK, "tag was renamed successfully")
+}
+
+// HandleDeleteTag takes the tag off every book having it
+func (s *Server) HandleDeleteTag(w http.ResponseWriter, r *http.Request, name string) {
+
+	if !auth.Can(currentUser(r).Role, auth.PermissionManageTags) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	if err := s.db.DeleteTag(name); err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeMessage(w, r, http.StatusOK, "tag was deleted successfully")
+}
+
+// HandleGetTagBooks lists the books having the tag.
+// It takes the filters, the order and the page of GET /api/v1/books.
+func (s *Server) HandleGetTagBooks(w http.ResponseWriter, r *http.Request, name string) {
+
+	// check if user can read the books
+	if !auth.Can(currentUser(r).Role, auth.PermissionReadBooks) {
+		s.writeError(w, r, db.ErrPermissionDenied)
+		return
+	}
+
+	query, err := parseBookQuery(r.URL.Query())
+	if err != nil {
+		s.writeProblem(w, r, http.StatusBadRequest, codeInvalidParameter, err.Error())
+		return
+	}
+	query.Tags = append(query.Tags, name)
+
+	s.writeBooks(w, r, query)
+}
+
+// tagPathSegments returns the unescaped segments of the path after /api/v1/tags/,
+// so the names of the tags may have escaped slashes. ok is false when the path
+// is not escaped correctly.
+func tagPathSegments(r *http.Request) (segments []string, ok bool) {
+
+	path := strings.Trim(strings.TrimPrefix(r.URL.EscapedPath(), "/api/v1/tags/"), "/")
+	for _, segment := range strings.Split(path, "/") {
+		segment, err := url.PathUnescape(segment)
+		if err != nil || segment == "" {
+			return nil, false
+		}
+		segments = append(segments, segment)
+	}
+
+	return segments, true
+}
@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+)
+
+type userCollection struct {
+	Users []models.User `json:"users"`
+}
+
+type roleRequestBody struct {
+	Role string `json:"role"`
+}
+
+// HandleUsersRoot serves /api/v1/users.
+// The users handlers are only for admins, see Require.
+func (s *Server) HandleUsersRoot(w http.ResponseWriter, r *http.Request) {
+
+	switch r.Method {
+	case http.MethodGet:
+		s.HandleGetAllUsers(w, r)
+	default:
+		s.methodNotAllowed(w, r, http.MethodGet)
+	}
+
+}
+
+// HandleUsersSubtree serves /api/v1/users/{username} and /api/v1/users/{username}/role
+func (s *Server) HandleUsersSubtree(w http.ResponseWriter, r *http.Request) {
+
+	switch {
+	case path.Base(r.URL.Path) == "role" && r.Method == http.MethodPut:
+		s.HandleUpdateUserRole(w, r)
+	case path.Base(r.URL.Path) == "role":
+		s.methodNotAllowed(w, r, http.MethodPut)
+	case r.Method == http.MethodGet:
+		s.HandleGetUser(w, r)
+	case r.Method == http.MethodDelete:
+		s.HandleDeleteUser(w, r)
+	default:
+		s.methodNotAllowed(w, r, http.MethodGet, http.MethodDelete)
+	}
+
+}
+
+// usernameFromPath returns the username in /api/v1/users/{username}[/role]
+func usernameFromPath(p string) string {
+
+	p = strings.TrimSuffix(strings.TrimPrefix(p, "/api/v1/users/"), "/role")
+	return strings.Trim(p, "/")
+}
+
+func (s *Server) HandleGetAllUsers(w http.ResponseWriter, r *http.Request) {
+
+	users, err := s.db.GetAllUsers()
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	// never send the password hashes
+	for i := range users {
+		users[i].Password = ""
+	}
+
+	s.writeJSON(w, r, http.StatusOK, userCollection{Users: users})
+}
+
+func (s *Server) HandleGetUser(w http.ResponseWriter, r *http.Request) {
+
+	user, err := s.db.GetUserByUsername(usernameFromPath(r.URL.Path))
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	// never send the password hash
+	user.Password = ""
+
+	s.writeJSON(w, r, http.StatusOK, user)
+}
+
+func (s *Server) HandleUpdateUserRole(w http.ResponseWriter, r *http.Request) {
+
+	// get the request body
+	var reqBody roleRequestBody
+	if !s.readJSON(w, r, &reqBody) {
+		return
+	}
+	if !models.IsValidRole(reqBody.Role) {
+		s.writeProblem(w, r, http.StatusBadRequest, codeInvalidRequestBody, "role must be admin, librarian or member")
+		return
+	}
+
+	// update the role
+	err := s.db.UpdateUserRole(usernameFromPath(r.URL.Path), reqBody.Role)
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeMessage(w, r, http.StatusOK, "role was updated successfully")
+}
+
+func (s *Server) HandleDeleteUser(w http.ResponseWriter, r *http.Request) {
+
+	// delete the user with its books
+	err := s.db.DeleteUser(usernameFromPath(r.URL.Path))
+	if err != nil {
+		s.writeError(w, r, err)
+		return
+	}
+
+	s.writeMessage(w, r, http.StatusOK, "user was deleted successfully")
+}
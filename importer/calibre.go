@@ -0,0 +1,307 @@
+package importer
+
+import (
+	"database/sql"
+	"fmt"
+	"html"
+	"io"
+	"net/url"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"github.com/Parsa-Sh-Y/book-manager-service/isbn"
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// calibreUnknownAuthor is the author Calibre gives the books whose author is not known
+const calibreUnknownAuthor = "Unknown"
+
+// calibreUndefinedYear is the year of the published date Calibre gives the books whose date is not known
+const calibreUndefinedYear = 101
+
+// The queries of the metadata of the books in a Calibre library, each row starting with the id of the book
+const (
+	calibreBooksQuery      = "SELECT id, title, pubdate, isbn FROM books ORDER BY id"
+	calibreAuthorsQuery    = "SELECT l.book, a.name, a.sort FROM books_authors_link l JOIN authors a ON a.id = l.author ORDER BY l.id"
+	calibrePublishersQuery = "SELECT l.book, p.name FROM books_publishers_link l JOIN publishers p ON p.id = l.publisher"
+	calibreSeriesQuery     = "SELECT l.book, s.name, b.series_index FROM books_series_link l JOIN series s ON s.id = l.series JOIN books b ON b.id = l.book"
+	calibreTagsQuery       = "SELECT l.book, t.name FROM books_tags_link l JOIN tags t ON t.id = l.tag ORDER BY t.name"
+	calibreRatingsQuery    = "SELECT l.book, r.rating FROM books_ratings_link l JOIN ratings r ON r.id = l.rating"
+	calibreISBNsQuery      = "SELECT book, val FROM identifiers WHERE type = 'isbn'"
+	calibreCommentsQuery   = "SELECT book, text FROM comments"
+)
+
+var (
+	// htmlBreaks are the tags ending a line of the comments of Calibre
+	htmlBreaks   = regexp.MustCompile(`(?i)<br\s*/?>|</(p|div|li|h[1-6])>`)
+	htmlComments = regexp.MustCompile(`(?s)<!--.*?-->`)
+	htmlTags     = regexp.MustCompile(`<[^>]*>`)
+)
+
+// CalibreReader reads the books of the metadata.db file of a Calibre library. The
+// comments of the books are their summary, the tags and the series are kept, and the
+// ratings of up to 10 half stars are rounded up to whole stars. The line of a row is
+// the id of the book in the library.
+type CalibreReader struct {
+	books []models.Book
+	lines []int
+}
+
+// LoadCalibre reads the books of the Calibre library whose metadata.db file is at path.
+// The file is opened read-only and closed before it returns.
+func LoadCalibre(path string) (*CalibreReader, error) {
+
+	dsn, err := calibreDSN(path)
+	if err != nil {
+		return nil, err
+	}
+	library, err := gorm.Open(sqlite.Open(dsn), &gorm.Config{Logger: logger.Default.LogMode(logger.Silent)})
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrInvalidLibrary, err)
+	}
+	if sqlDB, err := library.DB(); err == nil {
+		defer sqlDB.Close()
+	}
+
+	c := &CalibreReader{}
+	if err := c.load(library); err != nil {
+		return nil, fmt.Errorf("%w: it is not the metadata.db of a Calibre library: %v", ErrInvalidLibrary, err)
+	}
+
+	return c, nil
+}
+
+// calibreDSN returns the URI opening the file at path read-only, such as file:///a/metadata.db?mode=ro.
+// The characters of the path such as ? and # are escaped, so they are not read as the query of the URI.
+func calibreDSN(path string) (string, error) {
+
+	abs, err := filepath.Abs(path)
+	if err != nil {
+		return "", err
+	}
+
+	// the path of the URI starts with a slash, even before a drive letter such as C:
+	slashed := filepath.ToSlash(abs)
+	if !strings.HasPrefix(slashed, "/") {
+		slashed = "/" + slashed
+	}
+	uri := url.URL{Scheme: "file", Path: slashed, RawQuery: "mode=ro"}
+
+	return uri.String(), nil
+}
+
+func (c *CalibreReader) Next() (Row, error) {
+
+	if len(c.books) == 0 {
+		return Row{}, io.EOF
+	}
+
+	row := Row{Line: c.lines[0], Book: c.books[0]}
+	c.books, c.lines = c.books[1:], c.lines[1:]
+
+	return row, nil
+}
+
+// load reads the books of the library with their metadata
+func (c *CalibreReader) load(library *gorm.DB) error {
+
+	rows, err := library.Raw(calibreBooksQuery).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int
+		var title, number sql.NullString
+		var pubdate interface{}
+		if err := rows.Scan(&id, &title, &pubdate, &number); err != nil {
+			return err
+		}
+
+		book := models.Book{Name: strings.TrimSpace(title.String), PublishedAt: calibreDate(pubdate)}
+		setCalibreISBN(&book, number.String)
+		c.books = append(c.books, book)
+		c.lines = append(c.lines, id)
+	}
+	if err := rows.Err(); err != nil {
+		return err
+	}
+
+	// the books by their id in the library
+	books := make(map[string]*models.Book, len(c.books))
+	for i := range c.books {
+		books[strconv.Itoa(c.lines[i])] = &c.books[i]
+	}
+
+	queries := []struct {
+		query string
+		set   func(book *models.Book, values []string)
+	}{
+		{calibreAuthorsQuery, func(book *models.Book, values []string) {
+			if author, ok := calibreAuthor(values[0], values[1]); ok {
+				book.Authors = append(book.Authors, models.BookAuthor{Role: models.AuthorRoleAuthor, Author: author})
+			}
+		}},
+		{calibrePublishersQuery, func(book *models.Book, values []string) {
+			book.Publisher = &models.Publisher{Name: values[0]}
+		}},
+		{calibreSeriesQuery, func(book *models.Book, values []string) {
+			book.Series = &models.Series{Name: values[0]}
+			// a volume between two others, such as 1.5, is left out
+			if volume, err := strconv.ParseFloat(values[1], 64); err == nil && volume == float64(int(volume)) && volume > 0 {
+				book.Volumn = int(volume)
+			}
+		}},
+		{calibreTagsQuery, func(book *models.Book, values []string) {
+			book.Tags = append(book.Tags, models.Tag{Name: values[0]})
+		}},
+		{calibreRatingsQuery, func(book *models.Book, values []string) {
+			if halfStars, err := strconv.Atoi(values[0]); err == nil {
+				book.Rating = calibreStars(halfStars)
+			}
+		}},
+		{calibreISBNsQuery, func(book *models.Book, values []string) {
+			// the identifier replaces the ISBN kept in the books table by the older versions of Calibre
+			book.ISBN10, book.ISBN13 = "", ""
+			setCalibreISBN(book, values[0])
+		}},
+		{calibreCommentsQuery, func(book *models.Book, values []string) {
+			book.Summary = htmlText(values[0])
+		}},
+	}
+	for _, q := range queries {
+		if err := eachBookRow(library, q.query, books, q.set); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// eachBookRow runs the query and calls set with the book and the other values of
+// each row, whose first column is the id of a book. Rows of unknown books are skipped.
+func eachBookRow(library *gorm.DB, query string, books map[string]*models.Book, set func(book *models.Book, values []string)) error {
+
+	rows, err := library.Raw(query).Rows()
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return err
+	}
+
+	values := make([]sql.NullString, len(columns))
+	dest := make([]interface{}, len(columns))
+	for i := range values {
+		dest[i] = &values[i]
+	}
+
+	for rows.Next() {
+		if err := rows.Scan(dest...); err != nil {
+			return err
+		}
+		book, ok := books[values[0].String]
+		if !ok {
+			continue
+		}
+
+		strs := make([]string, 0, len(values)-1)
+		for _, value := range values[1:] {
+			strs = append(strs, strings.TrimSpace(value.String))
+		}
+		set(book, strs)
+	}
+
+	return rows.Err()
+}
+
+// calibreAuthor returns the author of the name and the sort of an author of Calibre.
+// The sort is "Last, First" when Calibre could tell the last name, the name is split
+// on its last word otherwise. The unknown author is not an author.
+func calibreAuthor(name string, sort string) (models.Author, bool) {
+
+	// Calibre writes the commas in the names of the authors as |
+	name = strings.ReplaceAll(name, "|", ",")
+	if strings.TrimSpace(name) == "" || name == calibreUnknownAuthor {
+		return models.Author{}, false
+	}
+	if strings.Contains(sort, ",") {
+		name = sort
+	}
+
+	return models.AuthorFromName(name), true
+}
+
+// calibreStars returns the rating of up to 5 stars of a rating of up to 10 half stars,
+// rounded up. Zero is not rated.
+func calibreStars(halfStars int) int {
+
+	if halfStars <= 0 {
+		return 0
+	}
+
+	return (halfStars + 1) / 2
+}
+
+// setCalibreISBN sets the ISBN of the book of the same length as number
+func setCalibreISBN(book *models.Book, number string) {
+
+	switch number = isbn.Normalize(number); len(number) {
+	case 10:
+		book.ISBN10 = number
+	case 13:
+		book.ISBN13 = number
+	}
+}
+
+// calibreDate returns the published date of a book, stored as a timestamp
+// or as the text of one. The zero time is returned when the date is not known.
+func calibreDate(v interface{}) time.Time {
+
+	var date time.Time
+	var text string
+	switch v := v.(type) {
+	case time.Time:
+		date = v
+	case string:
+		text = v
+	case []byte:
+		text = string(v)
+	}
+	if len(text) >= 10 {
+		date, _ = time.Parse("2006-01-02", text[:10])
+	}
+
+	if date.Year() <= calibreUndefinedYear {
+		return time.Time{}
+	}
+
+	return time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+// htmlText returns the text of the HTML of the comments of a book, a paragraph on each line
+func htmlText(s string) string {
+
+	s = htmlComments.ReplaceAllString(s, "")
+	s = htmlBreaks.ReplaceAllString(s, "\n")
+	s = html.UnescapeString(htmlTags.ReplaceAllString(s, ""))
+
+	var lines []string
+	for _, line := range strings.Split(s, "\n") {
+		if line = strings.Join(strings.Fields(line), " "); line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,240 @@
+package importer
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+)
+
+func TestCalibreDate(t *testing.T) {
+
+	dune := time.Date(1990, time.September, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name string
+		in   interface{}
+		want time.Time
+	}{
+		{"text", "1990-09-01 04:00:00+00:00", dune},
+		{"bytes", []byte("1990-09-01 04:00:00+00:00"), dune},
+		{"date only", "1990-09-01", dune},
+		{"timestamp", time.Date(1990, time.September, 1, 23, 30, 0, 0, time.FixedZone("", -5*3600)), dune},
+		{"unknown", "0101-01-01 00:00:00+00:00", time.Time{}},
+		{"unknown timestamp", time.Date(101, time.January, 1, 0, 0, 0, 0, time.UTC), time.Time{}},
+		{"before the unknown date", "0100-12-31 00:00:00+00:00", time.Time{}},
+		{"after the unknown year", "0102-01-01 00:00:00+00:00", time.Date(102, time.January, 1, 0, 0, 0, 0, time.UTC)},
+		{"too short", "1990-09", time.Time{}},
+		{"not a date", "September 1, 1990", time.Time{}},
+		{"null", nil, time.Time{}},
+		{"number", int64(1990), time.Time{}},
+	}
+
+	for _, tt := range tests {
+		if got := calibreDate(tt.in); !got.Equal(tt.want) {
+			t.Errorf("%s: calibreDate(%v) = %v, want %v", tt.name, tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCalibreStars(t *testing.T) {
+
+	// the half stars rounded up
+	want := []int{0, 1, 1, 2, 2, 3, 3, 4, 4, 5, 5}
+	for halfStars, stars := range want {
+		if got := calibreStars(halfStars); got != stars {
+			t.Errorf("calibreStars(%d) = %d, want %d", halfStars, got, stars)
+		}
+	}
+
+	if got := calibreStars(-2); got != 0 {
+		t.Errorf("calibreStars(-2) = %d, want 0", got)
+	}
+}
+
+func TestCalibreAuthor(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		sort   string
+		want   models.Author
+		wantOK bool
+	}{
+		{"Frank Herbert", "Herbert, Frank", models.Author{FirstName: "Frank", LastName: "Herbert"}, true},
+		{"Ursula K. Le Guin", "Le Guin, Ursula K.", models.Author{FirstName: "Ursula K.", LastName: "Le Guin"}, true},
+		// a sort without a comma is not a "Last, First" name
+		{"Gerald Jay Sussman", "Gerald Jay Sussman", models.Author{FirstName: "Gerald Jay", LastName: "Sussman"}, true},
+		{"Le Guin", "", models.Author{FirstName: "Le", LastName: "Guin"}, true},
+		{"Plato", "Plato", models.Author{LastName: "Plato"}, true},
+		// the | of a name is a comma
+		{"Sussman| Julie", "Sussman| Julie", models.Author{FirstName: "Julie", LastName: "Sussman"}, true},
+		{"Unknown", "Unknown", models.Author{}, false},
+		{"", "Herbert, Frank", models.Author{}, false},
+		{"  ", "", models.Author{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := calibreAuthor(tt.name, tt.sort)
+		if got != tt.want || ok != tt.wantOK {
+			t.Errorf("calibreAuthor(%q, %q) = %+v, %v, want %+v, %v", tt.name, tt.sort, got, ok, tt.want, tt.wantOK)
+		}
+	}
+}
+
+func TestHTMLText(t *testing.T) {
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"", ""},
+		{"plain text", "plain text"},
+		{"<p>One</p><p>Two</p>", "One\nTwo"},
+		{"<div><p>A  <em>classic</em><br/>of science fiction &amp; more.</p></div>", "A classic\nof science fiction & more."},
+		{"line<br>break<BR />again", "line\nbreak\nagain"},
+		{"<ul><li>one</li><li>two</li></ul>", "one\ntwo"},
+		{"<h2>Title</h2>text", "Title\ntext"},
+		{"Paul&nbsp;Atreides &lt;3", "Paul Atreides <3"},
+		{"<p>\n\n  spread\n  over   lines </p>", "spread\nover lines"},
+		// the comments are left out with the tags in them
+		{"before<!-- a <b>comment</b> -->after", "beforeafter"},
+		{"<!-- one -->text<!--\ntwo\n-->", "text"},
+		{"<p>kept</p><!-- <p>hidden</p> --><p>shown</p>", "kept\nshown"},
+	}
+
+	for _, tt := range tests {
+		if got := htmlText(tt.in); got != tt.want {
+			t.Errorf("htmlText(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestCalibreDSN(t *testing.T) {
+
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/library/metadata.db", "file:///library/metadata.db?mode=ro"},
+		{"/my library/metadata.db", "file:///my%20library/metadata.db?mode=ro"},
+		{"/a?b#c%d/metadata.db", "file:///a%3Fb%23c%25d/metadata.db?mode=ro"},
+	}
+
+	for _, tt := range tests {
+		got, err := calibreDSN(tt.path)
+		if err != nil || got != tt.want {
+			t.Errorf("calibreDSN(%q) = %q, %v, want %q", tt.path, got, err, tt.want)
+		}
+	}
+}
+
+func TestLoadCalibre(t *testing.T) {
+
+	reader, err := LoadCalibre("testdata/metadata.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := readAll(t, reader)
+
+	author := func(first, last string) models.BookAuthor {
+		return models.BookAuthor{Role: models.AuthorRoleAuthor, Author: models.Author{FirstName: first, LastName: last}}
+	}
+	want := []Row{
+		{Line: 1, Book: models.Book{
+			Name:        "Dune",
+			ISBN13:      "9780441172719",
+			PublishedAt: time.Date(1990, time.September, 1, 0, 0, 0, 0, time.UTC),
+			Authors:     []models.BookAuthor{author("Frank", "Herbert")},
+			Publisher:   &models.Publisher{Name: "Ace Books"},
+			Series:      &models.Series{Name: "Dune"},
+			Volumn:      1,
+			Tags:        []models.Tag{{Name: "Classic"}, {Name: "Science Fiction"}},
+			Rating:      5,
+			Summary:     "Set on the desert planet Arrakis, Dune is the story of Paul Atreides.\nA classic\nof science fiction & more.",
+		}},
+		{Line: 2, Book: models.Book{
+			Name:    "The Two Towers",
+			ISBN10:  "0618002235",
+			Authors: []models.BookAuthor{author("J. R. R.", "Tolkien")},
+			Series:  &models.Series{Name: "The Lord of the Rings"},
+			Volumn:  2,
+			Tags:    []models.Tag{{Name: "Fantasy"}},
+			Rating:  4,
+			Summary: "The second volume.",
+		}},
+		// the unknown author and an unrated book, in a series with a volume between two others
+		{Line: 3, Book: models.Book{Name: "Untitled Notes", Series: &models.Series{Name: "Notes"}}},
+		{Line: 5, Book: models.Book{
+			Name:        "Structure and Interpretation of Computer Programs",
+			ISBN10:      "0262510871",
+			PublishedAt: time.Date(1996, time.July, 25, 0, 0, 0, 0, time.UTC),
+			Authors:     []models.BookAuthor{author("Harold", "Abelson"), author("Gerald Jay", "Sussman"), author("Julie", "Sussman")},
+			Publisher:   &models.Publisher{Name: "MIT Press"},
+			Rating:      1,
+		}},
+	}
+
+	if len(rows) != len(want) {
+		t.Fatalf("%d rows, want %d", len(rows), len(want))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(rows[i], want[i]) {
+			t.Errorf("row %d =\n%+v\nwant\n%+v", i, rows[i], want[i])
+		}
+	}
+}
+
+func TestLoadCalibrePath(t *testing.T) {
+
+	data, err := os.ReadFile("testdata/metadata.db")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the characters of a URI in the path of the library
+	dir := filepath.Join(t.TempDir(), "my library #1?mode=rw&100%")
+	if err := os.Mkdir(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	path := filepath.Join(dir, "metadata.db")
+	if err := os.WriteFile(path, data, 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	reader, err := LoadCalibre(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rows := readAll(t, reader); len(rows) != 4 {
+		t.Errorf("%d rows, want 4", len(rows))
+	}
+
+	// a relative path
+	wd, _ := os.Getwd()
+	relative, err := filepath.Rel(wd, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := LoadCalibre(relative); err != nil {
+		t.Errorf("LoadCalibre(%q) = %v", relative, err)
+	}
+}
+
+func TestLoadCalibreInvalid(t *testing.T) {
+
+	dir := t.TempDir()
+	text := filepath.Join(dir, "books.csv")
+	if err := os.WriteFile(text, []byte("name\nDune\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, path := range []string{text, filepath.Join(dir, "missing.db"), "testdata/goodreads_library_export.csv"} {
+		if _, err := LoadCalibre(path); !errors.Is(err, ErrInvalidLibrary) {
+			t.Errorf("LoadCalibre(%q) = %v, want %v", path, err, ErrInvalidLibrary)
+		}
+	}
+}
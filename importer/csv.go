@@ -0,0 +1,263 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"github.com/Parsa-Sh-Y/book-manager-service/isbn"
+)
+
+// ErrInvalidMapping The column mapping names an unknown field or a column missing from the file
+var ErrInvalidMapping = errors.New("invalid column mapping")
+
+// The fields of a book the columns of a CSV file are mapped to. The lists of authors,
+// tags and contents are separated by semicolons, and the authors are written as
+// "First Last" or "Last, First". isbn takes either form of the ISBN.
+const (
+	FieldName            = "name"
+	FieldISBN            = "isbn"
+	FieldISBN10          = "isbn_10"
+	FieldISBN13          = "isbn_13"
+	FieldAuthors         = "authors"
+	FieldPublisher       = "publisher"
+	FieldCategory        = "category"
+	FieldSeries          = "series"
+	FieldVolume          = "volume"
+	FieldPublishedAt     = "published_at"
+	FieldSummary         = "summary"
+	FieldTags            = "tags"
+	FieldTableOfContents = "table_of_contents"
+	FieldRating          = "rating"
+)
+
+var csvFields = []string{
+	FieldName, FieldISBN, FieldISBN10, FieldISBN13, FieldAuthors, FieldPublisher, FieldCategory,
+	FieldSeries, FieldVolume, FieldPublishedAt, FieldSummary, FieldTags, FieldTableOfContents, FieldRating,
+}
+
+// publishedAtLayouts are the formats of the published dates in the CSV files
+var publishedAtLayouts = []string{"2006-01-02", "2006/01/02", "2006-01", "2006"}
+
+// Mapping maps the fields of the books to the columns of a CSV file by their header
+type Mapping map[string]string
+
+// ParseMapping reads the mapping from field:column pairs, such as name:Title.
+// The columns of the file not in the mapping are mapped to the field of their name,
+// ignoring case and reading spaces and hyphens as underscores.
+func ParseMapping(pairs []string) (Mapping, error) {
+
+	mapping := make(Mapping, len(pairs))
+	for _, pair := range pairs {
+		field, column, ok := strings.Cut(pair, ":")
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !ok || !isCSVField(field) || strings.TrimSpace(column) == "" {
+			return nil, fmt.Errorf("%w %q, it is field:column with a field of %s", ErrInvalidMapping, pair, strings.Join(csvFields, ", "))
+		}
+		mapping[field] = strings.TrimSpace(column)
+	}
+
+	return mapping, nil
+}
+
+// CSVReader reads the books of a CSV file with a header row
+type CSVReader struct {
+	reader *csv.Reader
+	// columns are the indexes of the mapped fields in the rows
+	columns map[string]int
+}
+
+// NewCSVReader reads the header of the CSV file and maps its columns to the fields of the books
+func NewCSVReader(r io.Reader, mapping Mapping) (*CSVReader, error) {
+
+	reader, header, err := openCSV(r)
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: the file has no header", ErrInvalidMapping)
+	} else if err != nil {
+		return nil, err
+	}
+
+	columns := make(map[string]int)
+	for column, i := range header {
+		// the leftmost of the columns read into the same field
+		if field := fieldKey(column); isCSVField(field) {
+			if j, ok := columns[field]; !ok || i < j {
+				columns[field] = i
+			}
+		}
+	}
+	for field, column := range mapping {
+		i, ok := header[column]
+		if !ok {
+			return nil, fmt.Errorf("%w: the file has no %q column", ErrInvalidMapping, column)
+		}
+		columns[field] = i
+	}
+
+	if _, ok := columns[FieldName]; !ok {
+		return nil, fmt.Errorf("%w: no column is mapped to %s", ErrInvalidMapping, FieldName)
+	}
+
+	return &CSVReader{reader: reader, columns: columns}, nil
+}
+
+func (c *CSVReader) Next() (Row, error) {
+
+	record, row, err := nextRecord(c.reader)
+	if err != nil || row.Err != nil {
+		return row, err
+	}
+
+	row.Err = c.readBook(record, &row.Book)
+	return row, nil
+}
+
+// openCSV reads the header of a CSV file. It returns the index of each column by its name,
+// the first one of the columns with the same name, and io.EOF when the file is empty.
+func openCSV(r io.Reader) (*csv.Reader, map[string]int, error) {
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, err
+	}
+
+	columns := make(map[string]int, len(header))
+	for i, column := range header {
+		column = strings.TrimSpace(strings.TrimPrefix(column, "\ufeff"))
+		if _, ok := columns[column]; !ok {
+			columns[column] = i
+		}
+	}
+
+	return reader, columns, nil
+}
+
+// nextRecord reads the next record of a CSV file, with the row it starts. The row
+// has the error of a record that could not be read, and the record is nil then.
+func nextRecord(reader *csv.Reader) ([]string, Row, error) {
+
+	record, err := reader.Read()
+	var parseErr *csv.ParseError
+	if errors.As(err, &parseErr) {
+		return nil, Row{Line: parseErr.StartLine, Err: &ParseError{Err: parseErr.Err}}, nil
+	} else if err != nil {
+		return nil, Row{}, err
+	}
+
+	line, _ := reader.FieldPos(0)
+	return record, Row{Line: line}, nil
+}
+
+// readBook sets the fields of book from the columns of the record
+func (c *CSVReader) readBook(record []string, book *models.Book) error {
+
+	get := func(field string) string {
+		if i, ok := c.columns[field]; ok && i < len(record) {
+			return strings.TrimSpace(record[i])
+		}
+		return ""
+	}
+
+	book.Name = get(FieldName)
+	book.ISBN10 = get(FieldISBN10)
+	book.ISBN13 = get(FieldISBN13)
+	if number := isbn.Normalize(get(FieldISBN)); len(number) == 10 && book.ISBN10 == "" {
+		book.ISBN10 = number
+	} else if number != "" && book.ISBN13 == "" {
+		book.ISBN13 = number
+	}
+
+	for _, name := range splitList(get(FieldAuthors)) {
+		book.Authors = append(book.Authors, models.BookAuthor{Role: models.AuthorRoleAuthor, Author: models.AuthorFromName(name)})
+	}
+	if name := get(FieldPublisher); name != "" {
+		book.Publisher = &models.Publisher{Name: name}
+	}
+	if name := get(FieldCategory); name != "" {
+		book.Category = &models.Category{Name: name}
+	}
+	if name := get(FieldSeries); name != "" {
+		book.Series = &models.Series{Name: name}
+	}
+	for _, name := range splitList(get(FieldTags)) {
+		book.Tags = append(book.Tags, models.Tag{Name: name})
+	}
+	book.Summary = get(FieldSummary)
+	book.TableOfContentsJson = splitList(get(FieldTableOfContents))
+
+	if v := get(FieldVolume); v != "" {
+		volume, err := strconv.Atoi(v)
+		if err != nil {
+			return &ParseError{Field: FieldVolume, Err: errors.New("must be a whole number")}
+		}
+		book.Volumn = volume
+	}
+
+	if v := get(FieldRating); v != "" {
+		rating, err := strconv.Atoi(v)
+		if err != nil {
+			return &ParseError{Field: FieldRating, Err: errors.New("must be a whole number of stars")}
+		}
+		book.Rating = rating
+	}
+
+	if v := get(FieldPublishedAt); v != "" {
+		date, err := parseDate(v)
+		if err != nil {
+			return &ParseError{Field: FieldPublishedAt, Err: err}
+		}
+		book.PublishedAt = date
+	}
+
+	return nil
+}
+
+// parseDate reads a published date as 2006-01-02, 2006/01/02, 2006-01 or 2006
+func parseDate(v string) (time.Time, error) {
+
+	for _, layout := range publishedAtLayouts {
+		if date, err := time.Parse(layout, v); err == nil {
+			return date, nil
+		}
+	}
+
+	return time.Time{}, errors.New("must be a date such as 2006-01-02")
+}
+
+// splitList splits the semicolon separated values of a column
+func splitList(v string) []string {
+
+	var values []string
+	for _, value := range strings.Split(v, ";") {
+		if value = strings.TrimSpace(value); value != "" {
+			values = append(values, value)
+		}
+	}
+
+	return values
+}
+
+// fieldKey is the field a column is mapped to by default, such as isbn_13 for "ISBN 13"
+func fieldKey(column string) string {
+	return strings.NewReplacer(" ", "_", "-", "_").Replace(strings.ToLower(strings.TrimSpace(column)))
+}
+
+func isCSVField(field string) bool {
+
+	for _, f := range csvFields {
+		if f == field {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,153 @@
+package importer
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+)
+
+// The columns of the library export of Goodreads read into the books
+const (
+	goodreadsTitle             = "Title"
+	goodreadsAuthorLastFirst   = "Author l-f"
+	goodreadsAuthor            = "Author"
+	goodreadsAdditionalAuthors = "Additional Authors"
+	goodreadsISBN              = "ISBN"
+	goodreadsISBN13            = "ISBN13"
+	goodreadsMyRating          = "My Rating"
+	goodreadsPublisher         = "Publisher"
+	goodreadsYearPublished     = "Year Published"
+	goodreadsOriginalYear      = "Original Publication Year"
+	goodreadsBookshelves       = "Bookshelves"
+	goodreadsExclusiveShelf    = "Exclusive Shelf"
+)
+
+// goodreadsSeries matches a title ending with its series and volume, such as
+// "The Two Towers (The Lord of the Rings, #2)"
+var goodreadsSeries = regexp.MustCompile(`^(.+?)\s*\(([^()]+?),?\s+#(\d+(?:\.\d+)?)\)$`)
+
+// GoodreadsReader reads the CSV file of the library export of Goodreads. The series
+// and the volume are taken from the end of the titles, the shelves become tags
+// and the rating is the one the user gave the book.
+type GoodreadsReader struct {
+	reader  *csv.Reader
+	columns map[string]int
+}
+
+func NewGoodreadsReader(r io.Reader) (*GoodreadsReader, error) {
+
+	reader, columns, err := openCSV(r)
+	if errors.Is(err, io.EOF) {
+		return nil, fmt.Errorf("%w: the file has no header", ErrInvalidLibrary)
+	} else if err != nil {
+		return nil, err
+	}
+
+	for _, column := range []string{goodreadsTitle, goodreadsExclusiveShelf} {
+		if _, ok := columns[column]; !ok {
+			return nil, fmt.Errorf("%w: the file has no %q column, it is not an export of Goodreads", ErrInvalidLibrary, column)
+		}
+	}
+
+	return &GoodreadsReader{reader: reader, columns: columns}, nil
+}
+
+func (g *GoodreadsReader) Next() (Row, error) {
+
+	record, row, err := nextRecord(g.reader)
+	if err != nil || row.Err != nil {
+		return row, err
+	}
+
+	row.Err = g.readBook(record, &row.Book)
+	return row, nil
+}
+
+// readBook sets the fields of book from the columns of the record
+func (g *GoodreadsReader) readBook(record []string, book *models.Book) error {
+
+	get := func(column string) string {
+		if i, ok := g.columns[column]; ok && i < len(record) {
+			return goodreadsValue(record[i])
+		}
+		return ""
+	}
+
+	book.Name = get(goodreadsTitle)
+	if match := goodreadsSeries.FindStringSubmatch(book.Name); match != nil {
+		book.Name = match[1]
+		book.Series = &models.Series{Name: strings.TrimSpace(match[2])}
+		// a volume between two others, such as #1.5, is left out
+		if volume, err := strconv.Atoi(match[3]); err == nil {
+			book.Volumn = volume
+		}
+	}
+
+	book.ISBN10 = get(goodreadsISBN)
+	book.ISBN13 = get(goodreadsISBN13)
+
+	// the first author is given as "Last, First" too, the others only as "First Last"
+	first := get(goodreadsAuthorLastFirst)
+	if first == "" {
+		first = get(goodreadsAuthor)
+	}
+	for _, name := range append([]string{first}, strings.Split(get(goodreadsAdditionalAuthors), ",")...) {
+		if name = strings.TrimSpace(name); name != "" {
+			book.Authors = append(book.Authors, models.BookAuthor{Role: models.AuthorRoleAuthor, Author: models.AuthorFromName(name)})
+		}
+	}
+
+	if name := get(goodreadsPublisher); name != "" {
+		book.Publisher = &models.Publisher{Name: name}
+	}
+
+	// the shelf the book is on, such as read or to-read, and the shelves the user made
+	shelves := map[string]bool{}
+	for _, name := range append([]string{get(goodreadsExclusiveShelf)}, strings.Split(get(goodreadsBookshelves), ",")...) {
+		if name = strings.TrimSpace(name); name != "" && !shelves[name] {
+			shelves[name] = true
+			book.Tags = append(book.Tags, models.Tag{Name: name})
+		}
+	}
+
+	if v := get(goodreadsMyRating); v != "" {
+		rating, err := strconv.Atoi(v)
+		if err != nil {
+			return &ParseError{Field: goodreadsMyRating, Err: errors.New("must be a whole number of stars")}
+		}
+		book.Rating = rating
+	}
+
+	year := get(goodreadsYearPublished)
+	if year == "" {
+		year = get(goodreadsOriginalYear)
+	}
+	if year != "" {
+		date, err := time.Parse("2006", year)
+		if err != nil {
+			return &ParseError{Field: goodreadsYearPublished, Err: errors.New("must be a year")}
+		}
+		book.PublishedAt = date
+	}
+
+	return nil
+}
+
+// goodreadsValue removes the spaces around a value and the ="..." the ISBNs are
+// written as so that spreadsheets keep them as text
+func goodreadsValue(v string) string {
+
+	v = strings.TrimSpace(v)
+	if strings.HasPrefix(v, `="`) && strings.HasSuffix(v, `"`) && len(v) >= 3 {
+		v = strings.TrimSpace(v[2 : len(v)-1])
+	}
+
+	return v
+}
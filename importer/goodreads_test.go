@@ -0,0 +1,150 @@
+package importer
+
+import (
+	"errors"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+)
+
+func TestGoodreadsSeries(t *testing.T) {
+
+	tests := []struct {
+		title  string
+		name   string
+		series string
+		volume string
+	}{
+		{"Dune (Dune #1)", "Dune", "Dune", "1"},
+		{"The Two Towers (The Lord of the Rings, #2)", "The Two Towers", "The Lord of the Rings", "2"},
+		{"Leviathan Wakes (The Expanse, #1)", "Leviathan Wakes", "The Expanse", "1"},
+		{"The Way of Kings (The Stormlight Archive, #10)", "The Way of Kings", "The Stormlight Archive", "10"},
+		{"Edgedancer (The Stormlight Archive, #2.5)", "Edgedancer", "The Stormlight Archive", "2.5"},
+		{"Harry Potter (Harry Potter) (Harry Potter, #1)", "Harry Potter (Harry Potter)", "Harry Potter", "1"},
+		{"The Hobbit", "", "", ""},
+		{"The Hobbit (Illustrated Edition)", "", "", ""},
+		{"The Lord of the Rings (The Lord of the Rings, #1-3)", "", "", ""},
+		{"(Dune #1)", "", "", ""},
+		{"Dune (Dune #1) and more", "", "", ""},
+	}
+
+	for _, tt := range tests {
+		match := goodreadsSeries.FindStringSubmatch(tt.title)
+		if tt.name == "" {
+			if match != nil {
+				t.Errorf("%q matched as %q", tt.title, match[1:])
+			}
+			continue
+		}
+		if match == nil || match[1] != tt.name || match[2] != tt.series || match[3] != tt.volume {
+			t.Errorf("%q matched as %q, want %q, %q, %q", tt.title, match, tt.name, tt.series, tt.volume)
+		}
+	}
+}
+
+func TestGoodreadsValue(t *testing.T) {
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`="0441172717"`, "0441172717"},
+		{`="9780441172719"`, "9780441172719"},
+		{`=""`, ""},
+		{` =" 0441172717 " `, "0441172717"},
+		{`  Dune  `, "Dune"},
+		{`="`, `="`},
+		{`=0441172717`, "=0441172717"},
+		{`"quoted"`, `"quoted"`},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		if got := goodreadsValue(tt.in); got != tt.want {
+			t.Errorf("goodreadsValue(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestGoodreadsReader(t *testing.T) {
+
+	file, err := os.Open("testdata/goodreads_library_export.csv")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	reader, err := NewGoodreadsReader(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rows := readAll(t, reader)
+
+	author := func(first, last string) models.BookAuthor {
+		return models.BookAuthor{Role: models.AuthorRoleAuthor, Author: models.Author{FirstName: first, LastName: last}}
+	}
+	want := []Row{
+		{Line: 2, Book: models.Book{
+			Name:        "Dune",
+			ISBN10:      "0441172717",
+			ISBN13:      "9780441172719",
+			Authors:     []models.BookAuthor{author("Frank", "Herbert")},
+			Publisher:   &models.Publisher{Name: "Ace Books"},
+			Series:      &models.Series{Name: "Dune"},
+			Volumn:      1,
+			Tags:        []models.Tag{{Name: "read"}, {Name: "favorites"}, {Name: "sci-fi"}},
+			Rating:      5,
+			PublishedAt: time.Date(1990, time.January, 1, 0, 0, 0, 0, time.UTC),
+		}},
+		// the original year when the edition has none
+		{Line: 3, Book: models.Book{
+			Name:        "The Two Towers",
+			Authors:     []models.BookAuthor{author("J.R.R.", "Tolkien")},
+			Publisher:   &models.Publisher{Name: "Mariner Books"},
+			Series:      &models.Series{Name: "The Lord of the Rings"},
+			Volumn:      2,
+			Tags:        []models.Tag{{Name: "to-read"}},
+			PublishedAt: time.Date(1954, time.January, 1, 0, 0, 0, 0, time.UTC),
+		}},
+		// the additional authors, and a shelf that is also the exclusive one
+		{Line: 4, Book: models.Book{
+			Name:        "Structure and Interpretation of Computer Programs",
+			ISBN10:      "0262510871",
+			ISBN13:      "9780262510875",
+			Authors:     []models.BookAuthor{author("Harold", "Abelson"), author("Gerald Jay", "Sussman"), author("Julie", "Sussman")},
+			Publisher:   &models.Publisher{Name: "MIT Press"},
+			Tags:        []models.Tag{{Name: "read"}, {Name: "programming"}},
+			Rating:      4,
+			PublishedAt: time.Date(1996, time.January, 1, 0, 0, 0, 0, time.UTC),
+		}},
+	}
+
+	if len(rows) != 4 {
+		t.Fatalf("%d rows, want 4", len(rows))
+	}
+	for i := range want {
+		if !reflect.DeepEqual(rows[i], want[i]) {
+			t.Errorf("row %d =\n%+v\nwant\n%+v", i, rows[i], want[i])
+		}
+	}
+
+	// a volume between two others is left out, and the year is not a year
+	last := rows[3]
+	var parseErr *ParseError
+	if last.Line != 5 || last.Book.Name != "Unrated" || last.Book.Volumn != 0 || !errors.As(last.Err, &parseErr) || parseErr.Field != goodreadsYearPublished {
+		t.Errorf("row 3 = %+v", last)
+	}
+}
+
+func TestNewGoodreadsReaderInvalid(t *testing.T) {
+
+	for _, file := range []string{"", "name,isbn\nDune,\n", "Title,Author\nDune,Frank Herbert\n"} {
+		if _, err := NewGoodreadsReader(strings.NewReader(file)); !errors.Is(err, ErrInvalidLibrary) {
+			t.Errorf("NewGoodreadsReader(%q) = %v, want %v", file, err, ErrInvalidLibrary)
+		}
+	}
+}
@@ -0,0 +1,296 @@
+// Package importer adds books in bulk from files, such as spreadsheets exported as CSV,
+// JSON Lines of books, the library exports of Goodreads and the libraries of Calibre.
+// The rows are read one by one, validated like the books of POST /api/v1/books and
+// created in batches, each batch in one transaction.
+//
+// The result is a report of every row: created, skipped as a duplicate of a book the
+// owner already has, or failed with the reason. A preview reports what an import
+// would do, with the books read from the file, without creating them.
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db"
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"github.com/Parsa-Sh-Y/book-manager-service/isbn"
+	"github.com/Parsa-Sh-Y/book-manager-service/validation"
+)
+
+// ErrInvalidLibrary The file is not a library of the kind it is read as, such as a Goodreads export
+var ErrInvalidLibrary = errors.New("invalid library file")
+
+// DefaultBatchSize is the number of books created in each transaction by default
+const DefaultBatchSize = 100
+
+// The statuses of the rows in the report
+const (
+	StatusCreated = "created"
+	StatusSkipped = "skipped"
+	StatusFailed  = "failed"
+	// StatusReady is a row of a preview that would be created
+	StatusReady = "ready"
+)
+
+// Row is a book read from a file, or the error of a row that could not be read
+type Row struct {
+	// Line is the line of the file the row starts at, or the id of the book in a Calibre library
+	Line int
+	Book models.Book
+	Err  error
+}
+
+// Reader reads the rows of a file. Next returns io.EOF after the last row,
+// and other errors when the rest of the file can not be read.
+type Reader interface {
+	Next() (Row, error)
+}
+
+// RowResult is what happened to a row
+type RowResult struct {
+	Line   int    `json:"line"`
+	Status string `json:"status"`
+	Name   string `json:"name,omitempty"`
+	// BookID is the id of the book created from the row
+	BookID uint   `json:"book_id,omitempty"`
+	Error  string `json:"error,omitempty"`
+	// Errors are the invalid fields of the book
+	Errors validation.Errors `json:"errors,omitempty"`
+	// Book is the book read from the row, in previews only
+	Book *models.Book `json:"book,omitempty"`
+}
+
+// Report is the result of an import
+type Report struct {
+	// Preview is set when nothing was created, the rows that would be are ready
+	Preview bool        `json:"preview,omitempty"`
+	Ready   int         `json:"ready,omitempty"`
+	Created int         `json:"created"`
+	Skipped int         `json:"skipped"`
+	Failed  int         `json:"failed"`
+	Rows    []RowResult `json:"rows"`
+	// Error is why the import stopped before the end of the file, the rows after it were not read
+	Error string `json:"error,omitempty"`
+}
+
+// Import reads every row of reader and creates the books of the user in batches of
+// batchSize books. A row is a duplicate when the user already has a book with its ISBN,
+// or when an earlier row has it. When the file can not be read to the end or a batch
+// can not be saved, it returns the error with the report of the rows read so far.
+func Import(store db.BookStore, reader Reader, userID uint, batchSize int) (*Report, error) {
+
+	report := &Report{Rows: []RowResult{}}
+	if err := importRows(store, reader, userID, batchSize, report); err != nil {
+		report.Error = err.Error()
+		return report, err
+	}
+
+	return report, nil
+}
+
+func importRows(store db.BookStore, reader Reader, userID uint, batchSize int, report *Report) error {
+
+	if batchSize <= 0 {
+		batchSize = DefaultBatchSize
+	}
+
+	var batch []*models.Book
+	var results []int // the index in report.Rows of each book of the batch
+
+	flush := func() error {
+		if len(batch) == 0 {
+			return nil
+		}
+
+		errs, err := store.CreateBooks(batch)
+		for i := range batch {
+			if err != nil {
+				report.setResult(results[i], batch[i], err)
+			} else {
+				report.setResult(results[i], batch[i], errs[i])
+			}
+		}
+
+		batch, results = nil, nil
+		return err
+	}
+
+	for {
+		row, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		} else if err != nil {
+			// the rows read before are imported all the same
+			if flushErr := flush(); flushErr != nil {
+				return flushErr
+			}
+			return err
+		}
+
+		result := RowResult{Line: row.Line, Name: row.Book.Name}
+		if row.Err == nil {
+			row.Err = prepareBook(&row.Book, userID)
+		}
+		if row.Err != nil {
+			report.add(result, row.Err)
+			continue
+		}
+
+		report.Rows = append(report.Rows, result)
+		book := row.Book
+		batch = append(batch, &book)
+		results = append(results, len(report.Rows)-1)
+
+		if len(batch) == batchSize {
+			if err := flush(); err != nil {
+				return err
+			}
+		}
+	}
+
+	return flush()
+}
+
+// Preview reads every row of reader like Import and reports what importing them for the user
+// would do, without creating the books. The rows that would be created are ready, and every
+// row read has its book. A ready row may still fail when it is imported, such as when it is
+// in a category that does not exist.
+func Preview(store db.BookStore, reader Reader, userID uint) (*Report, error) {
+
+	report := &Report{Preview: true, Rows: []RowResult{}}
+	if err := previewRows(store, reader, userID, report); err != nil {
+		report.Error = err.Error()
+		return report, err
+	}
+
+	return report, nil
+}
+
+func previewRows(store db.BookStore, reader Reader, userID uint, report *Report) error {
+
+	// the ISBN-13 of the rows read, a later row with one of them is a duplicate
+	seen := make(map[string]bool)
+
+	for {
+		row, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return nil
+		} else if err != nil {
+			return err
+		}
+
+		result := RowResult{Line: row.Line, Name: row.Book.Name}
+		if row.Err == nil {
+			row.Err = prepareBook(&row.Book, userID)
+			book := row.Book
+			result.Book = &book
+		}
+		if row.Err != nil {
+			report.add(result, row.Err)
+			continue
+		}
+
+		duplicate := false
+		if number := bookISBN13(&row.Book); number != "" {
+			duplicate = seen[number]
+			seen[number] = true
+			if !duplicate {
+				_, err := store.GetUserBookByISBN(userID, number)
+				if err != nil && !errors.Is(err, db.ErrBookNotFound) {
+					return err
+				}
+				duplicate = err == nil
+			}
+		}
+
+		if duplicate {
+			report.add(result, db.ErrISBNIsInUse)
+			continue
+		}
+		result.Status = StatusReady
+		report.Rows = append(report.Rows, result)
+		report.Ready++
+	}
+}
+
+// bookISBN13 returns the ISBN-13 of the book, or the one of its ISBN-10, empty when it has no ISBN
+func bookISBN13(book *models.Book) string {
+
+	for _, number := range []string{book.ISBN13, book.ISBN10} {
+		if isbn13, err := isbn.To13(number); err == nil {
+			return isbn13
+		}
+	}
+
+	return ""
+}
+
+// prepareBook makes the book of a row ready to be created by the user, like the body of POST /api/v1/books
+func prepareBook(book *models.Book, userID uint) error {
+
+	book.ID = 0
+	book.UserID = userID
+	if err := validation.Validate(book); err != nil {
+		return err
+	}
+
+	if len(book.TableOfContents) == 0 {
+		book.TableOfContents = models.ContentsFromNames(book.TableOfContentsJson)
+	}
+
+	return nil
+}
+
+// add adds the result of a row that failed before it was created
+func (r *Report) add(result RowResult, err error) {
+
+	r.Rows = append(r.Rows, result)
+	r.setResult(len(r.Rows)-1, nil, err)
+}
+
+// setResult sets the status of the row at index from the error of creating its book
+func (r *Report) setResult(index int, book *models.Book, err error) {
+
+	result := &r.Rows[index]
+	var fieldErrors validation.Errors
+	switch {
+	case err == nil:
+		result.Status = StatusCreated
+		result.BookID = book.ID
+		r.Created++
+	case errors.Is(err, db.ErrISBNIsInUse):
+		result.Status = StatusSkipped
+		result.Error = "the user already has a book with the isbn"
+		r.Skipped++
+	case errors.As(err, &fieldErrors):
+		result.Status = StatusFailed
+		result.Error = "the book has invalid fields"
+		result.Errors = fieldErrors
+		r.Failed++
+	default:
+		result.Status = StatusFailed
+		result.Error = err.Error()
+		r.Failed++
+	}
+}
+
+// ParseError is a row that could not be read
+type ParseError struct {
+	Field string
+	Err   error
+}
+
+func (e *ParseError) Error() string {
+
+	if e.Field == "" {
+		return e.Err.Error()
+	}
+
+	return fmt.Sprintf("%s: %v", e.Field, e.Err)
+}
+
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,418 @@
+package importer
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/config"
+	"github.com/Parsa-Sh-Y/book-manager-service/db"
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+)
+
+// newStores returns an empty MemoryDB and GormDB on an in-memory sqlite database,
+// both with the user 1 and a Fiction category
+func newStores(t *testing.T) map[string]db.Store {
+
+	t.Helper()
+	var conf config.Config
+	conf.Database.Driver, conf.Database.Path = db.DriverSQLite, db.SQLiteInMemory
+	gormDB, err := db.CreateNewGormDB(conf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := gormDB.CreateSchema(); err != nil {
+		t.Fatal(err)
+	}
+
+	stores := map[string]db.Store{"memory": db.CreateNewMemoryDB(), "gorm": gormDB}
+	for _, store := range stores {
+		if err := store.CreateUser(&models.User{Username: "ali", Email: "ali@example.com"}); err != nil {
+			t.Fatal(err)
+		}
+		if err := store.CreateCategory(&models.Category{Name: "Fiction"}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return stores
+}
+
+// statuses returns the line and the status of each row of the report
+func statuses(report *Report) []string {
+
+	var rows []string
+	for _, row := range report.Rows {
+		rows = append(rows, fmt.Sprintf("%d:%s", row.Line, row.Status))
+	}
+	return rows
+}
+
+func csvReader(t *testing.T, file string, mapping Mapping) *CSVReader {
+
+	t.Helper()
+	reader, err := NewCSVReader(strings.NewReader(file), mapping)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reader
+}
+
+// readAll returns the rows of the reader
+func readAll(t *testing.T, reader Reader) []Row {
+
+	t.Helper()
+	var rows []Row
+	for {
+		row, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			return rows
+		} else if err != nil {
+			t.Fatal(err)
+		}
+		rows = append(rows, row)
+	}
+}
+
+func TestParseMapping(t *testing.T) {
+
+	tests := []struct {
+		pairs   []string
+		want    Mapping
+		wantErr bool
+	}{
+		{nil, Mapping{}, false},
+		{[]string{"name:Title", " Authors : Author(s) "}, Mapping{"name": "Title", "authors": "Author(s)"}, false},
+		{[]string{"name:Title: a Subtitle"}, Mapping{"name": "Title: a Subtitle"}, false},
+		{[]string{"title:Title"}, nil, true},
+		{[]string{"name"}, nil, true},
+		{[]string{"name: "}, nil, true},
+	}
+
+	for _, tt := range tests {
+		got, err := ParseMapping(tt.pairs)
+		if (err != nil) != tt.wantErr || !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("ParseMapping(%q) = %v, %v, want %v", tt.pairs, got, err, tt.want)
+		}
+		if tt.wantErr && !errors.Is(err, ErrInvalidMapping) {
+			t.Errorf("ParseMapping(%q) = %v, want %v", tt.pairs, err, ErrInvalidMapping)
+		}
+	}
+}
+
+func TestNewCSVReaderErrors(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		file    string
+		mapping Mapping
+	}{
+		{"empty file", "", nil},
+		{"no name column", "Title,ISBN\n", nil},
+		{"mapped column missing", "name,ISBN\n", Mapping{FieldAuthors: "Author"}},
+	}
+
+	for _, tt := range tests {
+		if _, err := NewCSVReader(strings.NewReader(tt.file), tt.mapping); !errors.Is(err, ErrInvalidMapping) {
+			t.Errorf("%s: NewCSVReader() = %v, want %v", tt.name, err, ErrInvalidMapping)
+		}
+	}
+}
+
+func TestCSVReader(t *testing.T) {
+
+	// the header has a BOM, a column read by its name, a column mapped to a field and
+	// two columns read into the same field, the leftmost one is read
+	file := "\ufeffTitle,ISBN 13,Author(s),Tags,isbn_13,Volume,Published At,Rating,Table of Contents\n" +
+		`Dune,978-0-441-17271-9,"Herbert, Frank; Brian Herbert",sci-fi; ;classic,9780000000000,1,1965-08,5,Book One;Book Two` + "\n" +
+		"Emma,,Jane Austen,,,one,,,\n" +
+		"Emma,,,,,,the 1815,,\n" +
+		"Emma,,,,,,,five,\n" +
+		"Persuasion\n" +
+		`"Bad "quote",` + "\n"
+
+	rows := readAll(t, csvReader(t, file, Mapping{FieldName: "Title", FieldAuthors: "Author(s)"}))
+	if len(rows) != 6 {
+		t.Fatalf("%d rows, want 6", len(rows))
+	}
+
+	want := models.Book{
+		Name:   "Dune",
+		ISBN13: "978-0-441-17271-9",
+		Authors: []models.BookAuthor{
+			{Role: models.AuthorRoleAuthor, Author: models.Author{FirstName: "Frank", LastName: "Herbert"}},
+			{Role: models.AuthorRoleAuthor, Author: models.Author{FirstName: "Brian", LastName: "Herbert"}},
+		},
+		Tags:                []models.Tag{{Name: "sci-fi"}, {Name: "classic"}},
+		Volumn:              1,
+		PublishedAt:         time.Date(1965, time.August, 1, 0, 0, 0, 0, time.UTC),
+		Rating:              5,
+		TableOfContentsJson: []string{"Book One", "Book Two"},
+	}
+	if rows[0].Err != nil || !reflect.DeepEqual(rows[0].Book, want) {
+		t.Errorf("row 0 = %+v, %v\nwant %+v", rows[0].Book, rows[0].Err, want)
+	}
+
+	wantErrs := []struct {
+		line  int
+		field string
+	}{
+		{3, FieldVolume},
+		{4, FieldPublishedAt},
+		{5, FieldRating},
+		{6, ""},
+		{7, ""},
+	}
+	for i, want := range wantErrs {
+		row := rows[i+1]
+		var parseErr *ParseError
+		switch {
+		case want.line == 6:
+			// a short row is read with the columns it has
+			if row.Err != nil || row.Book.Name != "Persuasion" {
+				t.Errorf("line 6 = %+v, %v", row.Book, row.Err)
+			}
+		case row.Line != want.line || !errors.As(row.Err, &parseErr) || parseErr.Field != want.field:
+			t.Errorf("row %d = line %d, %v, want line %d with an error of %q", i+1, row.Line, row.Err, want.line, want.field)
+		}
+	}
+}
+
+func TestCSVReaderISBNColumn(t *testing.T) {
+
+	// the isbn field takes either form
+	rows := readAll(t, csvReader(t, "name,isbn\nDune,978-0-441-17271-9\nEmma,0-14-143958-1\n", nil))
+	if rows[0].Book.ISBN13 != "9780441172719" || rows[1].Book.ISBN10 != "0141439581" {
+		t.Errorf("books = %+v", rows)
+	}
+}
+
+func TestJSONLinesReader(t *testing.T) {
+
+	file := `{"name": "Dune", "isbn_13": "9780441172719", "rating": 5}` + "\n\n  \n" + `{"name": ` + "\n" + `{"name": "Emma"}`
+	rows := readAll(t, NewJSONLinesReader(strings.NewReader(file)))
+
+	if len(rows) != 3 {
+		t.Fatalf("%d rows, want 3", len(rows))
+	}
+	if rows[0].Line != 1 || rows[0].Book.Name != "Dune" || rows[0].Book.Rating != 5 {
+		t.Errorf("row 0 = %+v", rows[0])
+	}
+	var parseErr *ParseError
+	if rows[1].Line != 4 || !errors.As(rows[1].Err, &parseErr) {
+		t.Errorf("row 1 = line %d, %v, want line 4 with a parse error", rows[1].Line, rows[1].Err)
+	}
+	if rows[2].Line != 5 || rows[2].Book.Name != "Emma" {
+		t.Errorf("row 2 = %+v", rows[2])
+	}
+}
+
+// importFile is a CSV file with a row of each outcome of an import
+const importFile = `name,isbn,category,rating,volume
+Dune,978-0-441-17271-9,Fiction,5,
+Dune again,0441172717,,,
+Emma,9780141439587,Poetry,,
+Persuasion,,,9,
+Mansfield Park,,,,first
+Sense and Sensibility,9780141439662,,,
+Pride and Prejudice,9780141439518,Fiction,4,2
+`
+
+func TestImport(t *testing.T) {
+
+	for name, store := range newStores(t) {
+		t.Run(name, func(t *testing.T) {
+			// the user already has Sense and Sensibility
+			existing := models.Book{Name: "Sense and Sensibility", ISBN13: "9780141439662", UserID: 1}
+			if err := store.CreateBook(&existing); err != nil {
+				t.Fatal(err)
+			}
+
+			report, err := Import(store, csvReader(t, importFile, nil), 1, 2)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			want := []string{"2:created", "3:skipped", "4:failed", "5:failed", "6:failed", "7:skipped", "8:created"}
+			if got := statuses(report); !reflect.DeepEqual(got, want) {
+				t.Errorf("rows = %v, want %v", got, want)
+			}
+			if report.Created != 2 || report.Skipped != 2 || report.Failed != 3 || report.Error != "" {
+				t.Errorf("report = %d created, %d skipped, %d failed, %q", report.Created, report.Skipped, report.Failed, report.Error)
+			}
+
+			rows := report.Rows
+			if !strings.Contains(rows[2].Error, db.ErrUnknownCategory.Error()) {
+				t.Errorf("error of the unknown category = %q", rows[2].Error)
+			}
+			if len(rows[3].Errors) != 1 || rows[3].Errors[0].Field != "rating" {
+				t.Errorf("errors of the invalid rating = %v", rows[3].Errors)
+			}
+			if rows[4].Error != "volume: must be a whole number" {
+				t.Errorf("error of the invalid volume = %q", rows[4].Error)
+			}
+
+			book, err := store.GetBook(int(rows[6].BookID))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if book.Name != "Pride and Prejudice" || book.UserID != 1 || book.ISBN10 != "0141439513" ||
+				book.Category == nil || book.Category.Name != "Fiction" || book.Rating != 4 || book.Volumn != 2 {
+				t.Errorf("created book = %+v", book)
+			}
+		})
+	}
+}
+
+// batchStore records the size of the batches of CreateBooks
+type batchStore struct {
+	db.BookStore
+	batches []int
+}
+
+func (b *batchStore) CreateBooks(books []*models.Book) ([]error, error) {
+
+	b.batches = append(b.batches, len(books))
+	return b.BookStore.CreateBooks(books)
+}
+
+func TestImportBatches(t *testing.T) {
+
+	file := "name\nA\nB\nC\n\"bad\"row\"\nD\nE\n"
+
+	tests := []struct {
+		batchSize int
+		want      []int
+	}{
+		{1, []int{1, 1, 1, 1, 1}},
+		{2, []int{2, 2, 1}},
+		{5, []int{5}},
+		{0, []int{5}},
+		{-1, []int{5}},
+	}
+
+	for _, tt := range tests {
+		store := &batchStore{BookStore: db.CreateNewMemoryDB()}
+		report, err := Import(store, csvReader(t, file, nil), 1, tt.batchSize)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(store.batches, tt.want) || report.Created != 5 || report.Failed != 1 {
+			t.Errorf("batch size %d: batches %v with %d created, want %v", tt.batchSize, store.batches, report.Created, tt.want)
+		}
+	}
+}
+
+func TestImportPartialBatch(t *testing.T) {
+
+	// the second book creates its publisher before its category is found unknown,
+	// only that book is rolled back, to the savepoint before it
+	file := "name,publisher,category\nDune,Ace Books,Fiction\nEmma,Ghost Press,Poetry\nPersuasion,Penguin,\n"
+
+	for name, store := range newStores(t) {
+		t.Run(name, func(t *testing.T) {
+			report, err := Import(store, csvReader(t, file, nil), 1, 3)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if want := []string{"2:created", "3:failed", "4:created"}; !reflect.DeepEqual(statuses(report), want) {
+				t.Errorf("rows = %v, want %v", statuses(report), want)
+			}
+
+			publishers, _, err := store.GetAllPublishers(db.PublisherQuery{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			var names []string
+			for _, publisher := range publishers {
+				names = append(names, publisher.Name)
+			}
+			if want := []string{"Ace Books", "Penguin"}; !reflect.DeepEqual(names, want) {
+				t.Errorf("publishers = %v, want %v", names, want)
+			}
+
+			books, total, err := store.GetAllBooks(db.BookQuery{})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if total != 2 || (*books)[0].Name != "Dune" || (*books)[1].Name != "Persuasion" {
+				t.Errorf("%d books created: %+v", total, *books)
+			}
+		})
+	}
+}
+
+// failingReader returns its rows, then err
+type failingReader struct {
+	rows []Row
+	err  error
+}
+
+func (f *failingReader) Next() (Row, error) {
+
+	if len(f.rows) == 0 {
+		return Row{}, f.err
+	}
+	row := f.rows[0]
+	f.rows = f.rows[1:]
+	return row, nil
+}
+
+func TestImportReadError(t *testing.T) {
+
+	errRead := errors.New("connection reset")
+	reader := &failingReader{rows: []Row{{Line: 1, Book: models.Book{Name: "A"}}, {Line: 2, Book: models.Book{Name: "B"}}}, err: errRead}
+
+	store := &batchStore{BookStore: db.CreateNewMemoryDB()}
+	report, err := Import(store, reader, 1, 10)
+	if !errors.Is(err, errRead) {
+		t.Fatalf("Import() = %v, want %v", err, errRead)
+	}
+
+	// the rows read before the error are created
+	if report.Created != 2 || report.Error != errRead.Error() || !reflect.DeepEqual(store.batches, []int{2}) {
+		t.Errorf("report = %d created, %q with batches %v", report.Created, report.Error, store.batches)
+	}
+}
+
+func TestPreview(t *testing.T) {
+
+	store := db.CreateNewMemoryDB()
+	if err := store.CreateCategory(&models.Category{Name: "Fiction"}); err != nil {
+		t.Fatal(err)
+	}
+	existing := models.Book{Name: "Sense and Sensibility", ISBN13: "9780141439662", UserID: 1}
+	if err := store.CreateBook(&existing); err != nil {
+		t.Fatal(err)
+	}
+
+	report, err := Preview(store, csvReader(t, importFile, nil), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the unknown category is only found when the book is created
+	want := []string{"2:ready", "3:skipped", "4:ready", "5:failed", "6:failed", "7:skipped", "8:ready"}
+	if got := statuses(report); !reflect.DeepEqual(got, want) {
+		t.Errorf("rows = %v, want %v", got, want)
+	}
+	if !report.Preview || report.Ready != 3 || report.Created != 0 || report.Skipped != 2 || report.Failed != 2 {
+		t.Errorf("report = %+v", report)
+	}
+
+	if book := report.Rows[0].Book; book == nil || book.Name != "Dune" || book.UserID != 1 || book.Rating != 5 {
+		t.Errorf("book of the first row = %+v", book)
+	}
+	if report.Rows[4].Book != nil {
+		t.Errorf("row that could not be read has the book %+v", report.Rows[4].Book)
+	}
+
+	if _, total, _ := store.GetAllBooks(db.BookQuery{}); total != 1 {
+		t.Errorf("the preview created %d books", total-1)
+	}
+}
@@ -0,0 +1,54 @@
+package importer
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+)
+
+// maxLineSize is the longest line of a JSON Lines file
+const maxLineSize = 1 << 20
+
+// JSONLinesReader reads a JSON Lines file with a book on each line, in the
+// format of the body of POST /api/v1/books. Empty lines are skipped.
+type JSONLinesReader struct {
+	scanner *bufio.Scanner
+	line    int
+}
+
+func NewJSONLinesReader(r io.Reader) *JSONLinesReader {
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxLineSize)
+
+	return &JSONLinesReader{scanner: scanner}
+}
+
+func (j *JSONLinesReader) Next() (Row, error) {
+
+	for j.scanner.Scan() {
+		j.line++
+		data := bytes.TrimSpace(j.scanner.Bytes())
+		if len(data) == 0 {
+			continue
+		}
+
+		row := Row{Line: j.line}
+		var book models.Book
+		if err := json.Unmarshal(data, &book); err != nil {
+			row.Err = &ParseError{Err: err}
+		} else {
+			row.Book = book
+		}
+		return row, nil
+	}
+
+	if err := j.scanner.Err(); err != nil {
+		return Row{}, err
+	}
+
+	return Row{}, io.EOF
+}
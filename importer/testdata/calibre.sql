@@ -0,0 +1,67 @@
+-- The tables of the metadata.db of a Calibre library read by CalibreReader, with a few books.
+-- metadata.db is built from it with: sqlite3 metadata.db < calibre.sql
+PRAGMA page_size = 1024;
+CREATE TABLE books (
+	id INTEGER PRIMARY KEY AUTOINCREMENT,
+	title TEXT NOT NULL DEFAULT 'Unknown' COLLATE NOCASE,
+	sort TEXT COLLATE NOCASE,
+	timestamp TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	pubdate TIMESTAMP DEFAULT CURRENT_TIMESTAMP,
+	series_index REAL NOT NULL DEFAULT 1.0,
+	author_sort TEXT COLLATE NOCASE,
+	isbn TEXT DEFAULT '' COLLATE NOCASE,
+	lccn TEXT DEFAULT '' COLLATE NOCASE,
+	path TEXT NOT NULL DEFAULT '',
+	has_cover BOOL DEFAULT 0,
+	uuid TEXT,
+	last_modified TIMESTAMP NOT NULL DEFAULT '2000-01-01 00:00:00+00:00'
+);
+CREATE TABLE authors (id INTEGER PRIMARY KEY, name TEXT NOT NULL COLLATE NOCASE, sort TEXT COLLATE NOCASE, link TEXT NOT NULL DEFAULT '', UNIQUE(name));
+CREATE TABLE books_authors_link (id INTEGER PRIMARY KEY, book INTEGER NOT NULL, author INTEGER NOT NULL, UNIQUE(book, author));
+CREATE TABLE publishers (id INTEGER PRIMARY KEY, name TEXT NOT NULL COLLATE NOCASE, sort TEXT COLLATE NOCASE, UNIQUE(name));
+CREATE TABLE books_publishers_link (id INTEGER PRIMARY KEY, book INTEGER NOT NULL, publisher INTEGER NOT NULL, UNIQUE(book));
+CREATE TABLE series (id INTEGER PRIMARY KEY, name TEXT NOT NULL COLLATE NOCASE, sort TEXT COLLATE NOCASE, link TEXT NOT NULL DEFAULT '', UNIQUE(name));
+CREATE TABLE books_series_link (id INTEGER PRIMARY KEY, book INTEGER NOT NULL, series INTEGER NOT NULL, UNIQUE(book));
+CREATE TABLE tags (id INTEGER PRIMARY KEY, name TEXT NOT NULL COLLATE NOCASE, link TEXT NOT NULL DEFAULT '', UNIQUE(name));
+CREATE TABLE books_tags_link (id INTEGER PRIMARY KEY, book INTEGER NOT NULL, tag INTEGER NOT NULL, UNIQUE(book, tag));
+CREATE TABLE ratings (id INTEGER PRIMARY KEY, rating INTEGER CHECK(rating > -1 AND rating < 11), link TEXT NOT NULL DEFAULT '', UNIQUE(rating));
+CREATE TABLE books_ratings_link (id INTEGER PRIMARY KEY, book INTEGER NOT NULL, rating INTEGER NOT NULL, UNIQUE(book, rating));
+CREATE TABLE identifiers (id INTEGER PRIMARY KEY, book INTEGER NOT NULL, type TEXT NOT NULL DEFAULT 'isbn' COLLATE NOCASE, val TEXT NOT NULL COLLATE NOCASE, UNIQUE(book, type));
+CREATE TABLE comments (id INTEGER PRIMARY KEY, book INTEGER NOT NULL, text TEXT NOT NULL COLLATE NOCASE, UNIQUE(book));
+
+INSERT INTO books (id, title, pubdate, series_index, isbn) VALUES
+	(1, 'Dune', '1990-09-01 04:00:00+00:00', 1.0, ''),
+	(2, 'The Two Towers', '0101-01-01 00:00:00+00:00', 2.0, '0-618-00223-5'),
+	(3, '  Untitled Notes  ', '0101-01-01 00:00:00+00:00', 1.5, ''),
+	(5, 'Structure and Interpretation of Computer Programs', '1996-07-25 00:00:00+00:00', 1.0, '');
+
+INSERT INTO authors (id, name, sort) VALUES
+	(1, 'Frank Herbert', 'Herbert, Frank'),
+	(2, 'J. R. R. Tolkien', 'Tolkien, J. R. R.'),
+	(3, 'Unknown', 'Unknown'),
+	(4, 'Harold Abelson', 'Abelson, Harold'),
+	(5, 'Gerald Jay Sussman', 'Gerald Jay Sussman'),
+	(6, 'Sussman| Julie', 'Sussman, Julie');
+INSERT INTO books_authors_link (id, book, author) VALUES
+	(1, 1, 1), (2, 2, 2), (3, 3, 3), (4, 5, 4), (5, 5, 5), (6, 5, 6), (7, 9, 1);
+
+INSERT INTO publishers (id, name) VALUES (1, 'Ace Books'), (2, 'MIT Press');
+INSERT INTO books_publishers_link (id, book, publisher) VALUES (1, 1, 1), (2, 5, 2);
+
+INSERT INTO series (id, name) VALUES (1, 'Dune'), (2, 'The Lord of the Rings'), (3, 'Notes');
+INSERT INTO books_series_link (id, book, series) VALUES (1, 1, 1), (2, 2, 2), (3, 3, 3);
+
+INSERT INTO tags (id, name) VALUES (1, 'Science Fiction'), (2, 'Fantasy'), (3, 'Classic');
+INSERT INTO books_tags_link (id, book, tag) VALUES (1, 1, 1), (2, 1, 3), (3, 2, 2);
+
+INSERT INTO ratings (id, rating) VALUES (1, 10), (2, 7), (3, 1), (4, 0);
+INSERT INTO books_ratings_link (id, book, rating) VALUES (1, 1, 1), (2, 2, 2), (3, 5, 3), (4, 3, 4);
+
+INSERT INTO identifiers (id, book, type, val) VALUES
+	(1, 1, 'isbn', '9780441172719'),
+	(2, 1, 'goodreads', '234225'),
+	(3, 5, 'isbn', '0-262-51087-1');
+
+INSERT INTO comments (id, book, text) VALUES
+	(1, 1, '<div><p>Set on the desert planet <em>Arrakis</em>,&nbsp;Dune is the story of Paul&nbsp;Atreides.</p><p>A  classic<br/>of science fiction &amp; more.</p></div>'),
+	(2, 2, '<p>The second volume.</p>');
@@ -0,0 +1,134 @@
+// Package isbn checks and converts International Standard Book Numbers.
+//
+// An ISBN-10 is nine digits and a check digit, which is X for 10. An ISBN-13
+// is twelve digits starting with 978 or 979 and a check digit, other EAN-13
+// barcodes are not ISBNs. The ISBN-10 of a book is also an ISBN-13
+// with the 978 prefix and a new check digit, while the ISBN-13 starting with
+// 979 have no ISBN-10. The numbers may be written with hyphens or spaces
+// between their groups, such as 978-0-441-17271-9.
+package isbn
+
+import (
+	"errors"
+	"strings"
+)
+
+var (
+	// ErrInvalid The string is not a valid ISBN-10 or ISBN-13
+	ErrInvalid = errors.New("not a valid ISBN-10 or ISBN-13")
+	// ErrNoISBN10 The ISBN-13 starts with 979 and has no ISBN-10
+	ErrNoISBN10 = errors.New("the ISBN-13 has no ISBN-10")
+)
+
+const (
+	// bookland is the prefix of the ISBN-13 made from the ISBN-10
+	bookland = "978"
+	// musicland is the other prefix of the ISBN-13, the ones with no ISBN-10
+	musicland = "979"
+)
+
+// Normalize removes the hyphens and the spaces of s and writes
+// the X check digit of an ISBN-10 in upper case. It does not check s.
+func Normalize(s string) string {
+
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '-' || r == ' ':
+			return -1
+		case r == 'x':
+			return 'X'
+		}
+		return r
+	}, strings.TrimSpace(s))
+}
+
+// Valid10 reports whether s, normalized, is an ISBN-10 with a correct check digit
+func Valid10(s string) bool {
+
+	s = Normalize(s)
+	if len(s) != 10 || !digits(s[:9]) {
+		return false
+	}
+
+	return s[9] == checkDigit10(s[:9])
+}
+
+// Valid13 reports whether s, normalized, is an ISBN-13 with a correct check digit
+func Valid13(s string) bool {
+
+	s = Normalize(s)
+	if len(s) != 13 || !digits(s) {
+		return false
+	}
+	if !strings.HasPrefix(s, bookland) && !strings.HasPrefix(s, musicland) {
+		return false
+	}
+
+	return s[12] == checkDigit13(s[:12])
+}
+
+// To13 returns the ISBN-13 of an ISBN-10 or of an ISBN-13, normalized
+func To13(s string) (string, error) {
+
+	s = Normalize(s)
+	switch {
+	case Valid13(s):
+		return s, nil
+	case Valid10(s):
+		return bookland + s[:9] + string(checkDigit13(bookland+s[:9])), nil
+	}
+
+	return "", ErrInvalid
+}
+
+// To10 returns the ISBN-10 of an ISBN-13 or of an ISBN-10, normalized.
+// It returns ErrNoISBN10 for a valid ISBN-13 starting with 979.
+func To10(s string) (string, error) {
+
+	s = Normalize(s)
+	switch {
+	case Valid10(s):
+		return s, nil
+	case Valid13(s):
+		if !strings.HasPrefix(s, bookland) {
+			return "", ErrNoISBN10
+		}
+		return s[3:12] + string(checkDigit10(s[3:12])), nil
+	}
+
+	return "", ErrInvalid
+}
+
+// checkDigit10 returns the check digit of the first nine digits of an ISBN-10
+func checkDigit10(s string) byte {
+
+	sum := 0
+	for i := 0; i < 9; i++ {
+		sum += (10 - i) * int(s[i]-'0')
+	}
+
+	check := (11 - sum%11) % 11
+	if check == 10 {
+		return 'X'
+	}
+	return byte('0' + check)
+}
+
+// checkDigit13 returns the check digit of the first twelve digits of an ISBN-13
+func checkDigit13(s string) byte {
+
+	sum := 0
+	for i := 0; i < 12; i++ {
+		weight := 1
+		if i%2 == 1 {
+			weight = 3
+		}
+		sum += weight * int(s[i]-'0')
+	}
+
+	return byte('0' + (10-sum%10)%10)
+}
+
+func digits(s string) bool {
+	return strings.IndexFunc(s, func(r rune) bool { return r < '0' || r > '9' }) == -1
+}
@@ -0,0 +1,129 @@
+package isbn
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalize(t *testing.T) {
+
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{"9780441172719", "9780441172719"},
+		{"978-0-441-17271-9", "9780441172719"},
+		{" 978 0 441 17271 9 ", "9780441172719"},
+		{"0-8044-2957-x", "080442957X"},
+		{"", ""},
+		// Normalize does not check the number
+		{"not-an-isbn", "notanisbn"},
+	}
+
+	for _, tt := range tests {
+		if got := Normalize(tt.in); got != tt.want {
+			t.Errorf("Normalize(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValid10(t *testing.T) {
+
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"0441172717", true},
+		{"0-441-17271-7", true},
+		{"080442957X", true},
+		{"080442957x", true},
+		{"0-8044-2957-X", true},
+		{"0441172718", false},  // wrong check digit
+		{"0804429570", false},  // X is the check digit
+		{"X804429570", false},  // X only as the check digit
+		{"044117271", false},   // too short
+		{"04411727177", false}, // too long
+		{"9780441172719", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := Valid10(tt.in); got != tt.want {
+			t.Errorf("Valid10(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestValid13(t *testing.T) {
+
+	tests := []struct {
+		in   string
+		want bool
+	}{
+		{"9780441172719", true},
+		{"978-0-441-17271-9", true},
+		{"9791032300824", true},
+		{"9780441172710", false}, // wrong check digit
+		{"4006381333931", false}, // an EAN-13 with a correct check digit, not an ISBN
+		{"978044117271X", false},
+		{"978044117271", false},
+		{"0441172717", false},
+		{"", false},
+	}
+
+	for _, tt := range tests {
+		if got := Valid13(tt.in); got != tt.want {
+			t.Errorf("Valid13(%q) = %v, want %v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestTo13(t *testing.T) {
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr error
+	}{
+		{"0441172717", "9780441172719", nil},
+		{"0-441-17271-7", "9780441172719", nil},
+		{"080442957X", "9780804429573", nil},
+		{"978-0-441-17271-9", "9780441172719", nil},
+		{"9791032300824", "9791032300824", nil},
+		{"4006381333931", "", ErrInvalid},
+		{"0441172718", "", ErrInvalid},
+		{"", "", ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		got, err := To13(tt.in)
+		if got != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("To13(%q) = %q, %v, want %q, %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
+
+func TestTo10(t *testing.T) {
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr error
+	}{
+		{"9780441172719", "0441172717", nil},
+		{"978-0-8044-2957-3", "080442957X", nil},
+		{"0441172717", "0441172717", nil},
+		{"0-8044-2957-x", "080442957X", nil},
+		{"9791032300824", "", ErrNoISBN10},
+		{"9791032300820", "", ErrInvalid},
+		{"4006381333931", "", ErrInvalid},
+		{"", "", ErrInvalid},
+	}
+
+	for _, tt := range tests {
+		got, err := To10(tt.in)
+		if got != tt.want || !errors.Is(err, tt.wantErr) {
+			t.Errorf("To10(%q) = %q, %v, want %q, %v", tt.in, got, err, tt.want, tt.wantErr)
+		}
+	}
+}
@@ -1,10 +1,22 @@
 package main
 
 import (
+	"errors"
+	"flag"
+	"fmt"
 	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"text/tabwriter"
 
+	"github.com/Parsa-Sh-Y/book-manager-service/auth"
 	"github.com/Parsa-Sh-Y/book-manager-service/config"
+	"github.com/Parsa-Sh-Y/book-manager-service/db"
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
 	"github.com/Parsa-Sh-Y/book-manager-service/handlers"
+	"github.com/Parsa-Sh-Y/book-manager-service/importer"
 	"github.com/ilyakaznacheev/cleanenv"
 )
 
@@ -13,13 +25,258 @@ func main() {
 	var cfg config.Config
 	cleanenv.ReadEnv(&cfg)
 
+	if len(os.Args) > 1 && os.Args[1] == "migrate" {
+		if err := migrate(cfg, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "user" {
+		if err := user(cfg, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
+	if len(os.Args) > 1 && os.Args[1] == "import" {
+		if err := importBooks(cfg, os.Args[2:]); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
+
 	server := handlers.CreateNewServer(cfg)
 
 	http.HandleFunc("/api/v1/auth/signup", server.HandleSignup)
 	http.HandleFunc("/api/v1/auth/login", server.HandleLogin)
-	http.HandleFunc("/api/v1/books", server.HandleBooksRoot)
-	http.HandleFunc("/api/v1/books/", server.HandleBooksSubtree)
+	http.HandleFunc("/api/v1/auth/refresh", server.HandleRefresh)
+	http.HandleFunc("/api/v1/auth/logout", server.Authenticate(server.HandleLogout))
+	http.HandleFunc("/api/v1/books", server.Authenticate(server.HandleBooksRoot))
+	http.HandleFunc("/api/v1/books/", server.Authenticate(server.HandleBooksSubtree))
+	http.HandleFunc("/api/v1/books/search", server.Authenticate(server.HandleSearchBooks))
+	http.HandleFunc("/api/v1/books/lookup", server.Authenticate(server.HandleLookupBook))
+	http.HandleFunc("/api/v1/books/import", server.Authenticate(server.HandleImportBooks))
+	http.HandleFunc("/api/v1/books/export", server.Authenticate(server.HandleExportBooks))
+	http.HandleFunc("/api/v1/authors", server.Authenticate(server.HandleAuthorsRoot))
+	http.HandleFunc("/api/v1/authors/", server.Authenticate(server.HandleAuthorsSubtree))
+	http.HandleFunc("/api/v1/publishers", server.Authenticate(server.HandlePublishersRoot))
+	http.HandleFunc("/api/v1/publishers/", server.Authenticate(server.HandlePublishersSubtree))
+	http.HandleFunc("/api/v1/categories", server.Authenticate(server.HandleCategoriesRoot))
+	http.HandleFunc("/api/v1/categories/", server.Authenticate(server.HandleCategoriesSubtree))
+	http.HandleFunc("/api/v1/tags", server.Authenticate(server.HandleTagsRoot))
+	http.HandleFunc("/api/v1/tags/", server.Authenticate(server.HandleTagsSubtree))
+	http.HandleFunc("/api/v1/series", server.Authenticate(server.HandleSeriesRoot))
+	http.HandleFunc("/api/v1/series/", server.Authenticate(server.HandleSeriesSubtree))
+	http.HandleFunc("/api/v1/users", server.Require(auth.PermissionManageUsers, server.HandleUsersRoot))
+	http.HandleFunc("/api/v1/users/", server.Require(auth.PermissionManageUsers, server.HandleUsersSubtree))
+
+	http.ListenAndServe(":8080", server.RequestID(http.DefaultServeMux))
+
+}
+
+const migrateUsage = "usage: migrate up | down [steps] | status"
+
+// migrate runs the migrate command
+//
+//	migrate up            applies all the pending migrations
+//	migrate down [steps]  reverts the last steps migrations, one by default
+//	migrate status        lists the migrations and when they were applied
+func migrate(cfg config.Config, args []string) error {
+
+	if len(args) == 0 {
+		return errors.New(migrateUsage)
+	}
+
+	gormDB, err := db.CreateNewGormDB(cfg)
+	if err != nil {
+		return err
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := gormDB.MigrateUp()
+		for _, m := range applied {
+			fmt.Printf("applied %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(applied) == 0 {
+			fmt.Println("no pending migrations")
+		}
+	case "down":
+		steps := 1
+		if len(args) > 1 {
+			steps, err = strconv.Atoi(args[1])
+			if err != nil || steps < 1 {
+				return fmt.Errorf("invalid number of steps %q", args[1])
+			}
+		}
+		reverted, err := gormDB.MigrateDown(steps)
+		for _, m := range reverted {
+			fmt.Printf("reverted %04d_%s\n", m.Version, m.Name)
+		}
+		if err != nil {
+			return err
+		}
+		if len(reverted) == 0 {
+			fmt.Println("no applied migrations")
+		}
+	case "status":
+		states, err := gormDB.MigrationStatus()
+		if err != nil {
+			return err
+		}
+		w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "VERSION\tNAME\tAPPLIED AT")
+		for _, state := range states {
+			appliedAt := "pending"
+			if state.Applied {
+				appliedAt = state.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Fprintf(w, "%04d\t%s\t%s\n", state.Version, state.Name, appliedAt)
+		}
+		w.Flush()
+	default:
+		return errors.New(migrateUsage)
+	}
+
+	return nil
+}
+
+const userUsage = "usage: user role <username> <admin|librarian|member>"
+
+// user runs the user command
+//
+//	user role <username> <role>  changes the role of a user, e.g. to create the first admin
+func user(cfg config.Config, args []string) error {
+
+	if len(args) != 3 || args[0] != "role" {
+		return errors.New(userUsage)
+	}
+
+	username, role := args[1], args[2]
+	if !models.IsValidRole(role) {
+		return errors.New(userUsage)
+	}
+
+	gormDB, err := db.CreateNewGormDB(cfg)
+	if err != nil {
+		return err
+	}
+
+	if err := gormDB.UpdateUserRole(username, role); err != nil {
+		return err
+	}
+
+	fmt.Printf("the role of %s is now %s\n", username, role)
+	return nil
+}
+
+const importUsage = "usage: import [-format csv|jsonl|goodreads|calibre] [-map field:column]... [-batch-size n] [-preview] <username> <file>"
+
+// importBooks runs the import command
+//
+//	import [flags] <username> <file>  creates the books of a CSV or JSON Lines file, a Goodreads
+//	                                  export or the metadata.db of a Calibre library for the user
+//
+// The format is taken from the extension of the file unless -format is given, a .db file
+// being a Calibre library, and -map maps a field of the books to a column of a CSV file,
+// see importer.ParseMapping. -preview lists what the import would do without doing it.
+func importBooks(cfg config.Config, args []string) error {
+
+	flags := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := flags.String("format", "", "csv, jsonl, goodreads or calibre, by default the extension of the file")
+	preview := flags.Bool("preview", false, "report what would be imported without creating the books")
+	batchSize := flags.Int("batch-size", importer.DefaultBatchSize, "number of books created in each transaction")
+	var pairs []string
+	flags.Func("map", "maps a field to a CSV column as field:column, repeated for each field", func(pair string) error {
+		pairs = append(pairs, pair)
+		return nil
+	})
+	if err := flags.Parse(args); err != nil || flags.NArg() != 2 {
+		return errors.New(importUsage)
+	}
+	username, path := flags.Arg(0), flags.Arg(1)
+
+	if *format == "" {
+		*format = strings.TrimPrefix(strings.ToLower(filepath.Ext(path)), ".")
+		if *format == "db" {
+			*format = "calibre"
+		}
+	}
+	mapping, err := importer.ParseMapping(pairs)
+	if err != nil {
+		return err
+	}
+
+	var reader importer.Reader
+	if *format == "calibre" {
+		if reader, err = importer.LoadCalibre(path); err != nil {
+			return err
+		}
+	} else {
+		file, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer file.Close()
+
+		switch *format {
+		case "csv":
+			if reader, err = importer.NewCSVReader(file, mapping); err != nil {
+				return err
+			}
+		case "goodreads":
+			if reader, err = importer.NewGoodreadsReader(file); err != nil {
+				return err
+			}
+		case "jsonl", "ndjson":
+			reader = importer.NewJSONLinesReader(file)
+		default:
+			return errors.New(importUsage)
+		}
+	}
+
+	gormDB, err := db.CreateNewGormDB(cfg)
+	if err != nil {
+		return err
+	}
+	user, err := gormDB.GetUserByUsername(username)
+	if err != nil {
+		return err
+	}
+
+	var report *importer.Report
+	var importErr error
+	if *preview {
+		report, importErr = importer.Preview(gormDB, reader, user.ID)
+	} else {
+		report, importErr = importer.Import(gormDB, reader, user.ID, *batchSize)
+	}
 
-	http.ListenAndServe(":8080", nil)
+	// list the rows that were not or would not be created
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	for _, row := range report.Rows {
+		if row.Status == importer.StatusCreated || row.Status == importer.StatusReady {
+			continue
+		}
+		reason := row.Error
+		for _, fieldError := range row.Errors {
+			reason += "; " + fieldError.Field + " " + fieldError.Message
+		}
+		fmt.Fprintf(w, "line %d\t%s\t%s\t%s\n", row.Line, row.Status, row.Name, reason)
+	}
+	w.Flush()
+	if report.Preview {
+		fmt.Printf("ready %d, skipped %d, failed %d, nothing was imported\n", report.Ready, report.Skipped, report.Failed)
+	} else {
+		fmt.Printf("created %d, skipped %d, failed %d\n", report.Created, report.Skipped, report.Failed)
+	}
 
+	return importErr
 }
@@ -0,0 +1,57 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"github.com/Parsa-Sh-Y/book-manager-service/isbn"
+)
+
+// Fixture is a catalog kept in a local file, for tests and for running the
+// service offline. The file has the format of the answers of Open Library,
+// the books by their bibkey such as ISBN:9780441172719.
+type Fixture struct {
+	books openLibraryBooks
+}
+
+// LoadFixture reads the books of the fixture catalog from the JSON file at path
+func LoadFixture(path string) (*Fixture, error) {
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var books openLibraryBooks
+	if err := json.Unmarshal(data, &books); err != nil {
+		return nil, err
+	}
+
+	return &Fixture{books: books}, nil
+}
+
+func (f *Fixture) LookupISBN(ctx context.Context, number string) (*models.Book, error) {
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	// the books are found by either of their ISBNs, like in Open Library
+	keys := []string{number}
+	if isbn13, err := isbn.To13(number); err == nil && isbn13 != number {
+		keys = append(keys, isbn13)
+	}
+	if isbn10, err := isbn.To10(number); err == nil && isbn10 != number {
+		keys = append(keys, isbn10)
+	}
+
+	for _, key := range keys {
+		if data, ok := f.books["ISBN:"+key]; ok {
+			return data.toBook(number), nil
+		}
+	}
+
+	return nil, ErrNotFound
+}
@@ -0,0 +1,53 @@
+// Package metadata looks up the metadata of books in external catalogs, so the
+// clients can fill a book from its ISBN instead of typing all of its fields.
+//
+// The books found are not saved. Their authors and publisher only have names,
+// which are looked up or created like those of any other book when it is saved.
+package metadata
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/config"
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+)
+
+var (
+	// ErrNotFound The catalog has no book with the ISBN
+	ErrNotFound = errors.New("the catalog has no book with the isbn")
+	// ErrUnavailable The catalog could not be reached or gave an invalid answer
+	ErrUnavailable = errors.New("the catalog is unavailable")
+	// ErrUnknownProvider The configured metadata provider is not supported
+	ErrUnknownProvider = errors.New("unknown metadata provider")
+)
+
+const (
+	ProviderOpenLibrary = "openlibrary"
+	ProviderFixture     = "fixture"
+)
+
+// Provider is a catalog of book metadata
+type Provider interface {
+	// LookupISBN returns the book with the ISBN, an ISBN-10 or an ISBN-13 without hyphens.
+	// It returns ErrNotFound when the catalog has no such book, and wraps ErrUnavailable
+	// when the catalog can not answer.
+	LookupISBN(ctx context.Context, isbn string) (*models.Book, error)
+}
+
+// NewProvider creates the metadata provider of the configuration
+func NewProvider(conf config.Config) (Provider, error) {
+
+	switch conf.Metadata.Provider {
+	case ProviderOpenLibrary:
+		client := &http.Client{Timeout: time.Duration(conf.Metadata.TimeoutInSeconds) * time.Second}
+		return NewOpenLibrary(conf.Metadata.URL, client), nil
+	case ProviderFixture:
+		return LoadFixture(conf.Metadata.FixturePath)
+	}
+
+	return nil, fmt.Errorf("%w %q", ErrUnknownProvider, conf.Metadata.Provider)
+}
@@ -0,0 +1,191 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"github.com/Parsa-Sh-Y/book-manager-service/isbn"
+)
+
+// maxResponseSize is the largest answer of the catalog that is read
+const maxResponseSize = 1 << 20
+
+// OpenLibrary looks up the books in the Books API of Open Library,
+// or of any catalog answering in the same format
+type OpenLibrary struct {
+	baseURL string
+	client  *http.Client
+}
+
+// NewOpenLibrary creates an Open Library client for the catalog at baseURL, such as https://openlibrary.org
+func NewOpenLibrary(baseURL string, client *http.Client) *OpenLibrary {
+
+	return &OpenLibrary{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		client:  client,
+	}
+
+}
+
+// openLibraryBooks is the answer of /api/books?jscmd=data, the books by their bibkey such as ISBN:9780441172719
+type openLibraryBooks map[string]openLibraryBook
+
+type openLibraryBook struct {
+	Title    string `json:"title"`
+	Subtitle string `json:"subtitle"`
+	Authors  []struct {
+		Name string `json:"name"`
+	} `json:"authors"`
+	Publishers []struct {
+		Name string `json:"name"`
+	} `json:"publishers"`
+	PublishDate string `json:"publish_date"`
+	Identifiers struct {
+		ISBN10 []string `json:"isbn_10"`
+		ISBN13 []string `json:"isbn_13"`
+	} `json:"identifiers"`
+	TableOfContents []openLibraryContent `json:"table_of_contents"`
+}
+
+// openLibraryContent is an entry of a table of contents. The entries are
+// a flat list, where each entry is nested in the last entry of a lower level.
+type openLibraryContent struct {
+	Level   int    `json:"level"`
+	Label   string `json:"label"`
+	Title   string `json:"title"`
+	PageNum string `json:"pagenum"`
+}
+
+// publishDateLayouts are the formats of the publish dates of Open Library
+var publishDateLayouts = []string{"2006-01-02", "January 2, 2006", "Jan 2, 2006", "2 January 2006", "January 2006", "Jan 2006", "2006"}
+
+func (ol *OpenLibrary) LookupISBN(ctx context.Context, number string) (*models.Book, error) {
+
+	query := url.Values{"bibkeys": {"ISBN:" + number}, "format": {"json"}, "jscmd": {"data"}}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, ol.baseURL+"/api/books?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "book-manager-service")
+
+	resp, err := ol.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: the catalog answered %s", ErrUnavailable, resp.Status)
+	}
+
+	var books openLibraryBooks
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxResponseSize)).Decode(&books); err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrUnavailable, err)
+	}
+
+	return books.book(number)
+}
+
+// book returns the book with the ISBN in the answer of the catalog
+func (books openLibraryBooks) book(number string) (*models.Book, error) {
+
+	data, ok := books["ISBN:"+number]
+	if !ok {
+		return nil, ErrNotFound
+	}
+
+	return data.toBook(number), nil
+}
+
+// toBook maps the book of Open Library onto a book of the service
+func (data *openLibraryBook) toBook(number string) *models.Book {
+
+	book := &models.Book{Name: strings.TrimSpace(data.Title)}
+	if subtitle := strings.TrimSpace(data.Subtitle); subtitle != "" {
+		book.Name += ": " + subtitle
+	}
+
+	// the ISBN looked up is the one of this edition, the identifiers may list others
+	if isbn13, err := isbn.To13(number); err == nil {
+		book.ISBN13 = isbn13
+	}
+	if isbn10, err := isbn.To10(number); err == nil {
+		book.ISBN10 = isbn10
+	}
+
+	for _, author := range data.Authors {
+		if author.Name = strings.TrimSpace(author.Name); author.Name != "" {
+			book.Authors = append(book.Authors, models.BookAuthor{Role: models.AuthorRoleAuthor, Author: models.AuthorFromName(author.Name)})
+		}
+	}
+
+	if len(data.Publishers) > 0 && strings.TrimSpace(data.Publishers[0].Name) != "" {
+		book.Publisher = &models.Publisher{Name: strings.TrimSpace(data.Publishers[0].Name)}
+	}
+
+	for _, layout := range publishDateLayouts {
+		if date, err := time.Parse(layout, strings.TrimSpace(data.PublishDate)); err == nil {
+			book.PublishedAt = date
+			break
+		}
+	}
+
+	book.TableOfContents, _ = openLibraryContents(data.TableOfContents, 0)
+
+	return book
+}
+
+// openLibraryContents returns the tree of the entries from the first one up to the first entry
+// of a level lower than level, and the entries after them. An entry deeper than the one before
+// it is nested in it, and the entries with neither a title nor a label are left out. The
+// entries are numbered by their position among the entries nested in the same one.
+func openLibraryContents(entries []openLibraryContent, level int) ([]models.Content, []openLibraryContent) {
+
+	var contents []models.Content
+	for len(entries) > 0 {
+		entry := entries[0]
+		if entry.Level < level {
+			break
+		}
+
+		if entry.Level > level && len(contents) > 0 {
+			var children []models.Content
+			children, entries = openLibraryContents(entries, entry.Level)
+			last := &contents[len(contents)-1]
+			last.Children = append(last.Children, children...)
+			continue
+		}
+		entries = entries[1:]
+
+		name := strings.TrimSpace(entry.Title)
+		if label := strings.TrimSpace(entry.Label); label != "" && name != "" {
+			name = label + " " + name
+		} else if name == "" {
+			name = label
+		}
+		if name == "" {
+			continue
+		}
+
+		// the position is the order of the entry among the ones of its level
+		content := models.Content{ContentName: name, Position: len(contents)}
+		if page, err := strconv.Atoi(strings.TrimSpace(entry.PageNum)); err == nil && page > 0 {
+			content.Page = &page
+		}
+		contents = append(contents, content)
+	}
+
+	return contents, entries
+}
@@ -0,0 +1,233 @@
+package metadata
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+)
+
+// recordedBooks are answers of the Books API of Open Library, by their bibkey
+func recordedBooks(t *testing.T) map[string]json.RawMessage {
+
+	t.Helper()
+	data, err := os.ReadFile("testdata/openlibrary.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var books map[string]json.RawMessage
+	if err := json.Unmarshal(data, &books); err != nil {
+		t.Fatal(err)
+	}
+	return books
+}
+
+// newCatalog serves the recorded books like /api/books of Open Library, which answers
+// with an empty object for unknown books. The bibkeys ISBN:404 and ISBN:500 answer
+// with their status, and ISBN:invalid with a body that is not JSON.
+func newCatalog(t *testing.T) *httptest.Server {
+
+	books := recordedBooks(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query := r.URL.Query()
+		if r.URL.Path != "/api/books" || query.Get("format") != "json" || query.Get("jscmd") != "data" {
+			http.Error(w, "unexpected request "+r.URL.String(), http.StatusBadRequest)
+			return
+		}
+
+		bibkey := query.Get("bibkeys")
+		switch bibkey {
+		case "ISBN:404":
+			http.NotFound(w, r)
+			return
+		case "ISBN:500":
+			http.Error(w, "down", http.StatusInternalServerError)
+			return
+		case "ISBN:invalid":
+			w.Write([]byte("<html>"))
+			return
+		}
+
+		answer := map[string]json.RawMessage{}
+		if book, ok := books[bibkey]; ok {
+			answer[bibkey] = book
+		}
+		json.NewEncoder(w).Encode(answer)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func page(n int) *int {
+	return &n
+}
+
+func TestOpenLibraryLookupISBN(t *testing.T) {
+
+	catalog := NewOpenLibrary(newCatalog(t).URL+"/", http.DefaultClient)
+
+	book, err := catalog.LookupISBN(context.Background(), "9780441172719")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := &models.Book{
+		Name:        "Dune",
+		ISBN10:      "0441172717",
+		ISBN13:      "9780441172719",
+		PublishedAt: time.Date(1990, time.September, 1, 0, 0, 0, 0, time.UTC),
+		Publisher:   &models.Publisher{Name: "Ace Books"},
+		Authors: []models.BookAuthor{
+			{Role: models.AuthorRoleAuthor, Author: models.Author{FirstName: "Frank", LastName: "Herbert"}},
+		},
+		TableOfContents: []models.Content{
+			{ContentName: "Book One: Dune", Position: 0, Page: page(1)},
+			{ContentName: "Book Two: Muad'Dib", Position: 1, Page: page(229)},
+			{ContentName: "Book Three: The Prophet", Position: 2, Page: page(377)},
+			{ContentName: "Appendixes", Position: 3, Page: page(495), Children: []models.Content{
+				{ContentName: "I The Ecology of Dune", Position: 0, Page: page(495)},
+				{ContentName: "II The Religion of Dune", Position: 1, Page: page(505)},
+			}},
+			{ContentName: "Terminology of the Imperium", Position: 4, Page: page(519)},
+		},
+	}
+	if !reflect.DeepEqual(book, want) {
+		got, _ := json.MarshalIndent(book, "", "  ")
+		t.Errorf("LookupISBN() =\n%s", got)
+	}
+}
+
+func TestOpenLibraryLookupISBNSubtitleAndAuthors(t *testing.T) {
+
+	catalog := NewOpenLibrary(newCatalog(t).URL, http.DefaultClient)
+
+	book, err := catalog.LookupISBN(context.Background(), "9780262510875")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if want := "Structure and Interpretation of Computer Programs: Second Edition"; book.Name != want {
+		t.Errorf("Name = %q, want %q", book.Name, want)
+	}
+	if want := time.Date(1996, time.January, 1, 0, 0, 0, 0, time.UTC); !book.PublishedAt.Equal(want) {
+		t.Errorf("PublishedAt = %v, want %v", book.PublishedAt, want)
+	}
+
+	var names []string
+	for _, credit := range book.Authors {
+		names = append(names, credit.Author.LastName+", "+credit.Author.FirstName)
+	}
+	if want := "Abelson, Harold; Sussman, Gerald Jay; Sussman, Julie"; strings.Join(names, "; ") != want {
+		t.Errorf("Authors = %v, want %s", names, want)
+	}
+
+	if got := len(book.TableOfContents); got != 5 {
+		t.Fatalf("%d top level contents, want 5", got)
+	}
+	first := book.TableOfContents[0]
+	if first.ContentName != "1 Building Abstractions with Procedures" || len(first.Children) != 3 {
+		t.Errorf("first content = %q with %d children", first.ContentName, len(first.Children))
+	}
+	for i, child := range first.Children {
+		if child.Position != i {
+			t.Errorf("child %q at position %d, want %d", child.ContentName, child.Position, i)
+		}
+	}
+}
+
+func TestOpenLibraryLookupISBNErrors(t *testing.T) {
+
+	catalog := NewOpenLibrary(newCatalog(t).URL, http.DefaultClient)
+
+	tests := []struct {
+		isbn string
+		want error
+	}{
+		{"9780000000002", ErrNotFound},
+		{"404", ErrNotFound},
+		{"500", ErrUnavailable},
+		{"invalid", ErrUnavailable},
+	}
+
+	for _, tt := range tests {
+		book, err := catalog.LookupISBN(context.Background(), tt.isbn)
+		if !errors.Is(err, tt.want) {
+			t.Errorf("LookupISBN(%q) = %v, %v, want %v", tt.isbn, book, err, tt.want)
+		}
+	}
+}
+
+func TestOpenLibraryUnreachable(t *testing.T) {
+
+	server := newCatalog(t)
+	server.Close()
+
+	_, err := NewOpenLibrary(server.URL, http.DefaultClient).LookupISBN(context.Background(), "9780441172719")
+	if !errors.Is(err, ErrUnavailable) {
+		t.Errorf("LookupISBN() = %v, want %v", err, ErrUnavailable)
+	}
+}
+
+func TestOpenLibraryContents(t *testing.T) {
+
+	entries := []openLibraryContent{
+		{Level: 0, Title: "Part One"},
+		{Level: 1, Label: "1", Title: "Beginnings", PageNum: "3"},
+		{Level: 1, PageNum: "9"}, // neither a title nor a label
+		{Level: 2, Title: "A section"},
+		{Level: 1, Label: "2", PageNum: "x"},
+		{Level: 0, Title: "Part Two"},
+	}
+
+	contents, rest := openLibraryContents(entries, 0)
+	if len(rest) != 0 {
+		t.Errorf("%d entries left", len(rest))
+	}
+
+	want := []models.Content{
+		{ContentName: "Part One", Position: 0, Children: []models.Content{
+			{ContentName: "1 Beginnings", Position: 0, Page: page(3), Children: []models.Content{
+				{ContentName: "A section", Position: 0},
+			}},
+			{ContentName: "2", Position: 1},
+		}},
+		{ContentName: "Part Two", Position: 1},
+	}
+	if !reflect.DeepEqual(contents, want) {
+		got, _ := json.MarshalIndent(contents, "", "  ")
+		t.Errorf("openLibraryContents() =\n%s", got)
+	}
+}
+
+func TestFixtureLookupISBN(t *testing.T) {
+
+	fixture, err := LoadFixture("testdata/openlibrary.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the books are found by either of their ISBNs, hyphens are removed by the callers
+	for _, number := range []string{"9780441172719", "0441172717"} {
+		book, err := fixture.LookupISBN(context.Background(), number)
+		if err != nil {
+			t.Fatalf("LookupISBN(%q) = %v", number, err)
+		}
+		if book.Name != "Dune" || book.ISBN10 != "0441172717" || book.ISBN13 != "9780441172719" {
+			t.Errorf("LookupISBN(%q) = %q %s %s", number, book.Name, book.ISBN10, book.ISBN13)
+		}
+	}
+
+	if _, err := fixture.LookupISBN(context.Background(), "9780000000002"); !errors.Is(err, ErrNotFound) {
+		t.Errorf("LookupISBN() of an unknown book = %v, want %v", err, ErrNotFound)
+	}
+}
@@ -0,0 +1,410 @@
+// Package validation checks structs against the rules of their validate tags.
+//
+//	type User struct {
+//		Username string `json:"user_name" validate:"required,max=50"`
+//		Email    string `json:"email" validate:"required,email,max=50"`
+//	}
+//
+// The rules are separated by commas:
+//
+//	required   the value is not the zero value
+//	required_without=F
+//	           the value is required when the field F of the same struct is empty
+//	min=N      strings have at least N characters, numbers are at least N
+//	max=N      strings have at most N characters, numbers are at most N
+//	len=N      strings have exactly N characters
+//	oneof=A B  the string is one of the space separated values
+//	email      the string is an email address such as ali@example.com
+//	phone      the string is an 11 digit phone number such as 09120000000
+//	url        the string is an http or https URL such as https://example.com
+//	password   the string is a strong password, see passwordRule
+//	isbn10     the string is an ISBN-10 such as 0-441-17271-7
+//	isbn13     the string is an ISBN-13 such as 978-0-441-17271-9
+//	isbn       the string is an ISBN-10 or an ISBN-13
+//	dive       the rules after it are checked on each element of a slice
+//
+// Empty values that are not required are not checked against the other rules.
+// Nested structs are checked too, their field paths are joined with dots.
+package validation
+
+import (
+	"fmt"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/isbn"
+)
+
+// minPasswordLength is the minimum length of a password
+const minPasswordLength = 8
+
+// maxPasswordLength is the longest password bcrypt can hash
+const maxPasswordLength = 72
+
+// FieldError is a field that breaks one of its rules
+type FieldError struct {
+	// Field is the path of the field in the JSON document, such as author.first_name or table_of_contents[2]
+	Field   string `json:"field"`
+	Rule    string `json:"rule"`
+	Message string `json:"message"`
+}
+
+// Errors are all the field errors of a struct
+type Errors []FieldError
+
+func (e Errors) Error() string {
+
+	messages := make([]string, 0, len(e))
+	for _, fe := range e {
+		messages = append(messages, fe.Field+": "+fe.Message)
+	}
+
+	return "validation failed: " + strings.Join(messages, "; ")
+}
+
+// Validate checks v, a struct or a pointer to a struct, against its validate tags.
+// It returns Errors with every field error, or nil when v is valid.
+func Validate(v interface{}) error {
+
+	value := reflect.Indirect(reflect.ValueOf(v))
+	if value.Kind() != reflect.Struct {
+		panic(fmt.Sprintf("validation: can not validate a %T", v))
+	}
+
+	var errs Errors
+	validateStruct(value, "", &errs)
+	if len(errs) == 0 {
+		return nil
+	}
+
+	return errs
+}
+
+func validateStruct(value reflect.Value, prefix string, errs *Errors) {
+
+	t := value.Type()
+	for i := 0; i < t.NumField(); i++ {
+		field := t.Field(i)
+		name, ok := fieldName(field)
+		if !ok {
+			continue
+		}
+
+		rules := splitRules(field.Tag.Get("validate"))
+		validateValue(value.Field(i), value, prefix+name, rules, errs)
+	}
+}
+
+// fieldName returns the JSON name of the field, ok is false when the field is not in the JSON document
+func fieldName(field reflect.StructField) (name string, ok bool) {
+
+	if !field.IsExported() {
+		return "", false
+	}
+
+	name, _, _ = strings.Cut(field.Tag.Get("json"), ",")
+	if name == "-" {
+		return "", false
+	}
+	if name == "" {
+		name = field.Name
+	}
+
+	return name, true
+}
+
+func splitRules(tag string) []string {
+
+	if tag == "" {
+		return nil
+	}
+
+	return strings.Split(tag, ",")
+}
+
+// validateValue checks the value of a field of parent, or of an element of a slice
+// when parent is the zero Value
+func validateValue(value reflect.Value, parent reflect.Value, path string, rules []string, errs *Errors) {
+
+	if value.IsZero() {
+		if hasRule(rules, "required") {
+			errs.add(path, "required", "is required")
+			return
+		}
+		if other, ok := ruleParam(rules, "required_without"); ok && parent.IsValid() && parent.FieldByName(other).IsZero() {
+			errs.add(path, "required", "is required")
+			return
+		}
+		// the other rules are not checked on empty values,
+		// but the fields of empty structs may be required
+		if value.Kind() != reflect.Struct {
+			return
+		}
+		rules = nil
+	}
+
+	// the rules of pointers are checked on the values they point to
+	value = reflect.Indirect(value)
+
+	for i, rule := range rules {
+		if rule == "dive" {
+			if value.Kind() == reflect.Slice || value.Kind() == reflect.Array {
+				for j := 0; j < value.Len(); j++ {
+					validateValue(value.Index(j), reflect.Value{}, fmt.Sprintf("%s[%d]", path, j), rules[i+1:], errs)
+				}
+			}
+			return
+		}
+
+		if rule == "required" || strings.HasPrefix(rule, "required_without=") {
+			continue
+		}
+
+		name, param, _ := strings.Cut(rule, "=")
+		check, ok := checks[name]
+		if !ok {
+			panic(fmt.Sprintf("validation: unknown rule %q of %s", rule, path))
+		}
+		if message := check(value, param); message != "" {
+			errs.add(path, name, message)
+		}
+	}
+
+	// check the fields of nested structs
+	if value.Kind() == reflect.Struct && value.Type() != reflect.TypeOf(time.Time{}) {
+		validateStruct(value, path+".", errs)
+	}
+}
+
+func hasRule(rules []string, name string) bool {
+
+	for _, rule := range rules {
+		if rule == "dive" {
+			return false
+		}
+		if rule == name {
+			return true
+		}
+	}
+
+	return false
+}
+
+// ruleParam returns the parameter of the rule with the name
+func ruleParam(rules []string, name string) (string, bool) {
+
+	for _, rule := range rules {
+		if rule == "dive" {
+			return "", false
+		}
+		if n, param, ok := strings.Cut(rule, "="); ok && n == name {
+			return param, true
+		}
+	}
+
+	return "", false
+}
+
+func (e *Errors) add(field string, rule string, message string) {
+	*e = append(*e, FieldError{Field: field, Rule: rule, Message: message})
+}
+
+// check returns the message of the broken rule, or "" when value keeps the rule
+type check func(value reflect.Value, param string) string
+
+var checks = map[string]check{
+	"min":      minRule,
+	"max":      maxRule,
+	"len":      lenRule,
+	"oneof":    oneofRule,
+	"email":    emailRule,
+	"phone":    phoneRule,
+	"password": passwordRule,
+	"url":      urlRule,
+	"isbn10":   isbn10Rule,
+	"isbn13":   isbn13Rule,
+	"isbn":     isbnRule,
+}
+
+func minRule(value reflect.Value, param string) string {
+
+	limit := intParam(param)
+	if value.Kind() == reflect.String {
+		if utf8.RuneCountInString(value.String()) < limit {
+			return fmt.Sprintf("must be at least %d characters long", limit)
+		}
+		return ""
+	}
+
+	if number(value) < float64(limit) {
+		return fmt.Sprintf("must be at least %d", limit)
+	}
+	return ""
+}
+
+func maxRule(value reflect.Value, param string) string {
+
+	limit := intParam(param)
+	if value.Kind() == reflect.String {
+		if utf8.RuneCountInString(value.String()) > limit {
+			return fmt.Sprintf("must be at most %d characters long", limit)
+		}
+		return ""
+	}
+
+	if number(value) > float64(limit) {
+		return fmt.Sprintf("must be at most %d", limit)
+	}
+	return ""
+}
+
+func lenRule(value reflect.Value, param string) string {
+
+	length := intParam(param)
+	if utf8.RuneCountInString(value.String()) != length {
+		return fmt.Sprintf("must be exactly %d characters long", length)
+	}
+
+	return ""
+}
+
+func oneofRule(value reflect.Value, param string) string {
+
+	values := strings.Fields(param)
+	for _, v := range values {
+		if value.String() == v {
+			return ""
+		}
+	}
+
+	return "must be one of " + strings.Join(values, ", ")
+}
+
+func emailRule(value reflect.Value, _ string) string {
+
+	// only a bare address is an email, not "Ali <ali@example.com>"
+	address, err := mail.ParseAddress(value.String())
+	if err != nil || address.Address != value.String() {
+		return "must be an email address"
+	}
+
+	// the domain needs a top level domain
+	_, domain, _ := strings.Cut(address.Address, "@")
+	if !strings.Contains(strings.Trim(domain, "."), ".") {
+		return "must be an email address"
+	}
+
+	return ""
+}
+
+func phoneRule(value reflect.Value, _ string) string {
+
+	phone := value.String()
+	if len(phone) != 11 || strings.IndexFunc(phone, func(r rune) bool { return r < '0' || r > '9' }) != -1 {
+		return "must be a phone number of 11 digits"
+	}
+
+	return ""
+}
+
+func urlRule(value reflect.Value, _ string) string {
+
+	u, err := url.Parse(value.String())
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return "must be an http or https URL"
+	}
+
+	return ""
+}
+
+// isbn10Rule requires an ISBN-10 with a correct check digit, the groups may be separated by hyphens
+func isbn10Rule(value reflect.Value, _ string) string {
+
+	if !isbn.Valid10(value.String()) {
+		return "must be an ISBN-10 with a correct check digit"
+	}
+
+	return ""
+}
+
+// isbn13Rule requires an ISBN-13 with a correct check digit, the groups may be separated by hyphens
+func isbn13Rule(value reflect.Value, _ string) string {
+
+	if !isbn.Valid13(value.String()) {
+		return "must be an ISBN-13 with a correct check digit"
+	}
+
+	return ""
+}
+
+// isbnRule requires an ISBN-10 or an ISBN-13
+func isbnRule(value reflect.Value, _ string) string {
+
+	if !isbn.Valid10(value.String()) && !isbn.Valid13(value.String()) {
+		return "must be an ISBN-10 or an ISBN-13 with a correct check digit"
+	}
+
+	return ""
+}
+
+// passwordRule requires passwords of at least 8 characters and at most 72 bytes with
+// a lower case letter, an upper case letter and a digit
+func passwordRule(value reflect.Value, _ string) string {
+
+	password := value.String()
+	if utf8.RuneCountInString(password) < minPasswordLength {
+		return fmt.Sprintf("must be at least %d characters long", minPasswordLength)
+	}
+	if len(password) > maxPasswordLength {
+		return fmt.Sprintf("must be at most %d bytes long", maxPasswordLength)
+	}
+
+	var lower, upper, digit bool
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = true
+		case unicode.IsUpper(r):
+			upper = true
+		case unicode.IsDigit(r):
+			digit = true
+		}
+	}
+	if !lower || !upper || !digit {
+		return "must have a lower case letter, an upper case letter and a digit"
+	}
+
+	return ""
+}
+
+func intParam(param string) int {
+
+	n, err := strconv.Atoi(param)
+	if err != nil {
+		panic(fmt.Sprintf("validation: %q is not a number", param))
+	}
+
+	return n
+}
+
+func number(value reflect.Value) float64 {
+
+	switch value.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(value.Int())
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(value.Uint())
+	case reflect.Float32, reflect.Float64:
+		return value.Float()
+	case reflect.Slice, reflect.Array, reflect.Map:
+		return float64(value.Len())
+	}
+
+	panic(fmt.Sprintf("validation: can not compare a %s", value.Kind()))
+}
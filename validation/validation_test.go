@@ -0,0 +1,200 @@
+package validation_test
+
+import (
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/Parsa-Sh-Y/book-manager-service/db/models"
+	"github.com/Parsa-Sh-Y/book-manager-service/validation"
+)
+
+// fieldRules returns the field errors of err as field:rule, nil when err is nil
+func fieldRules(t *testing.T, err error) []string {
+
+	t.Helper()
+	if err == nil {
+		return nil
+	}
+
+	var errs validation.Errors
+	if !errors.As(err, &errs) {
+		t.Fatalf("Validate returned %v, want validation.Errors", err)
+	}
+
+	var rules []string
+	for _, fe := range errs {
+		rules = append(rules, fe.Field+":"+fe.Rule)
+	}
+	return rules
+}
+
+type author struct {
+	ID        uint   `json:"id"`
+	FirstName string `json:"first_name" validate:"max=5"`
+	LastName  string `json:"last_name" validate:"required_without=ID,max=5"`
+}
+
+type credit struct {
+	Role   string `json:"role" validate:"oneof=author editor"`
+	Author author `json:"author"`
+}
+
+type publisher struct {
+	Name string `json:"name" validate:"required"`
+}
+
+type shelf struct {
+	Name      string     `json:"name" validate:"required,max=10"`
+	Owner     string     `json:"-" validate:"required"`
+	Email     string     `json:"email" validate:"email"`
+	Phone     string     `json:"phone" validate:"phone"`
+	Website   string     `json:"website" validate:"url"`
+	Password  string     `json:"password" validate:"password"`
+	Code      string     `json:"code" validate:"len=3"`
+	ISBN10    string     `json:"isbn_10" validate:"isbn10"`
+	ISBN13    string     `json:"isbn_13" validate:"isbn13"`
+	ISBN      string     `json:"isbn" validate:"isbn"`
+	Size      int        `json:"size" validate:"min=1,max=3"`
+	Tags      []string   `json:"tags" validate:"max=2,dive,required,max=3"`
+	Credits   []credit   `json:"credits" validate:"dive"`
+	Publisher *publisher `json:"publisher"`
+	Untagged  string
+}
+
+// validShelf returns a shelf keeping all its rules, the tests break one of them
+func validShelf() shelf {
+
+	return shelf{
+		Name:     "fiction",
+		Owner:    "ali",
+		Email:    "ali@example.com",
+		Phone:    "09120000000",
+		Website:  "https://example.com",
+		Password: "Passw0rd",
+		Code:     "abc",
+		ISBN10:   "0-441-17271-7",
+		ISBN13:   "978-0-441-17271-9",
+		ISBN:     "080442957X",
+		Size:     2,
+		Tags:     []string{"a", "bc"},
+		Credits:  []credit{{Role: "author", Author: author{LastName: "Le"}}},
+	}
+}
+
+func TestValidate(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		shelf func(s *shelf)
+		want  []string
+	}{
+		{"valid", func(s *shelf) {}, nil},
+		{"empty values are not checked", func(s *shelf) {
+			s.Email, s.Phone, s.Website, s.Password, s.Code, s.ISBN10, s.ISBN13, s.ISBN = "", "", "", "", "", "", "", ""
+			s.Tags, s.Credits = nil, nil
+		}, nil},
+
+		{"required", func(s *shelf) { s.Name = "" }, []string{"name:required"}},
+		{"required field not in json", func(s *shelf) { s.Owner = "" }, nil},
+		{"required pointer", func(s *shelf) { s.Publisher = &publisher{} }, []string{"publisher.name:required"}},
+		{"nil pointer", func(s *shelf) { s.Publisher = nil }, nil},
+
+		{"max string counts characters", func(s *shelf) { s.Name = "éééééééééé" }, nil},
+		{"max string", func(s *shelf) { s.Name = "fiction and more" }, []string{"name:max"}},
+		{"min number", func(s *shelf) { s.Size = -1 }, []string{"size:min"}},
+		{"max number", func(s *shelf) { s.Size = 4 }, []string{"size:max"}},
+		{"max slice", func(s *shelf) { s.Tags = []string{"a", "b", "c"} }, []string{"tags:max"}},
+		{"len", func(s *shelf) { s.Code = "abcd" }, []string{"code:len"}},
+
+		{"email", func(s *shelf) { s.Email = "ali" }, []string{"email:email"}},
+		{"email with a name", func(s *shelf) { s.Email = "Ali <ali@example.com>" }, []string{"email:email"}},
+		{"email without a top level domain", func(s *shelf) { s.Email = "ali@localhost" }, []string{"email:email"}},
+
+		{"phone too short", func(s *shelf) { s.Phone = "0912000000" }, []string{"phone:phone"}},
+		{"phone with a letter", func(s *shelf) { s.Phone = "0912000000a" }, []string{"phone:phone"}},
+
+		{"url without a scheme", func(s *shelf) { s.Website = "example.com" }, []string{"website:url"}},
+		{"url of another scheme", func(s *shelf) { s.Website = "ftp://example.com" }, []string{"website:url"}},
+
+		{"password too short", func(s *shelf) { s.Password = "Pass0rd" }, []string{"password:password"}},
+		{"password too long", func(s *shelf) { s.Password = "Passw0rd" + strings.Repeat("a", 65) }, []string{"password:password"}},
+		{"password without an upper case letter", func(s *shelf) { s.Password = "passw0rd" }, []string{"password:password"}},
+		{"password without a digit", func(s *shelf) { s.Password = "Password" }, []string{"password:password"}},
+
+		{"isbn10 check digit", func(s *shelf) { s.ISBN10 = "0441172718" }, []string{"isbn_10:isbn10"}},
+		{"isbn10 given an isbn13", func(s *shelf) { s.ISBN10 = "9780441172719" }, []string{"isbn_10:isbn10"}},
+		{"isbn13 check digit", func(s *shelf) { s.ISBN13 = "9780441172710" }, []string{"isbn_13:isbn13"}},
+		{"isbn13 of an EAN", func(s *shelf) { s.ISBN13 = "4006381333931" }, []string{"isbn_13:isbn13"}},
+		{"isbn of either form", func(s *shelf) { s.ISBN = "979-10-323-0082-4" }, nil},
+		{"isbn", func(s *shelf) { s.ISBN = "12345" }, []string{"isbn:isbn"}},
+
+		{"dive into strings", func(s *shelf) { s.Tags = []string{"", "abcd"} }, []string{"tags[0]:required", "tags[1]:max"}},
+		{"dive into structs", func(s *shelf) {
+			s.Credits = append(s.Credits, credit{Role: "writer", Author: author{FirstName: "Ursula"}})
+		}, []string{"credits[1].role:oneof", "credits[1].author.first_name:max", "credits[1].author.last_name:required"}},
+		{"required_without the other field", func(s *shelf) { s.Credits[0].Author = author{ID: 3} }, nil},
+
+		{"all the errors", func(s *shelf) { s.Name, s.Size = "", 9 }, []string{"name:required", "size:max"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			s := validShelf()
+			tt.shelf(&s)
+
+			got := fieldRules(t, validation.Validate(&s))
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Validate() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateBook(t *testing.T) {
+
+	book := models.Book{
+		Rating:              6,
+		ISBN13:              "4006381333931",
+		TableOfContentsJson: []string{"Part I", ""},
+		Authors:             []models.BookAuthor{{Role: models.AuthorRoleAuthor, Author: models.Author{FirstName: "Frank"}}},
+		Tags:                []models.Tag{{Name: strings.Repeat("t", 51)}},
+	}
+
+	want := []string{
+		"name:required",
+		"isbn_13:isbn13",
+		"table_of_contents[1]:required",
+		"authors[0].author.last_name:required",
+		"tags[0].name:max",
+		"rating:max",
+	}
+	if got := fieldRules(t, validation.Validate(&book)); !reflect.DeepEqual(got, want) {
+		t.Errorf("Validate() = %v, want %v", got, want)
+	}
+}
+
+func TestErrorsError(t *testing.T) {
+
+	err := validation.Errors{
+		{Field: "name", Rule: "required", Message: "is required"},
+		{Field: "tags[0]", Rule: "max", Message: "must be at most 3 characters long"},
+	}
+
+	want := "validation failed: name: is required; tags[0]: must be at most 3 characters long"
+	if got := err.Error(); got != want {
+		t.Errorf("Error() = %q, want %q", got, want)
+	}
+}
+
+func TestValidateNotAStruct(t *testing.T) {
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Validate of a string did not panic")
+		}
+	}()
+
+	validation.Validate("book")
+}